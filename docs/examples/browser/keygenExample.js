@@ -0,0 +1,37 @@
+// eslint-disable-next-line @typescript-eslint/no-var-requires
+const portablegabi = require('@kiltprotocol/portablegabi')
+
+// Generates a new key pair of an attester inside a Web Worker (see
+// keygenWorker.js). The key generation can be aborted using abortKeyGen().
+const worker = new Worker('dist/keygenWorker.js')
+
+function generateKeyPair(options) {
+  return new Promise((resolve, reject) => {
+    worker.onmessage = ({ data }) => {
+      switch (data.type) {
+        case 'progress':
+          console.log('Key generation:\n\t', data.progress)
+          break
+        case 'done':
+          resolve(
+            new portablegabi.Attester(
+              new portablegabi.AttesterPublicKey(data.publicKey),
+              new portablegabi.AttesterPrivateKey(data.privateKey),
+              data.publicKeyProof &&
+                new portablegabi.AttesterPublicKeyProof(data.publicKeyProof)
+            )
+          )
+          break
+        default:
+          reject(Object.assign(new Error(data.message), { code: data.code }))
+      }
+    }
+    worker.postMessage({ type: 'generate', options })
+  })
+}
+
+window.abortKeyGen = () => worker.postMessage({ type: 'abort' })
+
+generateKeyPair({ withProof: false })
+  .then((attester) => console.log('Public key:\n\t', attester.publicKey))
+  .catch((e) => console.error('Key generation failed:\n\t', e.code, e))
@@ -0,0 +1,37 @@
+// eslint-disable-next-line @typescript-eslint/no-var-requires
+const portablegabi = require('@kiltprotocol/portablegabi')
+
+// The key generation takes several seconds and the proof of the public key
+// several minutes. Running them inside this worker keeps the page responsive.
+let controller
+
+onmessage = async ({ data }) => {
+  if (data.type === 'abort') {
+    if (controller) controller.abort()
+    return
+  }
+  if (data.type !== 'generate' || controller) return
+
+  controller = new AbortController()
+  try {
+    const { privateKey, publicKey, publicKeyProof } =
+      await portablegabi.Attester.genKeyPair({
+        ...data.options,
+        signal: controller.signal,
+        // the progress is forwarded to the page
+        onProgress: (progress) => postMessage({ type: 'progress', progress }),
+      })
+    // the keys are sent as strings and restored by the page
+    postMessage({
+      type: 'done',
+      privateKey: privateKey.toString(),
+      publicKey: publicKey.toString(),
+      publicKeyProof: publicKeyProof && publicKeyProof.toString(),
+    })
+  } catch (e) {
+    // e.code is 'ERR_ABORTED' if the key generation was aborted
+    postMessage({ type: 'error', code: e.code, message: e.message })
+  } finally {
+    controller = undefined
+  }
+}
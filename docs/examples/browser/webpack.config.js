@@ -10,9 +10,9 @@ const defaultEnv = {
 
 module.exports = (inputEnv) => {
   const env = { ...defaultEnv, ...inputEnv }
-  return {
+  const config = (entry, wasmFetchDir) => ({
     mode: 'production',
-    entry: './browserExample.js',
+    entry,
     resolve: {
       extensions: ['.js'],
     },
@@ -24,15 +24,26 @@ module.exports = (inputEnv) => {
         },
       ]),
       new DefinePlugin({
-        'process.env.WASM_FETCH_DIR': JSON.stringify('./dist'),
+        'process.env.WASM_FETCH_DIR': JSON.stringify(wasmFetchDir),
       }),
     ],
     output: {
-      filename: 'bundle.js',
+      filename: '[name].js',
       path: path.resolve(__dirname, env.outPath),
     },
     node: {
       fs: 'empty',
     },
-  }
+  })
+  return [
+    config(
+      { bundle: './browserExample.js', keygen: './keygenExample.js' },
+      './dist'
+    ),
+    // the worker fetches the wasm relative to its own location inside dist
+    {
+      ...config({ keygenWorker: './keygenWorker.js' }, '.'),
+      target: 'webworker',
+    },
+  ]
 }
@@ -0,0 +1,73 @@
+// +build !wasm
+
+// Command primepool precomputes safe primes for the key generation of
+// attesters and stores them in a safe prime pool (see
+// credentials.SafePrimePool). The pool contains the factors of future private
+// keys and must be kept secret.
+//
+// Usage:
+//
+//	primepool -file primes.json -key-length 2048 -count 20
+//
+// The primes are stored as soon as they are found, the command can be
+// interrupted and continued at any time.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+	"github.com/privacybydesign/gabi"
+)
+
+func main() {
+	file := flag.String("file", "primes.json", "file of the safe prime pool")
+	keyLength := flag.Int("key-length", 1024, "length of the keys the primes are used for (1024, 2048 or 4096)")
+	count := flag.Int("count", 10, "number of primes the pool should contain, two primes are used for every key")
+	flag.Parse()
+
+	if err := run(*file, *keyLength, *count); err != nil {
+		fmt.Fprintln(os.Stderr, "primepool:", err)
+		os.Exit(1)
+	}
+}
+
+func run(file string, keyLength, count int) error {
+	sysParams, ok := gabi.DefaultSystemParameters[keyLength]
+	if !ok {
+		return fmt.Errorf("invalid key length %d", keyLength)
+	}
+	pool, err := credentials.OpenSafePrimePool(file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s contains %d primes for keys of %d bits\n", file, pool.Len(sysParams), keyLength)
+
+	// stop on interrupt, the primes which were found are already stored
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	start, printed := time.Now(), time.Time{}
+	err = pool.Fill(ctx, sysParams, count, func(progress credentials.KeyGenProgress) {
+		if time.Since(printed) < time.Second {
+			return
+		}
+		printed = time.Now()
+		fmt.Fprintf(os.Stderr, "\r%d candidates tested, %d primes found (%s)",
+			progress.Attempts, progress.Primes, time.Since(start).Round(time.Second))
+	})
+	fmt.Fprintln(os.Stderr)
+	fmt.Printf("%s contains %d primes for keys of %d bits\n", file, pool.Len(sysParams), keyLength)
+	return err
+}
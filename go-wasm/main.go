@@ -22,31 +22,44 @@ func CloseWasm(js.Value, []js.Value) interface{} {
 
 func main() {
 
-	// expose all methods to the js environment. Use callbacker to transform the
-	// return style methods to callback style methods.
+	// expose all methods to the js environment. Use promiser to transform the
+	// return style methods to methods which return a promise.
 	methods := make(map[string]js.Func)
-	methods["genKeypair"] = js.FuncOf(wasm.Callbacker(wasm.GenKeypair))
-	methods["createAccumulator"] = js.FuncOf(wasm.Callbacker(wasm.CreateAccumulator))
-	methods["startAttestationSession"] = js.FuncOf(wasm.Callbacker(wasm.StartAttestationSession))
-	methods["issueAttestation"] = js.FuncOf(wasm.Callbacker(wasm.IssueAttestation))
-	methods["revokeAttestation"] = js.FuncOf(wasm.Callbacker(wasm.RevokeAttestation))
-
-	methods["genKey"] = js.FuncOf(wasm.Callbacker(wasm.GenKey))
-	methods["keyFromSeed"] = js.FuncOf(wasm.Callbacker(wasm.KeyFromSeed))
-	methods["requestAttestation"] = js.FuncOf(wasm.Callbacker(wasm.RequestAttestation))
-	methods["buildCredential"] = js.FuncOf(wasm.Callbacker(wasm.BuildCredential))
-	methods["buildPresentation"] = js.FuncOf(wasm.Callbacker(wasm.BuildPresentation))
-	methods["buildCombinedPresentation"] = js.FuncOf(wasm.Callbacker(wasm.BuildCombinedPresentation))
-	methods["updateCredential"] = js.FuncOf(wasm.Callbacker(wasm.UpdateCredential))
-	methods["updateAllCredential"] = js.FuncOf(wasm.Callbacker(wasm.UpdateAllCredential))
-
-	methods["requestPresentation"] = js.FuncOf(wasm.Callbacker(wasm.RequestPresentation))
-	methods["requestCombinedPresentation"] = js.FuncOf(wasm.Callbacker(wasm.RequestCombinedPresentation))
-	methods["verifyPresentation"] = js.FuncOf(wasm.Callbacker(wasm.VerifyPresentation))
-	methods["verifyCombinedPresentation"] = js.FuncOf(wasm.Callbacker(wasm.VerifyCombinedPresentation))
-
-	methods["getAccumulatorIndex"] = js.FuncOf(wasm.Callbacker(wasm.GetAccumulatorIndex))
-	methods["getAccumulatorTimestamp"] = js.FuncOf(wasm.Callbacker(wasm.GetAccumulatorTimestamp))
+	methods["genKeypair"] = js.FuncOf(wasm.Promiser(wasm.GenKeypair))
+	methods["createAccumulator"] = js.FuncOf(wasm.Promiser(wasm.CreateAccumulator))
+	methods["startAttestationSession"] = js.FuncOf(wasm.Promiser(wasm.StartAttestationSession))
+	methods["issueAttestation"] = js.FuncOf(wasm.Promiser(wasm.IssueAttestation))
+	methods["revokeAttestation"] = js.FuncOf(wasm.Promiser(wasm.RevokeAttestation))
+	methods["verifyPublicKey"] = js.FuncOf(wasm.Promiser(wasm.VerifyPublicKey))
+
+	methods["genKey"] = js.FuncOf(wasm.Promiser(wasm.GenKey))
+	methods["keyFromSeed"] = js.FuncOf(wasm.Promiser(wasm.KeyFromSeed))
+	methods["requestAttestation"] = js.FuncOf(wasm.Promiser(wasm.RequestAttestation))
+	methods["buildCredential"] = js.FuncOf(wasm.Promiser(wasm.BuildCredential))
+	methods["buildPresentation"] = js.FuncOf(wasm.Promiser(wasm.BuildPresentation))
+	methods["buildCombinedPresentation"] = js.FuncOf(wasm.Promiser(wasm.BuildCombinedPresentation))
+	methods["updateCredential"] = js.FuncOf(wasm.Promiser(wasm.UpdateCredential))
+	methods["updateAllCredential"] = js.FuncOf(wasm.Promiser(wasm.UpdateAllCredential))
+
+	methods["requestPresentation"] = js.FuncOf(wasm.Promiser(wasm.RequestPresentation))
+	methods["requestCombinedPresentation"] = js.FuncOf(wasm.Promiser(wasm.RequestCombinedPresentation))
+	methods["verifyPresentation"] = js.FuncOf(wasm.Promiser(wasm.VerifyPresentation))
+	methods["verifyCombinedPresentation"] = js.FuncOf(wasm.Promiser(wasm.VerifyCombinedPresentation))
+	methods["verifyTrustedPresentation"] = js.FuncOf(wasm.Promiser(wasm.VerifyTrustedPresentation))
+
+	methods["createWallet"] = js.FuncOf(wasm.Promiser(wasm.CreateWallet))
+	methods["openWallet"] = js.FuncOf(wasm.Promiser(wasm.OpenWallet))
+	methods["addWalletCredential"] = js.FuncOf(wasm.Promiser(wasm.AddWalletCredential))
+	methods["removeWalletCredential"] = js.FuncOf(wasm.Promiser(wasm.RemoveWalletCredential))
+	methods["updateWalletCredential"] = js.FuncOf(wasm.Promiser(wasm.UpdateWalletCredential))
+
+	methods["encodeBinary"] = js.FuncOf(wasm.Promiser(wasm.EncodeBinary))
+	methods["decodeBinary"] = js.FuncOf(wasm.Promiser(wasm.DecodeBinary))
+	methods["encodeFrames"] = js.FuncOf(wasm.Promiser(wasm.EncodeFrames))
+	methods["decodeFrames"] = js.FuncOf(wasm.Promiser(wasm.DecodeFrames))
+
+	methods["getAccumulatorIndex"] = js.FuncOf(wasm.Promiser(wasm.GetAccumulatorIndex))
+	methods["getAccumulatorTimestamp"] = js.FuncOf(wasm.Promiser(wasm.GetAccumulatorTimestamp))
 	methods["closeWasm"] = js.FuncOf(CloseWasm)
 
 	for k, v := range methods {
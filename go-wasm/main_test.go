@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -24,8 +25,8 @@ const (
 
 var (
 	letterRunes     = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-	attesterPrivKey = []byte(`{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":1578666344,"P":"82RNoU5p0nbTJofyadbTNA+NgLFnb7TrH8rJAwvay+JSvatXsh+bGtYF5petC5xASH8W+8W4sofDNKdAr80Zmw==","Q":"8yy+AX0L3wBSkn2KAJ85qWh7NbMYYksJ39ESqADOummuWd1JsNQOBrO0JrnLQ6hPhWVGRXo/GmM62kWNhZIjzw==","PPrime":"ebIm0Kc06Ttpk0P5NOtpmgfGwFizt9p1j+VkgYXtZfEpXtWr2Q/NjWsC80vWhc4gJD+LfeLcWUPhmlOgV+aMzQ==","QPrime":"eZZfAL6F74ApST7FAE+c1LQ9mtmMMSWE7+iJVABnXTTXLO6k2GoHA1naE1zlodQnwrKjIr0fjTGdbSLGwskR5w==","ECDSA":"MHcCAQEEIIeTtwTR0LbVtIczxUcohFY4fA17Bj5XFGFRZw5sFt8+oAoGCCqGSM49AwEHoUQDQgAEWD6TIb8Eb7noNKT87W1DiiGiXDxD7AdpYzCeuiXqnMmSF56d2S0M6+XG6zXoARHXgFnN0+H+9fpcpzgwk9KiZQ=="}`)
-	attesterPubKey  = []byte(`{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":1578666344,"N":"5zK/k1ENNgaW0NXjQmWO/v0ODej1H6coPAGNsRbZeAY3LzAfhIEcc31+GYI7LXXZJivomxLs2rVdZ8hL6bOwb6CMDBfhbHhT+v+E+EnNV9qw68ocyrcw4cIx3kMvBXIeOni8lLeuC5ZQ981rvOeBjkxiWSVApvnMEIbH/FK95VU=","Z":"r2Zy+gpFJ44pOTvdjiYbKGYqZAj79JxV0+zFdOj6ZfXRAOa+KOeRBgRDRv/G+8BL0q6+qcgi2xLWmWRKnEI6vrUvkH/+G4Ta7sN1fejDI7MHP4NJavmU6ODs4PUBei9bfJDLAG5Tpe+NPj0VaC61hskyNIRBOjTRTvB48IXbcHY=","S":"QJaP9Yv3RhT6FjHtvPrdx5nAOzxSsiN3G6BxRpprjc35wod7fRtx5tqAlK2SWVeD7M3fq1K/04hZH7CYjgKb3ymn4o0qX+tgTXnNo+u1eCRabfmbdGXisU/lR5z+nllAIY0ENtcTKq0dlmV4jxjPQapw4DnWWCAPSiqeHxqLnE8=","G":"HoVUm3Jjhmn6c4qZQwVrOnw0hy3kTcZF9MXqXSxFeZn/Z6yMZBNk3YY+n8+mqyeRyMTEIq3Ns9SqNKJHvjxztXVxCAE4O2ARjJIgl6pYm0W6h3z9LHhm3NsbBiCFLdNzrFdf8v/EnMBhrf6MiOJ8iJcLU0K8BKoMFuFZtCI6sRw=","H":"1XtFjBT9Tjute9xYivSPf1bAbFlW+HyLvbajEKCWMuSt9QddeKLo63ql5gXS4QCCcC1CMQb8BdoRmuQeYfDIPnfw/cID20+nAmRPJRo6SVnbqTpu70hD+HFEOXSRqtXW7Epfq/7LDKUyuY6R0/s4OKQ4dPsq6SGtmq7UF0JKGoU=","T":"xhJUsnEzwcCexYXFt2xGWIZjCOEQru0rkJk2R2D202ikZajvjZ+/fd26utqV5EUNz2WxroNt6GResjtDsxaNyjRNCVqdB+ykJiRapKTzvP863CLMsFLWZBJa2/Oh3Z7510ARBlfGTaeMT22UTLuJ3Hk8wKzQmFN/K0na8gn/tzs=","R":["5OREh3OP7eqXU49ohCC4cfjGz+SkGfmDQGTN/NJjqK62f/ryyb3GaD9pulSSeq+WE0bAX3Q0Slje0yprWz90ptjK06SKo+IFZvssoIu/kNmi/BT0HQ5+to+91pOePwa8Xtn9K/7Tq82rv2o3UMzUMms8zR0QxrxiLt6I8ctuCso=","IqZnglVClf5B96J0VFMxz/ZNAICV1iyHfunvHUZEqnk5DG4lf06O9S8I+O7GP2vLcorJ5BWVYZUgeFU1HRP8TlQUZeNzvnrlfnV/QE20SwBO4yFK8SscbYfiBrn5XfUP94gpgrv0nQlsfJLeHEA2RYxeQPMskQU1FkR0q7ryUzA=","xwfzpphfDWwVDLS5I3+olFmZgyYAfUitBRCxrDHBzBJITVbke6SRwe6wxmvWYQEJGoxrKwsiAs8hg+MjvOeF6uLHz0qzhYKNJonL73Q2ms9ugb38jL4E5iY7MJpz5HkKGHYusJJThVURpP+SZa6ub/QvcS3asjtxaS0yzOcV8kY=","m9ASIa4oAfv63KP94GiCVG68SMa5PWQ3pfduzGTn8XlxA8RlKm9zj8efhzSXpXOnvmX3CR8KklzDqyXVwghgjWnKUolMTXU1i3dQgfnqZPUV/8gFR3SaVYjghie+AhdP3Rwma23BG9i+57Q6jmJmSJyurzVNNL6jbwCXMpmM+6o=","MfNZ2aN816Fc2GtlEy6mZm+uRjZwd49aLgyyYIVkX/tFmRhgHxOMKgBi7TOskhSZJnwhkpNZ3DvgzU8INcw93Z+1+qbQISseXWUB5anVPz0PvgSucH7/CR3gskPhK9QR8Fk/ewXpjA6YmDabBjVG9IK6T3o/8bSHeBmdYeY/+rs=","1Fwpi4Vd4ixSzZFvx89vtXJLe5WvnDuDEH1TCWOf3e2C98ZBAmICs+EWrunjv/wgCshaSXaljEjTVlD57HgXn6xVJ3uwpJKyyqRJ2iFZM1WS9slO5q3fOYY2uYsY8cgQIoRYMJxL3OHWFpA0u6UY3/bnDYmBXcVXl1U/g3D8YXc=","fpVblrzBLW/WAa2pLNyM5t8iyMy3ktW7fOXWAPXNtm3gfBqHWoogFgMoI6NgfxvdMQ19YXbS6VIZWziOikw7wCLSEhTaR6P5gK2FxOAbWTzee3rZkRbDYW5dDKJXlGUaZLbxfrd7Sz2tzPIQ6yuz0EwJNprR9y96zt+WkhDrtxA=","kLr1qew8lqXMqNX+5KBvLrn4Ot6dj7soUHOXod1A4qdv9261Q6nEQ5WZNxEr7yUjZl1g3VGOhhZlwUO+8CG7pPe70fKUpj/DohSfAnOfJ0mcScl5QZpnRJmD7Okp3DagPTu1HKE76vdniYPCeNfkurUYXypalNt+xklBWd491nM=","UekNkoT+gfrsK5Z+qabHRIfVHhuU6owO3X0ipGZWVxDTVc9Tgt2+Ms94r62sE9GmJDRMXPkptg56LHf+wxz3x+v9lUmBw3hT6XgXIg2yxHpJwntsiFV/Uibk8Ya2+K3YS93GsKBO3Z173TVl2uhwtejWTyX7MT7fBj2hj9k/mzI=","mDAETKs0AHc7mwYxXFRbdPxpKdfnuCJbIXtp7t9JK1Cd5atVdOZTY3HZrV2J1z0Wasuqrh4KNsdazpniKA++D39fDxm6jnT5A5obXAM/hrznH9Myna7cHZoxAGKKuOtOX2pTfqGLZn1zc8Xeki4/FfmUWm8/bQ2cXIIZaB0ORDA="],"EpochLength":432000,"Params":{"LePrime":120,"Lh":256,"Lm":256,"Ln":1024,"Lstatzk":80,"Le":597,"LeCommit":456,"LmCommit":592,"LRA":1104,"LsCommit":593,"Lv":1700,"LvCommit":2036,"LvPrime":1104,"LvPrimeCommit":1440},"Issuer":"","ECDSA":"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEWD6TIb8Eb7noNKT87W1DiiGiXDxD7AdpYzCeuiXqnMmSF56d2S0M6+XG6zXoARHXgFnN0+H+9fpcpzgwk9KiZQ=="}`)
+	attesterPrivKey = []byte(`{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":4102444800,"P":"82RNoU5p0nbTJofyadbTNA+NgLFnb7TrH8rJAwvay+JSvatXsh+bGtYF5petC5xASH8W+8W4sofDNKdAr80Zmw==","Q":"8yy+AX0L3wBSkn2KAJ85qWh7NbMYYksJ39ESqADOummuWd1JsNQOBrO0JrnLQ6hPhWVGRXo/GmM62kWNhZIjzw==","PPrime":"ebIm0Kc06Ttpk0P5NOtpmgfGwFizt9p1j+VkgYXtZfEpXtWr2Q/NjWsC80vWhc4gJD+LfeLcWUPhmlOgV+aMzQ==","QPrime":"eZZfAL6F74ApST7FAE+c1LQ9mtmMMSWE7+iJVABnXTTXLO6k2GoHA1naE1zlodQnwrKjIr0fjTGdbSLGwskR5w==","ECDSA":"MHcCAQEEIIeTtwTR0LbVtIczxUcohFY4fA17Bj5XFGFRZw5sFt8+oAoGCCqGSM49AwEHoUQDQgAEWD6TIb8Eb7noNKT87W1DiiGiXDxD7AdpYzCeuiXqnMmSF56d2S0M6+XG6zXoARHXgFnN0+H+9fpcpzgwk9KiZQ=="}`)
+	attesterPubKey  = []byte(`{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":4102444800,"N":"5zK/k1ENNgaW0NXjQmWO/v0ODej1H6coPAGNsRbZeAY3LzAfhIEcc31+GYI7LXXZJivomxLs2rVdZ8hL6bOwb6CMDBfhbHhT+v+E+EnNV9qw68ocyrcw4cIx3kMvBXIeOni8lLeuC5ZQ981rvOeBjkxiWSVApvnMEIbH/FK95VU=","Z":"r2Zy+gpFJ44pOTvdjiYbKGYqZAj79JxV0+zFdOj6ZfXRAOa+KOeRBgRDRv/G+8BL0q6+qcgi2xLWmWRKnEI6vrUvkH/+G4Ta7sN1fejDI7MHP4NJavmU6ODs4PUBei9bfJDLAG5Tpe+NPj0VaC61hskyNIRBOjTRTvB48IXbcHY=","S":"QJaP9Yv3RhT6FjHtvPrdx5nAOzxSsiN3G6BxRpprjc35wod7fRtx5tqAlK2SWVeD7M3fq1K/04hZH7CYjgKb3ymn4o0qX+tgTXnNo+u1eCRabfmbdGXisU/lR5z+nllAIY0ENtcTKq0dlmV4jxjPQapw4DnWWCAPSiqeHxqLnE8=","G":"HoVUm3Jjhmn6c4qZQwVrOnw0hy3kTcZF9MXqXSxFeZn/Z6yMZBNk3YY+n8+mqyeRyMTEIq3Ns9SqNKJHvjxztXVxCAE4O2ARjJIgl6pYm0W6h3z9LHhm3NsbBiCFLdNzrFdf8v/EnMBhrf6MiOJ8iJcLU0K8BKoMFuFZtCI6sRw=","H":"1XtFjBT9Tjute9xYivSPf1bAbFlW+HyLvbajEKCWMuSt9QddeKLo63ql5gXS4QCCcC1CMQb8BdoRmuQeYfDIPnfw/cID20+nAmRPJRo6SVnbqTpu70hD+HFEOXSRqtXW7Epfq/7LDKUyuY6R0/s4OKQ4dPsq6SGtmq7UF0JKGoU=","T":"xhJUsnEzwcCexYXFt2xGWIZjCOEQru0rkJk2R2D202ikZajvjZ+/fd26utqV5EUNz2WxroNt6GResjtDsxaNyjRNCVqdB+ykJiRapKTzvP863CLMsFLWZBJa2/Oh3Z7510ARBlfGTaeMT22UTLuJ3Hk8wKzQmFN/K0na8gn/tzs=","R":["5OREh3OP7eqXU49ohCC4cfjGz+SkGfmDQGTN/NJjqK62f/ryyb3GaD9pulSSeq+WE0bAX3Q0Slje0yprWz90ptjK06SKo+IFZvssoIu/kNmi/BT0HQ5+to+91pOePwa8Xtn9K/7Tq82rv2o3UMzUMms8zR0QxrxiLt6I8ctuCso=","IqZnglVClf5B96J0VFMxz/ZNAICV1iyHfunvHUZEqnk5DG4lf06O9S8I+O7GP2vLcorJ5BWVYZUgeFU1HRP8TlQUZeNzvnrlfnV/QE20SwBO4yFK8SscbYfiBrn5XfUP94gpgrv0nQlsfJLeHEA2RYxeQPMskQU1FkR0q7ryUzA=","xwfzpphfDWwVDLS5I3+olFmZgyYAfUitBRCxrDHBzBJITVbke6SRwe6wxmvWYQEJGoxrKwsiAs8hg+MjvOeF6uLHz0qzhYKNJonL73Q2ms9ugb38jL4E5iY7MJpz5HkKGHYusJJThVURpP+SZa6ub/QvcS3asjtxaS0yzOcV8kY=","m9ASIa4oAfv63KP94GiCVG68SMa5PWQ3pfduzGTn8XlxA8RlKm9zj8efhzSXpXOnvmX3CR8KklzDqyXVwghgjWnKUolMTXU1i3dQgfnqZPUV/8gFR3SaVYjghie+AhdP3Rwma23BG9i+57Q6jmJmSJyurzVNNL6jbwCXMpmM+6o=","MfNZ2aN816Fc2GtlEy6mZm+uRjZwd49aLgyyYIVkX/tFmRhgHxOMKgBi7TOskhSZJnwhkpNZ3DvgzU8INcw93Z+1+qbQISseXWUB5anVPz0PvgSucH7/CR3gskPhK9QR8Fk/ewXpjA6YmDabBjVG9IK6T3o/8bSHeBmdYeY/+rs=","1Fwpi4Vd4ixSzZFvx89vtXJLe5WvnDuDEH1TCWOf3e2C98ZBAmICs+EWrunjv/wgCshaSXaljEjTVlD57HgXn6xVJ3uwpJKyyqRJ2iFZM1WS9slO5q3fOYY2uYsY8cgQIoRYMJxL3OHWFpA0u6UY3/bnDYmBXcVXl1U/g3D8YXc=","fpVblrzBLW/WAa2pLNyM5t8iyMy3ktW7fOXWAPXNtm3gfBqHWoogFgMoI6NgfxvdMQ19YXbS6VIZWziOikw7wCLSEhTaR6P5gK2FxOAbWTzee3rZkRbDYW5dDKJXlGUaZLbxfrd7Sz2tzPIQ6yuz0EwJNprR9y96zt+WkhDrtxA=","kLr1qew8lqXMqNX+5KBvLrn4Ot6dj7soUHOXod1A4qdv9261Q6nEQ5WZNxEr7yUjZl1g3VGOhhZlwUO+8CG7pPe70fKUpj/DohSfAnOfJ0mcScl5QZpnRJmD7Okp3DagPTu1HKE76vdniYPCeNfkurUYXypalNt+xklBWd491nM=","UekNkoT+gfrsK5Z+qabHRIfVHhuU6owO3X0ipGZWVxDTVc9Tgt2+Ms94r62sE9GmJDRMXPkptg56LHf+wxz3x+v9lUmBw3hT6XgXIg2yxHpJwntsiFV/Uibk8Ya2+K3YS93GsKBO3Z173TVl2uhwtejWTyX7MT7fBj2hj9k/mzI=","mDAETKs0AHc7mwYxXFRbdPxpKdfnuCJbIXtp7t9JK1Cd5atVdOZTY3HZrV2J1z0Wasuqrh4KNsdazpniKA++D39fDxm6jnT5A5obXAM/hrznH9Myna7cHZoxAGKKuOtOX2pTfqGLZn1zc8Xeki4/FfmUWm8/bQ2cXIIZaB0ORDA="],"EpochLength":432000,"Params":{"LePrime":120,"Lh":256,"Lm":256,"Ln":1024,"Lstatzk":80,"Le":597,"LeCommit":456,"LmCommit":592,"LRA":1104,"LsCommit":593,"Lv":1700,"LvCommit":2036,"LvPrime":1104,"LvPrimeCommit":1440},"Issuer":"","ECDSA":"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEWD6TIb8Eb7noNKT87W1DiiGiXDxD7AdpYzCeuiXqnMmSF56d2S0M6+XG6zXoARHXgFnN0+H+9fpcpzgwk9KiZQ=="}`)
 
 	earlier = time.Now().Add(time.Duration(-OneYear))
 	future  = time.Now().Add(time.Duration(OneYear))
@@ -52,7 +53,8 @@ func buildCredential(t *testing.T, sysParams *gabi.SystemParameters, attester *c
 		},
 	}
 
-	attributes := claim.ToAttributes()
+	attributes, err := claim.ToAttributes()
+	require.NoError(t, err)
 	require.Equal(t, 5, len(attributes))
 
 	attesterSession, startSignMsg, err := attester.InitiateAttestation()
@@ -77,7 +79,8 @@ func verify(t *testing.T, attester *credentials.Attester, claimer *credentials.C
 		"contents" + credentials.Separator + "special",
 		"contents" + credentials.Separator + "likedNumbers",
 	}
-	verifierSession, reqAttrMsg := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now())
+	verifierSession, reqAttrMsg, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now(), false)
+	require.NoError(t, err)
 	disclosedAttr, err := claimer.BuildPresentation(attester.PublicKey, cred, reqAttrMsg)
 	require.NoError(t, err, "Could not disclose attributes")
 
@@ -167,7 +170,7 @@ func TestCombinedPresentation(t *testing.T) {
 	update1, err := attester1.CreateAccumulator()
 	require.NoError(t, err, "Could not create update")
 
-	attester2, err := credentials.NewAttester(sysParams, 6, OneYear)
+	attester2, err := newAttester(sysParams, 6, OneYear)
 	gabi.GenerateRevocationKeypair(attester2.PrivateKey, attester2.PublicKey)
 	require.NoError(t, err, "Error in attester key generation")
 	update2, err := attester2.CreateAccumulator()
@@ -200,8 +203,9 @@ func TestCombinedPresentation(t *testing.T) {
 			ReqUpdatedAfter:       future,
 		},
 	}
-	verifierSession, reqAttrMsg := credentials.RequestCombinedPresentation(attester1.PublicKey.Params,
-		requestPresentation[:])
+	verifierSession, reqAttrMsg, err := credentials.RequestCombinedPresentation(attester1.PublicKey.Params,
+		requestPresentation[:], false)
+	require.NoError(t, err)
 	require.NotNil(t, verifierSession)
 	require.NotNil(t, reqAttrMsg)
 
@@ -268,7 +272,8 @@ func TestBigCredential(t *testing.T) {
 		},
 	}
 
-	attributes := claim.ToAttributes()
+	attributes, err := claim.ToAttributes()
+	require.NoError(t, err)
 	require.Equal(t, len(attributes), 5, "Expected 6 attributes")
 
 	attesterSession, startSignMsg, err := attester.InitiateAttestation()
@@ -285,8 +290,9 @@ func TestBigCredential(t *testing.T) {
 	require.NoError(t, err, "Could not request attributes")
 
 	requestedAttr := [2]string{"ctype", "contents" + credentials.Separator + "name"}
-	verifierSession, reqAttrMsg := credentials.RequestPresentation(sysParams, requestedAttr[:],
-		true, future)
+	verifierSession, reqAttrMsg, err := credentials.RequestPresentation(sysParams, requestedAttr[:],
+		true, future, false)
+	require.NoError(t, err)
 	disclosedAttr, err := user.BuildPresentation(attester.PublicKey, cred, reqAttrMsg)
 	require.NoError(t, err, "Could not disclose attributes")
 	require.NotNil(t, verifierSession, "Session must not be nil")
@@ -307,12 +313,23 @@ func TestBigCredential(t *testing.T) {
 	require.Nil(t, contents["special"], "special was unwillingly disclosed")
 }
 
+// newAttester creates an attester without the proof of its public key, which
+// takes several minutes.
+func newAttester(sysParams *gabi.SystemParameters, attributeCount int, periodOfValidity int64) (*credentials.Attester, error) {
+	expiryDate := time.Now().Add(time.Duration(periodOfValidity))
+	privK, pubK, err := gabi.GenerateKeyPair(sysParams, attributeCount, 0, expiryDate)
+	if err != nil {
+		return nil, err
+	}
+	return &credentials.Attester{PrivateKey: privK, PublicKey: pubK}, nil
+}
+
 // used to print a new set of json objects
 func TestFullWorkflow(t *testing.T) {
 	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
 	require.True(t, success, "Error in sysparams")
 
-	attester, err := credentials.NewAttester(sysParams, 6, OneYear)
+	attester, err := newAttester(sysParams, 6, OneYear)
 	gabi.GenerateRevocationKeypair(attester.PrivateKey, attester.PublicKey)
 	require.NoError(t, err, "Error in attester key generation")
 
@@ -347,7 +364,8 @@ func TestFullWorkflow(t *testing.T) {
 		},
 	}
 
-	attributes := claim.ToAttributes()
+	attributes, err := claim.ToAttributes()
+	require.NoError(t, err)
 	require.Equal(t, len(attributes), 5)
 
 	attesterSession, startSignMsg, err := attester.InitiateAttestation()
@@ -397,7 +415,8 @@ func TestFullWorkflow(t *testing.T) {
 		"contents" + credentials.Separator + "special",
 		"contents" + credentials.Separator + "gender",
 	}
-	verifierSession, reqAttrMsg := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, future)
+	verifierSession, reqAttrMsg, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, future, false)
+	require.NoError(t, err)
 	bts, err = json.Marshal(verifierSession)
 	require.NoError(t, err)
 	fmt.Printf("byteVerifierSession = []byte(`%s`)\n", string(bts))
@@ -443,7 +462,7 @@ func TestFullWorkflow(t *testing.T) {
 		"contents" + credentials.Separator + "name",
 		"contents" + credentials.Separator + "likedNumbers",
 	}
-	combVerifierSession, reqCombAttr := credentials.RequestCombinedPresentation(attester.PublicKey.Params, []credentials.PartialPresentationRequest{
+	combVerifierSession, reqCombAttr, err := credentials.RequestCombinedPresentation(attester.PublicKey.Params, []credentials.PartialPresentationRequest{
 		credentials.PartialPresentationRequest{
 			RequestedAttributes:   requestedAttr[:],
 			ReqNonRevocationProof: true,
@@ -454,7 +473,8 @@ func TestFullWorkflow(t *testing.T) {
 			ReqNonRevocationProof: true,
 			ReqUpdatedAfter:       future,
 		},
-	})
+	}, false)
+	require.NoError(t, err)
 	bts, err = json.Marshal(combVerifierSession)
 	require.NoError(t, err)
 	fmt.Printf("byteCombVerifierSession = []byte(`%s`)\n", string(bts))
@@ -526,8 +546,10 @@ func TestMixingVerificationSessions(t *testing.T) {
 		"contents" + credentials.Separator + "special",
 		"contents" + credentials.Separator + "likedNumbers",
 	}
-	_, reqAttrMsg1 := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, future)
-	verifierSession2, _ := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, future)
+	_, reqAttrMsg1, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, future, false)
+	require.NoError(t, err)
+	verifierSession2, _, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, future, false)
+	require.NoError(t, err)
 	require.NotEqual(t, reqAttrMsg1.Nonce, verifierSession2.Nonce)
 
 	disclosedAttr, err := claimer.BuildPresentation(attester.PublicKey, cred, reqAttrMsg1)
@@ -535,7 +557,7 @@ func TestMixingVerificationSessions(t *testing.T) {
 
 	verified, presentation, err := credentials.VerifyPresentation(attester.PublicKey,
 		update.SignedAccumulator, disclosedAttr, verifierSession2)
-	require.NoError(t, err)
+	require.True(t, errors.Is(err, credentials.ErrProofInvalid))
 	require.Nil(t, presentation)
 	require.False(t, verified)
 }
@@ -557,7 +579,7 @@ func TestForgedCombinedPresentation(t *testing.T) {
 	update1, err := attester1.CreateAccumulator()
 	require.NoError(t, err, "Could not create update")
 
-	attester2, err := credentials.NewAttester(sysParams, 6, OneYear)
+	attester2, err := newAttester(sysParams, 6, OneYear)
 	gabi.GenerateRevocationKeypair(attester2.PrivateKey, attester2.PublicKey)
 	require.NoError(t, err, "Error in attester key generation")
 	update2, err := attester2.CreateAccumulator()
@@ -591,7 +613,8 @@ func TestForgedCombinedPresentation(t *testing.T) {
 		},
 	}
 	// combined request
-	verifierSession, reqAttrMsg := credentials.RequestCombinedPresentation(attester1.PublicKey.Params, requestPresentation[:])
+	verifierSession, reqAttrMsg, err := credentials.RequestCombinedPresentation(attester1.PublicKey.Params, requestPresentation[:], false)
+	require.NoError(t, err)
 	require.NotNil(t, verifierSession)
 	require.NotNil(t, reqAttrMsg)
 
@@ -631,6 +654,7 @@ func TestForgedCombinedPresentation(t *testing.T) {
 			update1.SignedAccumulator,
 			update2.SignedAccumulator,
 		}, combinedPresentation, verifierSession)
+	require.True(t, errors.Is(err, credentials.ErrProofInvalid))
 	require.False(t, verified)
 	require.Nil(t, disclosedPresentations)
 }
@@ -666,8 +690,10 @@ func TestPresentForgedAttributes(t *testing.T) {
 		"contents" + credentials.Separator + "special",
 		"contents" + credentials.Separator + "likedNumbers",
 	}
-	_, reqAttrMsg1 := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now())
-	verifierSession2, _ := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now())
+	_, reqAttrMsg1, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now(), false)
+	require.NoError(t, err)
+	verifierSession2, _, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now(), false)
+	require.NoError(t, err)
 	require.NotEqual(t, reqAttrMsg1.Nonce, verifierSession2.Nonce)
 
 	disclosedAttr, err := claimer.BuildPresentation(attester.PublicKey, cred, reqAttrMsg1)
@@ -675,7 +701,7 @@ func TestPresentForgedAttributes(t *testing.T) {
 
 	verified, presentation, err := credentials.VerifyPresentation(attester.PublicKey,
 		update.SignedAccumulator, disclosedAttr, verifierSession2)
-	require.NoError(t, err)
+	require.True(t, errors.Is(err, credentials.ErrProofInvalid))
 	require.Nil(t, presentation)
 	require.False(t, verified)
 }
@@ -711,8 +737,10 @@ func TestPresentRequestNonexistent(t *testing.T) {
 		"contents" + credentials.Separator + "specifghfghal",
 		"contents" + credentials.Separator + "likedNumbers",
 	}
-	_, reqAttrMsg1 := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now())
-	verifierSession2, _ := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now())
+	_, reqAttrMsg1, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now(), false)
+	require.NoError(t, err)
+	verifierSession2, _, err := credentials.RequestPresentation(attester.PublicKey.Params, requestedAttr[:], true, time.Now(), false)
+	require.NoError(t, err)
 	require.NotEqual(t, reqAttrMsg1.Nonce, verifierSession2.Nonce)
 
 	disclosedAttr, err := claimer.BuildPresentation(attester.PublicKey, cred, reqAttrMsg1)
@@ -0,0 +1,148 @@
+// Package bip39 implements mnemonic phrases as specified in BIP39 and the seed
+// derivation used by substrate based chains.
+//
+// Substrate does not use the BIP39 seed derivation. Instead of the mnemonic
+// phrase, the entropy encoded by the phrase is fed into PBKDF2:
+//
+//	seed = PBKDF2-HMAC-SHA512(entropy, "mnemonic" + password, 2048 iterations, 64 bytes)
+//
+// The first 32 bytes of the seed are the so called mini secret. This is the
+// same derivation as mnemonicToMiniSecret of the polkadot js libraries, which
+// allows to restore the same key on every platform.
+package bip39
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// SeedLength is the length of the seed returned by Seed in bytes.
+	SeedLength = 64
+	// MiniSecretLength is the length of the mini secret in bytes.
+	MiniSecretLength = 32
+
+	pbkdf2Iterations = 2048
+	bitsPerWord      = 11
+)
+
+var (
+	wordList  = strings.Fields(english)
+	wordIndex = func() map[string]int {
+		index := make(map[string]int, len(wordList))
+		for i, w := range wordList {
+			index[w] = i
+		}
+		return index
+	}()
+)
+
+// NewEntropy returns random entropy of the given bit size. The size must be a
+// multiple of 32 between 128 and 256.
+func NewEntropy(bitSize int) ([]byte, error) {
+	if err := checkEntropySize(bitSize); err != nil {
+		return nil, err
+	}
+	entropy := make([]byte, bitSize/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewMnemonic encodes the entropy as a mnemonic phrase.
+func NewMnemonic(entropy []byte) (string, error) {
+	bitSize := len(entropy) * 8
+	if err := checkEntropySize(bitSize); err != nil {
+		return "", err
+	}
+	checksumSize := bitSize / 32
+	hash := sha256.Sum256(entropy)
+	// append the checksum bits to the entropy, the checksum has at most 8 bits
+	data := append(append([]byte{}, entropy...), hash[0])
+
+	words := make([]string, (bitSize+checksumSize)/bitsPerWord)
+	for i := range words {
+		index := 0
+		for b := i * bitsPerWord; b < (i+1)*bitsPerWord; b++ {
+			index = index<<1 | int(data[b/8]>>(7-uint(b%8))&1)
+		}
+		words[i] = wordList[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes the mnemonic phrase and verifies its checksum.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	totalBits := len(words) * bitsPerWord
+	checksumSize := totalBits / 33
+	bitSize := totalBits - checksumSize
+	if len(words)%3 != 0 || checkEntropySize(bitSize) != nil {
+		return nil, fmt.Errorf("invalid number of words %d", len(words))
+	}
+
+	data := make([]byte, (totalBits+7)/8)
+	for i, w := range words {
+		index, ok := wordIndex[w]
+		if !ok {
+			return nil, fmt.Errorf("invalid word %q at position %d", w, i+1)
+		}
+		for j := 0; j < bitsPerWord; j++ {
+			if index>>(bitsPerWord-1-uint(j))&1 == 1 {
+				b := i*bitsPerWord + j
+				data[b/8] |= 1 << (7 - uint(b%8))
+			}
+		}
+	}
+
+	entropy := data[:bitSize/8]
+	hash := sha256.Sum256(entropy)
+	mask := byte(0xff) << (8 - uint(checksumSize))
+	if data[bitSize/8]&mask != hash[0]&mask {
+		return nil, errors.New("invalid mnemonic checksum")
+	}
+	return entropy, nil
+}
+
+// IsMnemonicValid reports whether the mnemonic consists of valid words and has
+// a valid checksum.
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// Seed derives the 64 byte substrate seed from the mnemonic and the optional
+// password.
+func Seed(mnemonic, password string) ([]byte, error) {
+	entropy, err := EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	// the password is not normalized to match the polkadot js implementation
+	salt := []byte("mnemonic" + password)
+	return pbkdf2.Key(entropy, salt, pbkdf2Iterations, SeedLength, sha512.New), nil
+}
+
+// MiniSecret derives the 32 byte mini secret from the mnemonic and the
+// optional password.
+func MiniSecret(mnemonic, password string) ([]byte, error) {
+	seed, err := Seed(mnemonic, password)
+	if err != nil {
+		return nil, err
+	}
+	return seed[:MiniSecretLength], nil
+}
+
+func checkEntropySize(bitSize int) error {
+	if bitSize%32 != 0 || bitSize < 128 || bitSize > 256 {
+		return fmt.Errorf("invalid entropy size %d, must be a multiple of 32 between 128 and 256", bitSize)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package bip39
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// test vectors of the substrate-bip39 implementation
+var vectors = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"44e9d125f037ac1d51f0a7d3649689d422c2af8b1ec8e00d71db4d7bf6d127e33f50c3d5c84fa3e5399c72d6cbbbbc4a49bf76f76d952f479d74655a2ef2d453",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"4313249608fe8ac10fd5886c92c4579007272cb77c21551ee5b8d60b780416850f1e26c1f4b8d88ece681cb058ab66d6182bc2ce5a03181f7b74c27576b5c8bf",
+	},
+}
+
+func TestWordList(t *testing.T) {
+	require.Len(t, wordList, 2048)
+	assert.Equal(t, "abandon", wordList[0])
+	assert.Equal(t, "zoo", wordList[2047])
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		entropy, err := hex.DecodeString(v.entropy)
+		require.NoError(t, err)
+
+		mnemonic, err := NewMnemonic(entropy)
+		require.NoError(t, err)
+		assert.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := EntropyFromMnemonic(mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		seed, err := Seed(mnemonic, "Substrate")
+		require.NoError(t, err)
+		assert.Equal(t, v.seed, hex.EncodeToString(seed))
+
+		miniSecret, err := MiniSecret(mnemonic, "Substrate")
+		require.NoError(t, err)
+		assert.Equal(t, seed[:MiniSecretLength], miniSecret)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{128, 160, 192, 224, 256} {
+		entropy, err := NewEntropy(size)
+		require.NoError(t, err)
+		mnemonic, err := NewMnemonic(entropy)
+		require.NoError(t, err)
+		assert.True(t, IsMnemonicValid(mnemonic))
+		decoded, err := EntropyFromMnemonic(mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, entropy, decoded)
+	}
+}
+
+func TestInvalidMnemonic(t *testing.T) {
+	// wrong checksum
+	assert.False(t, IsMnemonicValid("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"))
+	// unknown word
+	assert.False(t, IsMnemonicValid("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon portable"))
+	// wrong number of words
+	assert.False(t, IsMnemonicValid("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"))
+	assert.False(t, IsMnemonicValid(""))
+
+	_, err := NewMnemonic(make([]byte, 15))
+	assert.Error(t, err)
+	_, err = Seed("abandon abandon", "")
+	assert.Error(t, err)
+}
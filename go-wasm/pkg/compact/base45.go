@@ -0,0 +1,63 @@
+package compact
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// base45Alphabet contains the characters of the base45 encoding as specified
+// in RFC 9285. All characters can be encoded in the alphanumeric mode of QR
+// codes.
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// EncodeBase45 encodes the data using base45. Two bytes are encoded using three
+// characters, a trailing single byte is encoded using two characters.
+func EncodeBase45(data []byte) string {
+	var sb strings.Builder
+	sb.Grow((len(data)/2)*3 + 2)
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])<<8 | int(data[i+1])
+		sb.WriteByte(base45Alphabet[n%45])
+		sb.WriteByte(base45Alphabet[(n/45)%45])
+		sb.WriteByte(base45Alphabet[n/2025])
+	}
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		sb.WriteByte(base45Alphabet[n%45])
+		sb.WriteByte(base45Alphabet[n/45])
+	}
+	return sb.String()
+}
+
+// DecodeBase45 decodes a base45 encoded string.
+func DecodeBase45(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, errors.New("invalid base45 length")
+	}
+	values := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		v := strings.IndexByte(base45Alphabet, s[i])
+		if v < 0 {
+			return nil, fmt.Errorf("invalid base45 character %q at position %d", s[i], i)
+		}
+		values[i] = v
+	}
+	data := make([]byte, 0, (len(s)/3)*2+1)
+	for i := 0; i < len(values); i += 3 {
+		if i+2 < len(values) {
+			n := values[i] + values[i+1]*45 + values[i+2]*2025
+			if n > 0xffff {
+				return nil, errors.New("invalid base45 chunk")
+			}
+			data = append(data, byte(n>>8), byte(n))
+			continue
+		}
+		n := values[i] + values[i+1]*45
+		if n > 0xff {
+			return nil, errors.New("invalid base45 chunk")
+		}
+		data = append(data, byte(n))
+	}
+	return data, nil
+}
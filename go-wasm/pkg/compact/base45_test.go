@@ -0,0 +1,34 @@
+package compact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase45(t *testing.T) {
+	// test vectors from RFC 9285
+	vectors := map[string]string{
+		"AB":       "BB8",
+		"Hello!!":  "%69 VD92EX0",
+		"base-45":  "UJCLQE7W581",
+		"ietf!":    "QED8WEX0",
+		"":         "",
+		"\x00":     "00",
+		"\xff\xff": "FGW",
+	}
+	for plain, encoded := range vectors {
+		assert.Equal(t, encoded, EncodeBase45([]byte(plain)))
+		decoded, err := DecodeBase45(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, plain, string(decoded))
+	}
+}
+
+func TestBase45Invalid(t *testing.T) {
+	for _, s := range []string{"A", "BB8B", "GGW", "GGW00", "ab", "BB\x00"} {
+		_, err := DecodeBase45(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,294 @@
+// Package compact encodes presentation requests and presentation responses
+// into short text frames which can be displayed as QR codes.
+//
+// A message is encoded using the binary encoding of the credentials package
+// and optionally compressed using deflate. The result is split into chunks,
+// each chunk is encoded using base45 or base64url and prefixed with a header:
+//
+//	PG1A:<index>:<total>:<digest>:<checksum>:<data>
+//
+// "PG1" identifies the frame format and its version, the following character
+// identifies the text encoding ("A" base45, "B" base64url). The index starts at
+// 1. The digest is the hex encoded beginning of the SHA-256 hash of the whole
+// message and ties the frames of a message together. The checksum is the hex
+// encoded CRC-32 of the chunk. Base45 frames only contain characters of the
+// alphanumeric mode of QR codes.
+package compact
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+)
+
+// Encoding is the text encoding of the frames.
+type Encoding byte
+
+// The supported text encodings.
+const (
+	// Base45 can be encoded in the alphanumeric mode of QR codes.
+	Base45 Encoding = 'A'
+	// Base64URL needs the byte mode of QR codes, but produces shorter frames.
+	Base64URL Encoding = 'B'
+)
+
+const (
+	framePrefix = "PG1"
+	// MaxFrames is the maximum number of frames of a message.
+	MaxFrames = 255
+	// MaxMessageSize is the maximum size of a decompressed message in bytes.
+	MaxMessageSize = 1 << 20
+
+	flagDeflate  byte = 1
+	digestLength      = 4
+	// headerFields is the number of fields of the header, excluding the data.
+	headerFields = 5
+)
+
+// Options configure the encoding of a message.
+type Options struct {
+	// Encoding is the text encoding of the frames. Defaults to Base45.
+	Encoding Encoding
+	// Deflate enables the compression of the message.
+	Deflate bool
+	// MaxFrameLength is the maximum length of a frame including the header. If
+	// it is 0, the message is encoded into a single frame.
+	MaxFrameLength int
+}
+
+// Encode encodes the message into one or more frames. The message must be
+// supported by credentials.MarshalBinary, e.g. a *credentials.PresentationRequest,
+// *credentials.CombinedPresentationRequest or *credentials.PresentationResponse.
+func Encode(msg interface{}, opts *Options) ([]string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	encoding := opts.Encoding
+	if encoding == 0 {
+		encoding = Base45
+	}
+	if encoding != Base45 && encoding != Base64URL {
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	body, err := credentials.MarshalBinary(msg)
+	if err != nil {
+		return nil, err
+	}
+	flags := byte(0)
+	if opts.Deflate {
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+		flags |= flagDeflate
+	}
+	payload := append([]byte{flags}, body...)
+	hash := sha256.Sum256(payload)
+	digest := strings.ToUpper(hex.EncodeToString(hash[:digestLength]))
+
+	chunkSize, err := chunkSize(len(payload), encoding, opts.MaxFrameLength)
+	if err != nil {
+		return nil, err
+	}
+	total := (len(payload) + chunkSize - 1) / chunkSize
+	frames := make([]string, total)
+	for i := range frames {
+		end := (i + 1) * chunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunk := payload[i*chunkSize : end]
+		frames[i] = fmt.Sprintf("%s%c:%d:%d:%s:%08X:%s", framePrefix, encoding, i+1, total, digest,
+			crc32.ChecksumIEEE(chunk), encode(encoding, chunk))
+	}
+	return frames, nil
+}
+
+// chunkSize returns the number of bytes which fit into a single frame.
+func chunkSize(payloadLength int, encoding Encoding, maxFrameLength int) (int, error) {
+	if maxFrameLength == 0 {
+		return payloadLength, nil
+	}
+	// the length of the header depends on the number of frames
+	total := 1
+	for {
+		digits := len(strconv.Itoa(total))
+		headerLength := len(framePrefix) + 1 + 2*digits + 2*digestLength + 8 + headerFields
+		available := maxFrameLength - headerLength
+		size := available * 3 / 4
+		if encoding == Base45 {
+			size = available / 3 * 2
+		}
+		if size < 1 {
+			return 0, errors.New("maximum frame length too small")
+		}
+		newTotal := (payloadLength + size - 1) / size
+		if newTotal > MaxFrames {
+			return 0, fmt.Errorf("message needs %d frames, at most %d are supported", newTotal, MaxFrames)
+		}
+		if len(strconv.Itoa(newTotal)) <= digits {
+			return size, nil
+		}
+		total = newTotal
+	}
+}
+
+func encode(encoding Encoding, data []byte) string {
+	if encoding == Base45 {
+		return EncodeBase45(data)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(encoding Encoding, s string) ([]byte, error) {
+	if encoding == Base45 {
+		return DecodeBase45(s)
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// Collector reassembles a message from its frames. The frames can be added in
+// any order.
+type Collector struct {
+	encoding Encoding
+	digest   string
+	chunks   [][]byte
+	received int
+}
+
+// Add parses the frame and stores its chunk. Frames which were already added
+// are ignored, a frame with the same index but different content is rejected.
+// An error is returned if the frame is corrupted or belongs to a
+// different message.
+func (c *Collector) Add(frame string) error {
+	parts := strings.SplitN(frame, ":", headerFields+1)
+	if len(parts) != headerFields+1 {
+		return errors.New("invalid frame header")
+	}
+	prefix := parts[0]
+	if len(prefix) != len(framePrefix)+1 || !strings.HasPrefix(prefix, framePrefix) {
+		return fmt.Errorf("unsupported frame format %q", prefix)
+	}
+	encoding := Encoding(prefix[len(framePrefix)])
+	if encoding != Base45 && encoding != Base64URL {
+		return fmt.Errorf("unknown encoding %q", encoding)
+	}
+	index, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid frame index: %v", err)
+	}
+	total, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid number of frames: %v", err)
+	}
+	if total < 1 || total > MaxFrames || index < 1 || index > total {
+		return fmt.Errorf("invalid frame %d of %d", index, total)
+	}
+	digest := parts[3]
+	if len(digest) != 2*digestLength {
+		return errors.New("invalid message digest")
+	}
+	checksum, err := strconv.ParseUint(parts[4], 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid frame checksum: %v", err)
+	}
+	chunk, err := decode(encoding, parts[5])
+	if err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(chunk) != uint32(checksum) {
+		return fmt.Errorf("checksum of frame %d does not match", index)
+	}
+
+	if c.chunks == nil {
+		c.encoding = encoding
+		c.digest = digest
+		c.chunks = make([][]byte, total)
+	} else if encoding != c.encoding || digest != c.digest || total != len(c.chunks) {
+		return errors.New("frame belongs to a different message")
+	}
+	switch {
+	case c.chunks[index-1] == nil:
+		c.chunks[index-1] = chunk
+		c.received++
+	case !bytes.Equal(c.chunks[index-1], chunk):
+		return fmt.Errorf("frame %d was already added with different content", index)
+	}
+	return nil
+}
+
+// Progress returns the number of received frames and the total number of
+// frames. The total is 0 if no frame was added yet.
+func (c *Collector) Progress() (int, int) {
+	return c.received, len(c.chunks)
+}
+
+// Complete reports whether all frames of the message were added.
+func (c *Collector) Complete() bool {
+	return c.chunks != nil && c.received == len(c.chunks)
+}
+
+// Message verifies the digest of the reassembled message and decodes it. The
+// type of the returned value depends on the encoded message.
+func (c *Collector) Message() (interface{}, error) {
+	if !c.Complete() {
+		received, total := c.Progress()
+		return nil, fmt.Errorf("missing frames (received %d of %d)", received, total)
+	}
+	payload := bytes.Join(c.chunks, nil)
+	hash := sha256.Sum256(payload)
+	if strings.ToUpper(hex.EncodeToString(hash[:digestLength])) != c.digest {
+		return nil, errors.New("digest of the message does not match")
+	}
+	if len(payload) < 1 {
+		return nil, errors.New("empty message")
+	}
+	flags, body := payload[0], payload[1:]
+	if flags&^flagDeflate != 0 {
+		return nil, fmt.Errorf("unknown flags %x", flags)
+	}
+	if flags&flagDeflate != 0 {
+		r := flate.NewReader(bytes.NewReader(body))
+		defer r.Close()
+		inflated, err := ioutil.ReadAll(io.LimitReader(r, MaxMessageSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(inflated) > MaxMessageSize {
+			return nil, fmt.Errorf("decompressed message exceeds %d bytes", MaxMessageSize)
+		}
+		body = inflated
+	}
+	return credentials.DecodeBinary(body)
+}
+
+// Decode reassembles and decodes a message from its frames.
+func Decode(frames []string) (interface{}, error) {
+	c := &Collector{}
+	for _, frame := range frames {
+		if err := c.Add(frame); err != nil {
+			return nil, err
+		}
+	}
+	return c.Message()
+}
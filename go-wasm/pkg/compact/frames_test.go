@@ -0,0 +1,137 @@
+package compact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var byteCombPresentationRequest = []byte(`{"partialPresentationRequests":[{"requestedAttributes":["contents.age","contents.gender","contents.name","contents.special"],"reqNonRevocationProof":true,"ReqUpdatedAfter":"2020-02-17T13:19:41.585728+01:00"},{"requestedAttributes":["contents.name","contents.likedNumbers"],"reqNonRevocationProof":true,"ReqUpdatedAfter":"2020-02-17T13:19:41.585728+01:00"}],"context":"V13gZuOAhTb5tdcXZKfkJUUXOJd2hj0VLjg86petjYs=","nonce":"8i33d+JtlZIplvIl2BKFRZzLQAHKLFq85c64Y5oFtu0="}`)
+
+// assertDecodesTo decodes the frames and compares the result with the request.
+func assertDecodesTo(t *testing.T, frames []string, request *credentials.CombinedPresentationRequest) {
+	decoded, err := Decode(frames)
+	require.NoError(t, err)
+	require.IsType(t, &credentials.CombinedPresentationRequest{}, decoded)
+
+	expected, err := json.Marshal(request)
+	require.NoError(t, err)
+	actual, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestFrames(t *testing.T) {
+	request := &credentials.CombinedPresentationRequest{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationRequest, request))
+
+	for _, opts := range []*Options{
+		nil,
+		{Deflate: true},
+		{Encoding: Base64URL},
+		{Encoding: Base64URL, Deflate: true},
+	} {
+		frames, err := Encode(request, opts)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		assert.Less(t, len(frames[0]), len(byteCombPresentationRequest))
+		assertDecodesTo(t, frames, request)
+	}
+
+	frames, err := Encode(request, nil)
+	require.NoError(t, err)
+	for _, c := range frames[0] {
+		assert.Contains(t, base45Alphabet, string(c))
+	}
+}
+
+func TestFramesMultiPart(t *testing.T) {
+	request := &credentials.CombinedPresentationRequest{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationRequest, request))
+
+	for _, encoding := range []Encoding{Base45, Base64URL} {
+		frames, err := Encode(request, &Options{Encoding: encoding, MaxFrameLength: 60})
+		require.NoError(t, err)
+		require.True(t, len(frames) > 1)
+		for _, frame := range frames {
+			assert.True(t, len(frame) <= 60, frame)
+		}
+
+		// frames can be scanned in any order and more than once
+		c := &Collector{}
+		for i := len(frames) - 1; i >= 0; i-- {
+			assert.False(t, c.Complete())
+			require.NoError(t, c.Add(frames[i]))
+			require.NoError(t, c.Add(frames[i]))
+		}
+		received, total := c.Progress()
+		assert.Equal(t, len(frames), received)
+		assert.Equal(t, len(frames), total)
+		assert.True(t, c.Complete())
+		assertDecodesTo(t, frames, request)
+	}
+}
+
+func TestFramesInvalid(t *testing.T) {
+	request := &credentials.CombinedPresentationRequest{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationRequest, request))
+	frames, err := Encode(request, &Options{MaxFrameLength: 80})
+	require.NoError(t, err)
+
+	// missing frame
+	_, err = Decode(frames[1:])
+	assert.Error(t, err)
+
+	// corrupted data
+	corrupted := []byte(frames[0])
+	if corrupted[len(corrupted)-1] == '0' {
+		corrupted[len(corrupted)-1] = '1'
+	} else {
+		corrupted[len(corrupted)-1] = '0'
+	}
+	assert.Error(t, (&Collector{}).Add(string(corrupted)))
+
+	// frame of another message
+	request.Nonce = request.Context
+	otherFrames, err := Encode(request, &Options{MaxFrameLength: 80})
+	require.NoError(t, err)
+	c := &Collector{}
+	require.NoError(t, c.Add(frames[0]))
+	assert.Error(t, c.Add(otherFrames[1]))
+
+	// invalid headers
+	for _, frame := range []string{
+		"",
+		"PG1A:1:1:00000000:00000000",
+		"PG2A:1:1:00000000:00000000:00",
+		"PG1C:1:1:00000000:00000000:00",
+		"PG1A:2:1:00000000:00000000:00",
+		"PG1A:0:1:00000000:00000000:00",
+		"PG1A:1:1:0000:00000000:00",
+		"PG1A:1:1:00000000:XYZ:00",
+	} {
+		assert.Error(t, (&Collector{}).Add(frame), frame)
+	}
+
+	// valid frames with a wrong digest
+	parts := strings.SplitN(frames[0], ":", 6)
+	parts[3] = "00000000"
+	c = &Collector{}
+	require.NoError(t, c.Add(strings.Join(parts, ":")))
+	for _, frame := range frames[1:] {
+		parts := strings.SplitN(frame, ":", 6)
+		parts[3] = "00000000"
+		require.NoError(t, c.Add(strings.Join(parts, ":")))
+	}
+	_, err = c.Message()
+	assert.Error(t, err)
+
+	_, err = Encode(request, &Options{MaxFrameLength: 20})
+	assert.Error(t, err)
+	_, err = Encode(request, &Options{Encoding: 'C'})
+	assert.Error(t, err)
+}
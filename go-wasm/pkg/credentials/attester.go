@@ -1,63 +1,104 @@
 package credentials
 
 import (
+	"context"
 	"errors"
-	"time"
+	"io"
 
 	"github.com/privacybydesign/gabi"
 	"github.com/privacybydesign/gabi/big"
-	"github.com/privacybydesign/gabi/pkg/common"
+	"github.com/privacybydesign/gabi/keyproof"
 	"github.com/privacybydesign/gabi/revocation"
 )
 
 // AttesterSession contains information needed by the attester to create an
 // attestation
 type AttesterSession struct {
-	Context *big.Int `json:"context"`
-	Nonce   *big.Int `json:"nonce"`
+	Context   *big.Int `json:"context"`
+	Nonce     *big.Int `json:"nonce"`
+	SessionID *big.Int `json:"sessionId"`
+	CTypeHash string   `json:"cTypeHash,omitempty"`
 }
 
-// Attester can attest claims.
+// Attester can attest claims. The PublicKeyProof proves that the public key is
+// well formed and should be published together with the public key, claimers
+// and verifiers check it using VerifyPublicKey. It is created together with
+// the key, keys which were created otherwise can be proven using ProveKey. The
+// session ids and nonces of the attester are read from Rand, if it is nil
+// crypto/rand is used. The keys, the proof and the signatures are always
+// created using crypto/rand, since gabi does not accept another source of
+// randomness.
 type Attester struct {
-	PrivateKey *gabi.PrivateKey `json:"PrivateKey"`
-	PublicKey  *gabi.PublicKey  `json:"PublicKey"`
+	PrivateKey     *gabi.PrivateKey        `json:"PrivateKey"`
+	PublicKey      *gabi.PublicKey         `json:"PublicKey"`
+	PublicKeyProof *keyproof.ValidKeyProof `json:"PublicKeyProof,omitempty"`
+	Rand           io.Reader               `json:"-"`
 }
 
-// NewAttester creates a new key pair for an attester
+// NewAttester creates a new key pair for an attester together with the proof
+// of the public key. Creating the proof takes several minutes for larger keys.
+// The keys and the proof are created using crypto/rand, only the session ids
+// and nonces of the attester can be read from another source (see Rand).
 func NewAttester(sysParams *gabi.SystemParameters, attributeCount int, periodOfValidity int64) (*Attester, error) {
-	parsedExpiryDate := time.Now().Add(time.Duration(periodOfValidity))
-	attesterPrivK, attesterPubK, err := gabi.GenerateKeyPair(sysParams, attributeCount, 0, parsedExpiryDate)
+	return NewAttesterWithCounter(sysParams, attributeCount, periodOfValidity, 0)
+}
+
+// NewAttesterWithCounter creates a new key pair for an attester like
+// NewAttester. The counter identifies the version of the key (see
+// AttesterKeyring).
+func NewAttesterWithCounter(sysParams *gabi.SystemParameters, attributeCount int, periodOfValidity int64, counter uint) (*Attester, error) {
+	return NewAttesterContext(context.Background(), sysParams, attributeCount, periodOfValidity, counter, nil)
+}
+
+// ProveKey creates the proof of the public key and stores it as
+// PublicKeyProof, e.g. for keys which were imported or taken from a
+// SafePrimePool. The proof is aborted with the error of the context, if the
+// context is done. If progress is not nil, it is called regularly with the
+// progress of the proof.
+func (attester *Attester) ProveKey(ctx context.Context, progress ProgressFunc) error {
+	proof, err := ProvePublicKeyContext(ctx, attester.PrivateKey, attester.PublicKey, progress)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return &Attester{
-		PrivateKey: attesterPrivK,
-		PublicKey:  attesterPubK,
-	}, nil
+	attester.PublicKeyProof = proof
+	return nil
 }
 
 // InitiateAttestation starts the attestation process. It returns an
 // AttesterSession, which contains information the attester needs for creating
 // the attestation and StartSessionMsg which represents the message for the claimer
 func (attester *Attester) InitiateAttestation() (*AttesterSession, *StartSessionMsg, error) {
-	// TODO: Calculate correct context
-	context, err := common.RandomBigInt(attester.PublicKey.Params.Lh)
+	return attester.InitiateCTypeAttestation("")
+}
+
+// InitiateCTypeAttestation starts the attestation process for a claim of the
+// given ctype. The ctype hash is bound to the context of the session, claims of
+// a different ctype will not be attested in this session.
+func (attester *Attester) InitiateCTypeAttestation(cTypeHash string) (*AttesterSession, *StartSessionMsg, error) {
+	sessionID, err := randomBigInt(attester.Rand, attester.PublicKey.Params.Lh)
+	if err != nil {
+		return nil, nil, err
+	}
+	context, err := AttestationContext(attester.PublicKey, sessionID, cTypeHash)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	nonce, err := common.RandomBigInt(attester.PublicKey.Params.Lstatzk)
+	nonce, err := randomBigInt(attester.Rand, attester.PublicKey.Params.Lstatzk)
 	if err != nil {
 		return nil, nil, err
 	}
 	// send request attributes to sign
 	return &AttesterSession{
-			Context: context,
-			Nonce:   nonce,
+			Context:   context,
+			Nonce:     nonce,
+			SessionID: sessionID,
+			CTypeHash: cTypeHash,
 		}, &StartSessionMsg{
-			Context: context,
-			Nonce:   nonce,
+			Context:   context,
+			Nonce:     nonce,
+			SessionID: sessionID,
+			CTypeHash: cTypeHash,
 		}, nil
 }
 
@@ -65,12 +106,27 @@ func (attester *Attester) InitiateAttestation() (*AttesterSession, *StartSession
 // RequestAttestedClaim which was send by the claimer and an AttesterSession.
 // It returns an gabi.IssueSignatureMessage which should be sent to the claimer.
 func (attester *Attester) AttestClaim(reqCred *AttestedClaimRequest, session *AttesterSession, update *revocation.Update) (*gabi.IssueSignatureMessage, *revocation.Witness, error) {
+	if session.CTypeHash != "" && session.CTypeHash != claimCType(reqCred.Claim) {
+		return nil, nil, newError(ErrSessionMismatch, "ctype of the claim does not match the session")
+	}
 	ok := reqCred.CommitMsg.Proofs.Verify([]*gabi.PublicKey{attester.PublicKey}, session.Context, session.Nonce, false, nil)
 	if !ok {
-		return nil, nil, errors.New("commit message could not be verified")
+		return nil, nil, newError(ErrProofInvalid, "commit message could not be verified")
 	}
+	return attester.signClaim(reqCred, session, update)
+}
 
-	attributes := reqCred.Claim.ToAttributes()
+// signClaim issues the signature of the claim after the commitment of the
+// request was verified.
+func (attester *Attester) signClaim(reqCred *AttestedClaimRequest, session *AttesterSession,
+	update *revocation.Update) (*gabi.IssueSignatureMessage, *revocation.Witness, error) {
+	if err := reqCred.Encoding.validate(); err != nil {
+		return nil, nil, err
+	}
+	attributes, _, err := reqCred.Claim.ToAttributesWithParams(attester.PublicKey.Params, reqCred.Encoding)
+	if err != nil {
+		return nil, nil, err
+	}
 	marshaledAttr, err := attributesToBigInts(attributes)
 	if err != nil {
 		return nil, nil, err
@@ -2,7 +2,9 @@ package credentials
 
 import (
 	"encoding/json"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/privacybydesign/gabi"
 	"github.com/privacybydesign/gabi/revocation"
@@ -15,12 +17,44 @@ var (
 	OneYear   = (int64)(365 * 24 * 60 * 60 * 1000 * 1000 * 1000)
 )
 
-func TestNewAttester(t *testing.T) {
+// smallSysParams returns system parameters with a small modulus, so that
+// safe primes are found and keys are proven quickly.
+func smallSysParams(t *testing.T) *gabi.SystemParameters {
 	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
 	require.True(t, success)
-	attester, err := NewAttester(sysParams, 10, OneYear)
-	gabi.GenerateRevocationKeypair(attester.PrivateKey, attester.PublicKey)
+	small := *sysParams
+	small.Ln = 128
+	return &small
+}
+
+var provenAttester struct {
+	once     sync.Once
+	attester *Attester
+	err      error
+}
+
+// provenTestAttester returns an attester with a small key, which was created
+// together with the proof of its public key. Even for small keys the proof
+// takes about half a minute, it is therefore created only once.
+func provenTestAttester(t *testing.T) *Attester {
+	sysParams := smallSysParams(t)
+	provenAttester.once.Do(func() {
+		provenAttester.attester, provenAttester.err = NewAttester(sysParams, 3, OneYear)
+	})
+	require.NoError(t, provenAttester.err)
+	return provenAttester.attester
+}
+
+// newTestAttester creates an attester without the proof of its public key,
+// which takes several minutes for keys of KeyLength bits.
+func newTestAttester(t *testing.T, sysParams *gabi.SystemParameters, attributeCount int, counter uint) *Attester {
+	privK, pubK, err := gabi.GenerateKeyPair(sysParams, attributeCount, counter, time.Now().Add(time.Duration(OneYear)))
 	require.NoError(t, err)
+	return &Attester{PrivateKey: privK, PublicKey: pubK}
+}
+
+func TestNewAttester(t *testing.T) {
+	attester := provenTestAttester(t)
 	require.NotNil(t, attester)
 	require.True(t, attester.PublicKey.RevocationSupported())
 	require.True(t, attester.PrivateKey.RevocationSupported())
@@ -41,6 +75,10 @@ func TestNewAttester(t *testing.T) {
 
 	require.True(t, privateKey.RevocationSupported())
 	require.True(t, publicKey.RevocationSupported())
+
+	// the proof of the key is created together with the key
+	require.NotNil(t, attester.PublicKeyProof)
+	assert.NoError(t, VerifyPublicKey(publicKey, attester.PublicKeyProof))
 }
 
 func TestInitiateAttestation(t *testing.T) {
@@ -54,6 +92,31 @@ func TestInitiateAttestation(t *testing.T) {
 	assert.NotNil(t, message)
 	assert.Equal(t, session.Context, message.Context)
 	assert.Equal(t, session.Nonce, message.Nonce)
+	assert.Equal(t, session.SessionID, message.SessionID)
+
+	context, err := AttestationContext(attester.PublicKey, message.SessionID, "")
+	require.NoError(t, err)
+	assert.Equal(t, context, message.Context)
+}
+
+func TestInitiateCTypeAttestation(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	session, message, err := attester.InitiateCTypeAttestation("0xDEADBEEFCOFEE")
+	require.NoError(t, err)
+	assert.Equal(t, "0xDEADBEEFCOFEE", session.CTypeHash)
+	assert.Equal(t, "0xDEADBEEFCOFEE", message.CTypeHash)
+
+	context, err := AttestationContext(attester.PublicKey, message.SessionID, "0xDEADBEEFCOFEE")
+	require.NoError(t, err)
+	assert.Equal(t, context, message.Context)
+
+	// the ctype hash is bound to the context
+	context, err = AttestationContext(attester.PublicKey, message.SessionID, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, context, message.Context)
 }
 
 func TestSign(t *testing.T) {
@@ -102,6 +165,29 @@ func TestSignInvalid(t *testing.T) {
 	assert.Nil(t, witness)
 }
 
+func TestSignWrongCType(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	request := &AttestedClaimRequest{}
+	err = json.Unmarshal(byteAttestationRequest, request)
+	require.NoError(t, err)
+
+	session := &AttesterSession{}
+	err = json.Unmarshal(byteAttesterSession, session)
+	require.NoError(t, err)
+	session.CTypeHash = "0xC0FFEE"
+
+	update, err := attester.CreateAccumulator()
+	require.NoError(t, err)
+
+	attested, witness, err := attester.AttestClaim(request, session, update)
+	assert.Error(t, err)
+	assert.Nil(t, attested)
+	assert.Nil(t, witness)
+}
+
 func TestSignAndRevoke(t *testing.T) {
 	attester := &Attester{}
 	err := json.Unmarshal(byteAttester, attester)
@@ -136,3 +222,24 @@ func TestCreateAccumulator(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, update)
 }
+
+// issueTestClaim attests the claim for a new claimer using the given attester
+// and returns the claimer, the credential and the accumulator of the attester.
+func issueTestClaim(t *testing.T, attester *Attester, claim Claim) (*Claimer, *AttestedClaim, *revocation.Update) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+	claimer, err := NewClaimer(sysParams)
+	require.NoError(t, err)
+	update, err := attester.CreateAccumulator()
+	require.NoError(t, err)
+
+	attesterSession, startMsg, err := attester.InitiateAttestation()
+	require.NoError(t, err)
+	claimerSession, request, err := claimer.RequestAttestationForClaim(attester.PublicKey, startMsg, claim)
+	require.NoError(t, err)
+	sig, _, err := attester.AttestClaim(request, attesterSession, update)
+	require.NoError(t, err)
+	cred, err := claimer.BuildCredential(sig, claimerSession)
+	require.NoError(t, err)
+	return claimer, cred, update
+}
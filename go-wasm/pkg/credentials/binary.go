@@ -0,0 +1,429 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor"
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+)
+
+// BinaryVersion is the version of the binary encoding. It is the first byte of
+// every binary encoded message. Version 2 added the key ids and the preimages
+// of hashed attributes to presentations, messages of other versions are
+// rejected.
+const BinaryVersion = 2
+
+// The second byte of a binary encoded message identifies the type of the
+// encoded value.
+const (
+	binaryPublicKey byte = iota + 1
+	binaryAttestedClaim
+	binaryUpdate
+	binaryPresentationResponse
+	binaryCombinedPresentationResponse
+	binaryPresentationRequest
+	binaryCombinedPresentationRequest
+)
+
+type (
+	// binaryAttestedClaimData is the binary representation of an AttestedClaim.
+	// The claim is stored as json, since cbor would decode nested objects into
+	// maps with interface keys.
+	binaryAttestedClaimData struct {
+		Claim      []byte           `json:"claim"`
+		Credential *gabi.Credential `json:"credential"`
+		Preimages  []*Attribute     `json:"preimages,omitempty"`
+	}
+
+	// binaryProofD is the binary representation of a disclosure proof. It is
+	// encoded as an array to save the space of the field names.
+	binaryProofD struct {
+		_                  struct{} `cbor:",toarray"`
+		C                  *big.Int
+		A                  *big.Int
+		EResponse          *big.Int
+		VResponse          *big.Int
+		AResponses         map[int]*big.Int
+		ADisclosed         map[int]*big.Int
+		NonRevocationProof *revocation.Proof
+	}
+
+	// binaryPresentationResponseData is the binary representation of a
+	// PresentationResponse.
+	binaryPresentationResponseData struct {
+		_         struct{} `cbor:",toarray"`
+		Proof     *binaryProofD
+		KeyID     *big.Int
+		Preimages []*Attribute
+	}
+
+	// binaryCombinedPresentationResponseData is the binary representation of
+	// a CombinedPresentationResponse.
+	binaryCombinedPresentationResponseData struct {
+		_         struct{} `cbor:",toarray"`
+		Proofs    []*binaryProofD
+		KeyIDs    []*big.Int
+		Preimages [][]*Attribute
+	}
+
+	// binaryPartialPresentationRequest is the binary representation of a
+	// PartialPresentationRequest.
+	binaryPartialPresentationRequest struct {
+		_                     struct{} `cbor:",toarray"`
+		RequestedAttributes   []string
+		ReqNonRevocationProof bool
+		// ReqUpdatedAfter is encoded using time.Time.MarshalBinary, which keeps
+		// the time zone offset.
+		ReqUpdatedAfter  []byte
+		HashedAttributes []string
+	}
+
+	// binaryPresentationRequestData is the binary representation of a
+	// PresentationRequest and a CombinedPresentationRequest.
+	binaryPresentationRequestData struct {
+		_               struct{} `cbor:",toarray"`
+		PartialRequests []*binaryPartialPresentationRequest
+		Context         *big.Int
+		Nonce           *big.Int
+	}
+)
+
+var bigIntType = reflect.TypeOf((*big.Int)(nil))
+
+// MarshalBinary encodes a *gabi.PublicKey, *AttestedClaim, *revocation.Update,
+// *PresentationRequest, *CombinedPresentationRequest, *PresentationResponse or
+// *CombinedPresentationResponse into a compact binary format. The result
+// starts with the BinaryVersion and a byte identifying the type, followed by
+// the cbor encoded value.
+func MarshalBinary(v interface{}) ([]byte, error) {
+	var (
+		tag  byte
+		data interface{}
+	)
+	switch x := v.(type) {
+	case *gabi.PublicKey:
+		tag, data = binaryPublicKey, x
+	case *AttestedClaim:
+		claim, err := json.Marshal(x.Claim)
+		if err != nil {
+			return nil, err
+		}
+		tag, data = binaryAttestedClaim, &binaryAttestedClaimData{
+			Claim:      claim,
+			Credential: x.Credential,
+			Preimages:  x.Preimages,
+		}
+	case *revocation.Update:
+		tag, data = binaryUpdate, x
+	case *PresentationResponse:
+		tag, data = binaryPresentationResponse, &binaryPresentationResponseData{
+			Proof:     newBinaryProofD(&x.Proof),
+			KeyID:     x.KeyID,
+			Preimages: x.Preimages,
+		}
+	case *CombinedPresentationResponse:
+		proofs := make([]*binaryProofD, len(x.Proof))
+		for i, p := range x.Proof {
+			proofD, ok := p.(*gabi.ProofD)
+			if !ok {
+				return nil, fmt.Errorf("unsupported proof type %T", p)
+			}
+			proofs[i] = newBinaryProofD(proofD)
+		}
+		tag, data = binaryCombinedPresentationResponse, &binaryCombinedPresentationResponseData{
+			Proofs:    proofs,
+			KeyIDs:    x.KeyIDs,
+			Preimages: x.Preimages,
+		}
+	case *PresentationRequest:
+		if x.PartialPresentationRequest == nil {
+			return nil, errors.New("missing partial presentation request")
+		}
+		partialReqs, err := newBinaryPartialRequests([]PartialPresentationRequest{*x.PartialPresentationRequest})
+		if err != nil {
+			return nil, err
+		}
+		tag, data = binaryPresentationRequest, &binaryPresentationRequestData{
+			PartialRequests: partialReqs,
+			Context:         x.Context,
+			Nonce:           x.Nonce,
+		}
+	case *CombinedPresentationRequest:
+		partialReqs, err := newBinaryPartialRequests(x.PartialRequests)
+		if err != nil {
+			return nil, err
+		}
+		tag, data = binaryCombinedPresentationRequest, &binaryPresentationRequestData{
+			PartialRequests: partialReqs,
+			Context:         x.Context,
+			Nonce:           x.Nonce,
+		}
+	default:
+		return nil, fmt.Errorf("binary encoding not supported for %T", v)
+	}
+	// big integers are encoded without their sign
+	if err := checkNonNegative(reflect.ValueOf(data)); err != nil {
+		return nil, err
+	}
+	bts, err := cbor.Marshal(data, cbor.EncOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{BinaryVersion, tag}, bts...), nil
+}
+
+// UnmarshalBinary decodes a value which was encoded using MarshalBinary. The
+// type of v must match the type of the encoded value.
+func UnmarshalBinary(data []byte, v interface{}) error {
+	if len(data) < 2 {
+		return errors.New("binary message too short")
+	}
+	if data[0] != BinaryVersion {
+		return fmt.Errorf("unsupported binary version %d", data[0])
+	}
+	tag, bts := data[1], data[2:]
+	switch x := v.(type) {
+	case *gabi.PublicKey:
+		if err := checkBinaryTag(tag, binaryPublicKey); err != nil {
+			return err
+		}
+		return cbor.Unmarshal(bts, x)
+	case *AttestedClaim:
+		if err := checkBinaryTag(tag, binaryAttestedClaim); err != nil {
+			return err
+		}
+		tmp := &binaryAttestedClaimData{}
+		if err := cbor.Unmarshal(bts, tmp); err != nil {
+			return err
+		}
+		claim := Claim{}
+		if err := json.Unmarshal(tmp.Claim, &claim); err != nil {
+			return err
+		}
+		x.Claim = claim
+		x.Credential = tmp.Credential
+		x.Preimages = tmp.Preimages
+		return nil
+	case *revocation.Update:
+		if err := checkBinaryTag(tag, binaryUpdate); err != nil {
+			return err
+		}
+		return cbor.Unmarshal(bts, x)
+	case *PresentationResponse:
+		if err := checkBinaryTag(tag, binaryPresentationResponse); err != nil {
+			return err
+		}
+		tmp := &binaryPresentationResponseData{}
+		if err := cbor.Unmarshal(bts, tmp); err != nil {
+			return err
+		}
+		if tmp.Proof == nil {
+			return errors.New("missing proof")
+		}
+		x.Proof = *tmp.Proof.proofD()
+		x.KeyID = tmp.KeyID
+		x.Preimages = tmp.Preimages
+		return nil
+	case *CombinedPresentationResponse:
+		if err := checkBinaryTag(tag, binaryCombinedPresentationResponse); err != nil {
+			return err
+		}
+		tmp := &binaryCombinedPresentationResponseData{}
+		if err := cbor.Unmarshal(bts, tmp); err != nil {
+			return err
+		}
+		x.Proof = make(gabi.ProofList, len(tmp.Proofs))
+		for i, p := range tmp.Proofs {
+			if p == nil {
+				return fmt.Errorf("missing %d. proof", i+1)
+			}
+			x.Proof[i] = p.proofD()
+		}
+		x.KeyIDs = tmp.KeyIDs
+		x.Preimages = tmp.Preimages
+		return nil
+	case *PresentationRequest:
+		if err := checkBinaryTag(tag, binaryPresentationRequest); err != nil {
+			return err
+		}
+		tmp := &binaryPresentationRequestData{}
+		if err := cbor.Unmarshal(bts, tmp); err != nil {
+			return err
+		}
+		if len(tmp.PartialRequests) != 1 {
+			return errors.New("expected exactly one partial presentation request")
+		}
+		partialReqs, err := tmp.partialRequests()
+		if err != nil {
+			return err
+		}
+		x.PartialPresentationRequest = &partialReqs[0]
+		x.Context = tmp.Context
+		x.Nonce = tmp.Nonce
+		return nil
+	case *CombinedPresentationRequest:
+		if err := checkBinaryTag(tag, binaryCombinedPresentationRequest); err != nil {
+			return err
+		}
+		tmp := &binaryPresentationRequestData{}
+		if err := cbor.Unmarshal(bts, tmp); err != nil {
+			return err
+		}
+		partialReqs, err := tmp.partialRequests()
+		if err != nil {
+			return err
+		}
+		x.PartialRequests = partialReqs
+		x.Context = tmp.Context
+		x.Nonce = tmp.Nonce
+		return nil
+	default:
+		return fmt.Errorf("binary encoding not supported for %T", v)
+	}
+}
+
+// DecodeBinary decodes a value which was encoded using MarshalBinary. The type
+// of the returned value depends on the encoded type.
+func DecodeBinary(data []byte) (interface{}, error) {
+	if len(data) < 2 {
+		return nil, errors.New("binary message too short")
+	}
+	var v interface{}
+	switch data[1] {
+	case binaryPublicKey:
+		v = &gabi.PublicKey{}
+	case binaryAttestedClaim:
+		v = &AttestedClaim{}
+	case binaryUpdate:
+		v = &revocation.Update{}
+	case binaryPresentationResponse:
+		v = &PresentationResponse{}
+	case binaryCombinedPresentationResponse:
+		v = &CombinedPresentationResponse{}
+	case binaryPresentationRequest:
+		v = &PresentationRequest{}
+	case binaryCombinedPresentationRequest:
+		v = &CombinedPresentationRequest{}
+	default:
+		return nil, fmt.Errorf("unknown binary message type %d", data[1])
+	}
+	if err := UnmarshalBinary(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func newBinaryProofD(p *gabi.ProofD) *binaryProofD {
+	return &binaryProofD{
+		C:                  p.C,
+		A:                  p.A,
+		EResponse:          p.EResponse,
+		VResponse:          p.VResponse,
+		AResponses:         p.AResponses,
+		ADisclosed:         p.ADisclosed,
+		NonRevocationProof: p.NonRevocationProof,
+	}
+}
+
+func (p *binaryProofD) proofD() *gabi.ProofD {
+	return &gabi.ProofD{
+		C:                  p.C,
+		A:                  p.A,
+		EResponse:          p.EResponse,
+		VResponse:          p.VResponse,
+		AResponses:         p.AResponses,
+		ADisclosed:         p.ADisclosed,
+		NonRevocationProof: p.NonRevocationProof,
+	}
+}
+
+func newBinaryPartialRequests(partialReqs []PartialPresentationRequest) ([]*binaryPartialPresentationRequest, error) {
+	binaryReqs := make([]*binaryPartialPresentationRequest, len(partialReqs))
+	for i, req := range partialReqs {
+		updatedAfter, err := req.ReqUpdatedAfter.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		binaryReqs[i] = &binaryPartialPresentationRequest{
+			RequestedAttributes:   req.RequestedAttributes,
+			ReqNonRevocationProof: req.ReqNonRevocationProof,
+			ReqUpdatedAfter:       updatedAfter,
+			HashedAttributes:      req.HashedAttributes,
+		}
+	}
+	return binaryReqs, nil
+}
+
+func (data *binaryPresentationRequestData) partialRequests() ([]PartialPresentationRequest, error) {
+	partialReqs := make([]PartialPresentationRequest, len(data.PartialRequests))
+	for i, req := range data.PartialRequests {
+		if req == nil {
+			return nil, fmt.Errorf("missing %d. partial presentation request", i+1)
+		}
+		partialReqs[i] = PartialPresentationRequest{
+			RequestedAttributes:   req.RequestedAttributes,
+			ReqNonRevocationProof: req.ReqNonRevocationProof,
+			HashedAttributes:      req.HashedAttributes,
+		}
+		if err := partialReqs[i].ReqUpdatedAfter.UnmarshalBinary(req.ReqUpdatedAfter); err != nil {
+			return nil, err
+		}
+	}
+	return partialReqs, nil
+}
+
+func checkBinaryTag(tag, expected byte) error {
+	if tag != expected {
+		return fmt.Errorf("unexpected binary message type %d (expected %d)", tag, expected)
+	}
+	return nil
+}
+
+// checkNonNegative ensures that all big integers reachable from the exported
+// fields of v are non negative.
+func checkNonNegative(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type() == bigIntType {
+			if v.Interface().(*big.Int).Sign() < 0 {
+				return errors.New("negative integers can not be encoded")
+			}
+			return nil
+		}
+		return checkNonNegative(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if err := checkNonNegative(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := checkNonNegative(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkNonNegative(iter.Value()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package credentials
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertBinaryRoundTrip checks that the json encoding of the value does not
+// change after encoding and decoding the value in the binary format.
+func assertBinaryRoundTrip(t *testing.T, v, decoded interface{}) {
+	bts, err := MarshalBinary(v)
+	require.NoError(t, err)
+	require.NoError(t, UnmarshalBinary(bts, decoded))
+
+	expected, err := json.Marshal(v)
+	require.NoError(t, err)
+	actual, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestBinaryPublicKey(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	assertBinaryRoundTrip(t, attester.PublicKey, &gabi.PublicKey{})
+}
+
+func TestBinaryAttestedClaim(t *testing.T) {
+	cred := &AttestedClaim{}
+	require.NoError(t, json.Unmarshal(byteCredential, cred))
+	// times are stored in UTC
+	cred.Credential.NonRevocationWitness.Updated = cred.Credential.NonRevocationWitness.Updated.UTC()
+	decoded := &AttestedClaim{}
+	assertBinaryRoundTrip(t, cred, decoded)
+	equal, err := cred.Claim.Equal(decoded.Claim)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	bts, err := MarshalBinary(cred)
+	require.NoError(t, err)
+	jsonBts, err := json.Marshal(cred)
+	require.NoError(t, err)
+	assert.Less(t, len(bts), len(jsonBts))
+}
+
+func TestBinaryUpdate(t *testing.T) {
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+	assertBinaryRoundTrip(t, update, &revocation.Update{})
+}
+
+func TestBinaryPresentationResponse(t *testing.T) {
+	response := &PresentationResponse{}
+	require.NoError(t, json.Unmarshal(bytePresentationResponse, response))
+	assertBinaryRoundTrip(t, response, &PresentationResponse{})
+	response.KeyID = big.NewInt(42)
+	assertBinaryRoundTrip(t, response, &PresentationResponse{})
+	preimages := []*Attribute{{Name: "contents.picture", Typename: "string", Value: []byte("picture")}}
+	response.Preimages = preimages
+	assertBinaryRoundTrip(t, response, &PresentationResponse{})
+
+	combResponse := &CombinedPresentationResponse{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationResponse, combResponse))
+	assertBinaryRoundTrip(t, combResponse, &CombinedPresentationResponse{})
+	combResponse.KeyIDs = []*big.Int{big.NewInt(42), big.NewInt(43)}
+	assertBinaryRoundTrip(t, combResponse, &CombinedPresentationResponse{})
+	combResponse.Preimages = [][]*Attribute{nil, preimages}
+	assertBinaryRoundTrip(t, combResponse, &CombinedPresentationResponse{})
+}
+
+func TestBinaryPresentationRequest(t *testing.T) {
+	request := &PresentationRequest{}
+	require.NoError(t, json.Unmarshal(bytePresentationRequest, request))
+	assertBinaryRoundTrip(t, request, &PresentationRequest{})
+	request.PartialPresentationRequest.HashedAttributes = []string{"contents.picture"}
+	assertBinaryRoundTrip(t, request, &PresentationRequest{})
+
+	combRequest := &CombinedPresentationRequest{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationRequest, combRequest))
+	assertBinaryRoundTrip(t, combRequest, &CombinedPresentationRequest{})
+
+	bts, err := MarshalBinary(combRequest)
+	require.NoError(t, err)
+	decoded, err := DecodeBinary(bts)
+	require.NoError(t, err)
+	assert.IsType(t, &CombinedPresentationRequest{}, decoded)
+}
+
+func TestBinaryInvalid(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	bts, err := MarshalBinary(attester.PublicKey)
+	require.NoError(t, err)
+
+	// wrong type
+	assert.Error(t, UnmarshalBinary(bts, &AttestedClaim{}))
+	// unknown and previous versions
+	for _, version := range []byte{0, 1, BinaryVersion + 1} {
+		wrongVersion := append([]byte{version}, bts[1:]...)
+		assert.Error(t, UnmarshalBinary(wrongVersion, &gabi.PublicKey{}))
+	}
+	// truncated
+	assert.Error(t, UnmarshalBinary(bts[:1], &gabi.PublicKey{}))
+	assert.Error(t, UnmarshalBinary(bts[:len(bts)/2], &gabi.PublicKey{}))
+	// unsupported type
+	_, err = MarshalBinary(attester)
+	assert.Error(t, err)
+
+	negative := *attester.PublicKey
+	negative.Z = big.NewInt(-1)
+	_, err = MarshalBinary(&negative)
+	assert.Error(t, err)
+}
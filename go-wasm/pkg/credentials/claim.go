@@ -1,7 +1,10 @@
 package credentials
 
 import (
+	"bytes"
 	"container/list"
+	"crypto/sha256"
+	"encoding"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
@@ -10,7 +13,10 @@ import (
 	"math"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/privacybydesign/gabi"
 	"github.com/privacybydesign/gabi/big"
@@ -23,6 +29,46 @@ const Separator = "."
 // MagicByte is used to prevent a big.Int to truncate leading zeros.
 const MagicByte = byte(0xFF)
 
+// Wildcard is a segment of a requested attribute which matches any key.
+const Wildcard = "*"
+
+// arrayLengthType is the typename of the attribute which stores the length of
+// an array in the ElementEncoding. Its name is the path of the array.
+const arrayLengthType = "arraylength"
+
+// maxArrayLength limits the length of arrays which are reconstructed from
+// disclosed attributes.
+const maxArrayLength = 1 << 16
+
+// hashType is the typename of an attribute which stores the SHA-256 hash of an
+// attribute which is too large to be stored inside the credential (see
+// MaxAttributeSize). The hash attribute has the name of the hashed attribute.
+const hashType = "sha256"
+
+// ClaimEncoding selects how a claim is transformed into attributes.
+type ClaimEncoding string
+
+const (
+	// DefaultEncoding stores every array as a single attribute, which contains
+	// the json encoding of the array.
+	DefaultEncoding ClaimEncoding = ""
+	// ElementEncoding stores every element of an array as its own attribute,
+	// named by the path of the array and the index of the element, e.g.
+	// "contents.likedNumbers.2". Additionally the length of the array is
+	// stored in an attribute named by the path of the array. Elements can be
+	// disclosed individually, the length of the array is disclosed together
+	// with any of its elements.
+	ElementEncoding ClaimEncoding = "elements"
+)
+
+// validate returns an error if the encoding is unknown.
+func (encoding ClaimEncoding) validate() error {
+	if encoding != DefaultEncoding && encoding != ElementEncoding {
+		return fmt.Errorf("unknown claim encoding %q", encoding)
+	}
+	return nil
+}
+
 type (
 	// AttestedClaim contains the Claim and the gabi.Credential. It can be used to
 	// disclose specific attributes to the verifier.
@@ -30,6 +76,7 @@ type (
 		Credential    *gabi.Credential `json:"credential"`
 		UpdateCounter uint64           `json:"updateCounter"`
 		Claim         Claim            `json:"claim"`
+		Preimages     []*Attribute     `json:"preimages,omitempty"`
 	}
 
 	// Claim contains the attributes the claimer claims to possess. Contents should
@@ -45,6 +92,8 @@ type (
 	//    We receive a list of attributes
 	// 2. transform each of these attributes into a big.Int
 	//    big.Int := bytes(Len(Name)|Name|len(Type)|type|len(value)|value)
+	// Using the ElementEncoding arrays are not stored as a single value, instead
+	// they are traversed like objects whose keys are the indices of the elements.
 	Claim map[string]interface{}
 
 	// Attribute describes an attribute. It specifies the name and the type of the
@@ -63,9 +112,15 @@ type (
 	}
 )
 
-// NewAttestedClaim instantiates a new AttestedClaim.
-func NewAttestedClaim(cb *gabi.CredentialBuilder, attributes []*Attribute, signature *gabi.IssueSignatureMessage) (*AttestedClaim, error) {
-	claim, err := newClaimFromAttribute(attributes)
+// NewAttestedClaim instantiates a new AttestedClaim. The preimages are the
+// original attributes of the hash attributes (see ToAttributesWithParams).
+func NewAttestedClaim(cb *gabi.CredentialBuilder, attributes, preimages []*Attribute,
+	signature *gabi.IssueSignatureMessage) (*AttestedClaim, error) {
+	revealed, err := applyPreimages(attributes, preimages)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := newClaimFromAttribute(revealed)
 	if err != nil {
 		return nil, err
 	}
@@ -81,34 +136,170 @@ func NewAttestedClaim(cb *gabi.CredentialBuilder, attributes []*Attribute, signa
 	return &AttestedClaim{
 		Credential: cred,
 		Claim:      claim,
+		Preimages:  preimages,
 	}, nil
 }
 
-func (attestedClaim *AttestedClaim) getAttributeIndices(reqAttributes []string) ([]int, error) {
-	// make sure attributes are unique
-	reqAttributes, _ = sortRemoveDuplicates(reqAttributes)
+// LoadAttestedClaim unmarshals a stored AttestedClaim and validates it against
+// the public key of the attester and the latest accumulator. The accumulator is
+// optional, see Validate.
+func LoadAttestedClaim(data []byte, attesterPubK *gabi.PublicKey, latestAcc *revocation.SignedAccumulator) (*AttestedClaim, error) {
+	attestedClaim := &AttestedClaim{}
+	if err := json.Unmarshal(data, attestedClaim); err != nil {
+		return nil, err
+	}
+	if err := attestedClaim.Validate(attesterPubK, latestAcc); err != nil {
+		return nil, err
+	}
+	return attestedClaim, nil
+}
+
+// Validate checks that the AttestedClaim is usable. It verifies that the key of
+// the attester has not expired, that the CL signature matches the attributes,
+// that the attributes are sorted and decode to the Claim, that the preimages
+// match their hash attributes and that the non
+// revocation witness is valid. If latestAcc is not nil, the witness must belong
+// to the latest accumulator, otherwise the witness is only checked against the
+// accumulator it contains.
+func (attestedClaim *AttestedClaim) Validate(attesterPubK *gabi.PublicKey, latestAcc *revocation.SignedAccumulator) error {
+	if attesterPubK == nil {
+		return errors.New("missing public key of the attester")
+	}
+	cred := attestedClaim.Credential
+	if cred == nil || cred.Signature == nil || len(cred.Attributes) < 1 {
+		return errors.New("incomplete credential")
+	}
+	if time.Now().After(time.Unix(attesterPubK.ExpiryDate, 0)) {
+		return newError(ErrKeyExpired, "public key of the attester expired")
+	}
+	if !cred.Signature.Verify(attesterPubK, cred.Attributes) {
+		return newError(ErrProofInvalid, "signature does not match the attributes")
+	}
+
+	attributes, err := attestedClaim.getClaimAttributes()
+	if err != nil {
+		return err
+	}
+	claim, err := newClaimFromAttribute(attributes)
+	if err != nil {
+		return err
+	}
+	if equal, err := claim.Equal(attestedClaim.Claim); err != nil {
+		return err
+	} else if !equal {
+		return newError(ErrProofInvalid, "claim does not match the attributes inside the credential")
+	}
+
+	witness := cred.NonRevocationWitness
+	if witness == nil {
+		return errors.New("missing non revocation witness")
+	}
+	revKey, err := attesterPubK.RevocationKey()
+	if err != nil {
+		return err
+	}
+	if err = witness.Verify(revKey); err != nil {
+		return err
+	}
+	if latestAcc != nil {
+		acc, err := latestAcc.UnmarshalVerify(revKey)
+		if err != nil {
+			return err
+		}
+		if witness.SignedAccumulator.Accumulator.Index < acc.Index {
+			return newError(ErrAccumulatorStale, "witness is outdated and needs to be updated")
+		} else if witness.SignedAccumulator.Accumulator.Index > acc.Index {
+			return newError(ErrAccumulatorStale, "witness is newer than the latest accumulator")
+		}
+	}
+	return nil
+}
 
-	indices := make([]int, len(reqAttributes))
+// getAttributeIndices returns the indices of the attributes which are selected
+// by the requested attributes. A requested attribute selects all attributes
+// whose path starts with the path of the requested attribute, which allows to
+// disclose nested objects and arrays as a whole. The Wildcard matches any key,
+// e.g. "contents.*.name" selects the name of every object inside contents.
+// The length of an array of the ElementEncoding is disclosed if one of its
+// elements is selected.
+func (attestedClaim *AttestedClaim) getAttributeIndices(reqAttributes []string) ([]int, error) {
 	attributes, err := attestedClaim.getAttributes()
 	if err != nil {
 		return nil, err
 	}
+	sep := []rune(Separator)[0]
+	paths := make([][]string, len(attributes))
+	for i, attr := range attributes {
+		if paths[i], err = escapedSplit(attr.Name, sep); err != nil {
+			return nil, err
+		}
+	}
 
-	i := 0
-	// assert: attestedClaim.Attributes and reqAttributes are sorted!
-	for attrI, v := range attributes {
-		if i < len(reqAttributes) && strings.Compare(reqAttributes[i], v.Name) == 0 {
+	selected := make([]bool, len(attributes))
+	for _, reqAttr := range reqAttributes {
+		selector, err := escapedSplit(reqAttr, sep)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for i, path := range paths {
+			if matchesSelector(selector, path) {
+				selected[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, newError(ErrAttributeMissing, "could not find attribute with name '%s'", reqAttr)
+		}
+	}
+	for i, attr := range attributes {
+		if attr.Typename != arrayLengthType {
+			continue
+		}
+		for j, path := range paths {
+			if selected[j] && len(path) > len(paths[i]) && hasPathPrefix(path, paths[i]) {
+				selected[i] = true
+				break
+			}
+		}
+	}
+
+	var indices []int
+	for i, sel := range selected {
+		if sel {
 			// first attribute inside the attestedClaim is the secret key.
 			// the real attributes start at index 1
-			indices[i] = attrI + 1
-			i++
+			indices = append(indices, i+1)
 		}
 	}
+	return indices, nil
+}
 
-	if i < len(reqAttributes) {
-		return nil, fmt.Errorf("could not find attribute with name '%s'", reqAttributes[i])
+// matchesSelector reports whether the path starts with the selector. Wildcard
+// segments of the selector match any key.
+func matchesSelector(selector, path []string) bool {
+	if len(selector) > len(path) {
+		return false
 	}
-	return indices, nil
+	for i, part := range selector {
+		if part != Wildcard && part != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPathPrefix reports whether the path starts with the prefix.
+func hasPathPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, part := range prefix {
+		if part != path[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // getRawAttributes returns a list of all attributes stored inside the credential.
@@ -132,6 +323,65 @@ func (attestedClaim *AttestedClaim) getAttributes() ([]*Attribute, error) {
 	return attributes, nil
 }
 
+// getClaimAttributes returns the attributes stored inside the credential, in
+// which the hash attributes are replaced by their preimages.
+func (attestedClaim *AttestedClaim) getClaimAttributes() ([]*Attribute, error) {
+	attributes, err := attestedClaim.getAttributes()
+	if err != nil {
+		return nil, err
+	}
+	return applyPreimages(attributes, attestedClaim.Preimages)
+}
+
+// getPreimages returns the preimages of the hash attributes at the given
+// indices. Preimages of attributes which are selected by hashedAttributes are
+// left out, so that only the hash of their value is disclosed.
+func (attestedClaim *AttestedClaim) getPreimages(indices []int, hashedAttributes []string) ([]*Attribute, error) {
+	if len(attestedClaim.Preimages) < 1 {
+		return nil, nil
+	}
+	attributes, err := attestedClaim.getAttributes()
+	if err != nil {
+		return nil, err
+	}
+	sep := []rune(Separator)[0]
+	selectors := make([][]string, len(hashedAttributes))
+	for i, hashed := range hashedAttributes {
+		if selectors[i], err = escapedSplit(hashed, sep); err != nil {
+			return nil, err
+		}
+	}
+	var preimages []*Attribute
+	for _, index := range indices {
+		// the indices include the secret key
+		attr := attributes[index-1]
+		if attr.Typename != hashType {
+			continue
+		}
+		path, err := escapedSplit(attr.Name, sep)
+		if err != nil {
+			return nil, err
+		}
+		hashOnly := false
+		for _, selector := range selectors {
+			if matchesSelector(selector, path) {
+				hashOnly = true
+				break
+			}
+		}
+		if hashOnly {
+			continue
+		}
+		for _, preimage := range attestedClaim.Preimages {
+			if preimage.Name == attr.Name {
+				preimages = append(preimages, preimage)
+				break
+			}
+		}
+	}
+	return preimages, nil
+}
+
 // Update updates the non revocation witness using the provided update.
 func (attestedClaim *AttestedClaim) Update(attesterPubK *gabi.PublicKey, update *revocation.Update) error {
 	pubRevKey, err := attesterPubK.RevocationKey()
@@ -193,51 +443,288 @@ func (attestedClaim *AttestedClaim) UpdateAll(attesterPubK *gabi.PublicKey,
 	return nil
 }
 
+// newClaimFromAttribute decodes the attributes into a claim. Attributes of
+// unknown types are decoded as hex strings.
 func newClaimFromAttribute(attributes []*Attribute) (Claim, error) {
+	return decodeClaim(attributes, false)
+}
+
+// decodeClaim decodes the attributes into a claim. In strict mode attributes
+// of unknown types are rejected and the values must be encoded exactly like
+// EncodeAttributes encodes them.
+func decodeClaim(attributes []*Attribute, strict bool) (Claim, error) {
 	claim := make(Claim)
+	arrayLengths := make(map[string]int)
 	for _, attr := range attributes {
-		var err error
-		switch attr.Typename {
-		case "string":
-			err = setNestedValue(claim, attr.Name, string(attr.Value))
-		case "float":
-			bytes := attr.Value
-			// a float requires at least 8 bytes.
-			if len(bytes) < 8 {
-				return nil, fmt.Errorf("invalid big.Int for %q float value", attr.Name)
-			}
-			bits := binary.BigEndian.Uint64(bytes)
-			err = setNestedValue(claim, attr.Name, math.Float64frombits(bits))
-		case "bool":
-			err = setNestedValue(claim, attr.Name, attr.Value[0] != byte(0))
-		case "array":
-			var array []interface{}
-			// skip first byte which is only for big ints. trailing 0 are
-			// truncated...
-			err = json.Unmarshal(attr.Value[1:], &array)
-			if err == nil {
-				err = setNestedValue(claim, attr.Name, array)
-			}
-		case "":
-			err = setNestedValue(claim, attr.Name, nil)
-		default:
-			err = setNestedValue(claim, attr.Name, hex.EncodeToString(attr.Value))
+		if attr == nil {
+			return nil, errors.New("missing attribute")
 		}
+		value, err := decodeValue(attr, strict)
 		if err != nil {
 			return nil, err
 		}
+		if attr.Typename == arrayLengthType {
+			arrayLengths[attr.Name] = value.(int)
+			continue
+		}
+		if err := setNestedValue(claim, attr.Name, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := setArrays(claim, arrayLengths); err != nil {
+		return nil, err
 	}
 	return claim, nil
 }
 
+// decodeValue decodes the value of the attribute. The length of an array is
+// returned as int.
+func decodeValue(attr *Attribute, strict bool) (interface{}, error) {
+	switch attr.Typename {
+	case arrayLengthType:
+		if len(attr.Value) != 8 {
+			return nil, fmt.Errorf("invalid length of array %q", attr.Name)
+		}
+		length := binary.BigEndian.Uint64(attr.Value)
+		if length > maxArrayLength {
+			return nil, fmt.Errorf("array %q is too long", attr.Name)
+		}
+		return int(length), nil
+	case "string":
+		if strict && !utf8.Valid(attr.Value) {
+			return nil, fmt.Errorf("invalid string value of %q", attr.Name)
+		}
+		return string(attr.Value), nil
+	case "float":
+		// a float requires at least 8 bytes.
+		if len(attr.Value) < 8 || (strict && len(attr.Value) != 8) {
+			return nil, fmt.Errorf("invalid big.Int for %q float value", attr.Name)
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(attr.Value))
+		if strict && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return nil, fmt.Errorf("invalid float value of %q", attr.Name)
+		}
+		return f, nil
+	case "bool":
+		if len(attr.Value) < 1 || (strict && (len(attr.Value) != 1 || attr.Value[0] > 1)) {
+			return nil, fmt.Errorf("invalid bool value of %q", attr.Name)
+		}
+		return attr.Value[0] != byte(0), nil
+	case "array":
+		// skip first byte which is only for big ints. trailing 0 are
+		// truncated...
+		if len(attr.Value) < 1 || (strict && attr.Value[0] != MagicByte) {
+			return nil, fmt.Errorf("invalid array value of %q", attr.Name)
+		}
+		var array []interface{}
+		if err := json.Unmarshal(attr.Value[1:], &array); err != nil {
+			return nil, err
+		}
+		return array, nil
+	case "":
+		if strict && len(attr.Value) != 0 {
+			return nil, fmt.Errorf("invalid null value of %q", attr.Name)
+		}
+		return nil, nil
+	case hashType:
+		if strict && len(attr.Value) != sha256.Size {
+			return nil, fmt.Errorf("invalid hash value of %q", attr.Name)
+		}
+		// the hash is disclosed without its preimage
+		return hex.EncodeToString(attr.Value), nil
+	default:
+		if strict {
+			return nil, fmt.Errorf("unknown type %q of attribute %q", attr.Typename, attr.Name)
+		}
+		return hex.EncodeToString(attr.Value), nil
+	}
+}
+
+// setArrays replaces the objects containing the elements of the arrays of the
+// ElementEncoding by arrays of the given lengths. Elements which were not
+// disclosed are set to nil. Inner arrays are replaced first.
+func setArrays(claim Claim, arrayLengths map[string]int) error {
+	sep := []rune(Separator)[0]
+	paths := make([][]string, 0, len(arrayLengths))
+	for name := range arrayLengths {
+		path, err := escapedSplit(name, sep)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+	for _, path := range paths {
+		name := strings.Join(path, Separator)
+		m := map[string]interface{}(claim)
+		for _, part := range path[:len(path)-1] {
+			key := unescape(part, sep)
+			if _, ok := m[key]; !ok {
+				m[key] = make(map[string]interface{})
+			}
+			next, ok := m[key].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("could not set array %q (not a map)", name)
+			}
+			m = next
+		}
+		key := unescape(path[len(path)-1], sep)
+		array := make([]interface{}, arrayLengths[name])
+		if value, ok := m[key]; ok {
+			elements, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("could not set array %q (not a map)", name)
+			}
+			for index, element := range elements {
+				i, err := strconv.Atoi(index)
+				if err != nil || strconv.Itoa(i) != index || i < 0 || i >= len(array) {
+					return fmt.Errorf("invalid element %q of array %q", index, name)
+				}
+				array[i] = element
+			}
+		}
+		m[key] = array
+	}
+	return nil
+}
+
+// Equal reports whether both claims contain the same values. Claims are
+// compared using their json representation, so that nested Claims and
+// map[string]interface{} are treated as equal.
+func (claim Claim) Equal(other Claim) (bool, error) {
+	a, err := json.Marshal(claim)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(other)
+	if err != nil {
+		return false, err
+	}
+	return string(a) == string(b), nil
+}
+
 // ToAttributes transforms a claim struct to a list of attributes. The returned list is sorted by name.
-func (claim Claim) ToAttributes() []*Attribute {
+// An error is returned if the claim can not be encoded, see EncodeAttributes.
+func (claim Claim) ToAttributes() ([]*Attribute, error) {
+	return claim.EncodeAttributes(DefaultEncoding)
+}
+
+// MaxAttributeSize returns the size in bytes of the largest attribute, which is
+// stored as is inside a credential using the given system parameters. The
+// size is the length of the binary encoding of the attribute (see
+// Attribute.MarshalBinary), which includes its name, its type and a header of
+// 25 bytes besides the value. Larger attributes are hashed. The limit is the
+// message space (Lm bits) of the key, so with the default parameters of 1024
+// and 2048 bit keys (32 bytes) every attribute is hashed.
+func MaxAttributeSize(sysParams *gabi.SystemParameters) int {
+	return int(sysParams.Lm / 8)
+}
+
+// ToAttributesWithParams transforms a claim struct to a list of attributes
+// like EncodeAttributes. Every attribute whose encoding exceeds the
+// MaxAttributeSize of the system parameters is replaced by an attribute of the
+// same name, which contains the SHA-256 hash of the attribute. The replaced
+// attributes are returned as preimages, sorted by name. If sysParams is nil
+// no attributes are hashed.
+func (claim Claim) ToAttributesWithParams(sysParams *gabi.SystemParameters, encoding ClaimEncoding) ([]*Attribute, []*Attribute, error) {
+	attributes, err := claim.EncodeAttributes(encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sysParams == nil {
+		return attributes, nil, nil
+	}
+	maxSize := MaxAttributeSize(sysParams)
+	var preimages []*Attribute
+	for i, attr := range attributes {
+		if attr.encodedSize() <= maxSize {
+			continue
+		}
+		hashed, err := hashAttribute(attr)
+		if err != nil {
+			return nil, nil, err
+		}
+		attributes[i] = hashed
+		preimages = append(preimages, attr)
+	}
+	return attributes, preimages, nil
+}
+
+// hashAttribute returns the hash attribute of the given attribute. The hash
+// covers the binary encoding of the attribute and therefore its name and type.
+func hashAttribute(attr *Attribute) (*Attribute, error) {
+	bts, err := attr.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(bts)
+	return &Attribute{
+		Name:     attr.Name,
+		Typename: hashType,
+		Value:    hash[:],
+	}, nil
+}
+
+// applyPreimages replaces the hash attributes by their preimages. Every
+// preimage must belong to a hash attribute and match its hash. Hash attributes
+// without a preimage are kept. The given attributes are not modified.
+func applyPreimages(attributes, preimages []*Attribute) ([]*Attribute, error) {
+	if len(preimages) < 1 {
+		return attributes, nil
+	}
+	applied := make([]*Attribute, len(attributes))
+	copy(applied, attributes)
+	for _, preimage := range preimages {
+		if preimage == nil {
+			return nil, errors.New("missing preimage")
+		}
+		i := 0
+		for i < len(applied) && applied[i].Name != preimage.Name {
+			i++
+		}
+		if i >= len(applied) || applied[i].Typename != hashType {
+			return nil, fmt.Errorf("no hashed attribute %q for preimage", preimage.Name)
+		}
+		hashed, err := hashAttribute(preimage)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(hashed.Value, applied[i].Value) {
+			return nil, newError(ErrProofInvalid, "preimage does not match the hash of attribute %q", preimage.Name)
+		}
+		applied[i] = preimage
+	}
+	return applied, nil
+}
+
+// EncodeAttributes transforms a claim struct to a list of attributes using the
+// given encoding. The returned list is sorted by name. Besides the values of
+// decoded json, the claim may contain native Go values like integers and
+// structs, which are converted like json.Marshal converts them (e.g. using the
+// json tags of the struct fields). If a value can not be encoded, an
+// AttributeError containing the name of the attribute is returned.
+func (claim Claim) EncodeAttributes(encoding ClaimEncoding) ([]*Attribute, error) {
+	normalized, err := normalizeObject("", claim)
+	if err != nil {
+		return nil, err
+	}
+	return encodeNormalized(normalized, encoding)
+}
+
+// encodeNormalized transforms a claim, which was normalized using
+// normalizeObject, into attributes like EncodeAttributes.
+func encodeNormalized(normalized map[string]interface{}, encoding ClaimEncoding) ([]*Attribute, error) {
+	if err := encoding.validate(); err != nil {
+		return nil, err
+	}
 	var attributes []*Attribute
 
 	queue := list.New()
 	queue.PushBack(&nestedObject{
 		prefix:  "",
-		content: claim,
+		content: normalized,
 	})
 	// go through every property of the claim, transform it into an int and put it into the attributes array
 	for queue.Len() > 0 {
@@ -245,34 +732,36 @@ func (claim Claim) ToAttributes() []*Attribute {
 		queue.Remove(elem)
 		jsonObj := elem.Value.(*nestedObject)
 		for n, v := range jsonObj.content {
-			var name string
-			n = escape(n, []rune(Separator)[0])
-			if jsonObj.prefix != "" {
-				name = jsonObj.prefix + Separator + n
-			} else {
-				name = n
-			}
-			reflected := reflect.ValueOf(v)
+			name := joinName(jsonObj.prefix, n)
 
-			switch reflected.Kind() {
-			case reflect.Map:
-				if m, ok := v.(Claim); ok {
-					queue.PushBack(&nestedObject{
-						prefix:  name,
-						content: m,
+			switch value := v.(type) {
+			case map[string]interface{}:
+				queue.PushBack(&nestedObject{
+					prefix:  name,
+					content: (Claim)(value),
+				})
+			case []interface{}:
+				if encoding == ElementEncoding {
+					var length [8]byte
+					binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+					attributes = append(attributes, &Attribute{
+						Name:     name,
+						Typename: arrayLengthType,
+						Value:    length[:],
 					})
-				} else if m, ok := v.(map[string]interface{}); ok {
+					elements := make(Claim, len(value))
+					for i, element := range value {
+						elements[strconv.Itoa(i)] = element
+					}
 					queue.PushBack(&nestedObject{
 						prefix:  name,
-						content: (Claim)(m),
+						content: elements,
 					})
-				} else {
-					panic(fmt.Sprintf("unsupported map type %T", v))
+					continue
 				}
-			case reflect.Slice, reflect.Array:
-				marshaledV, err := json.Marshal(v)
+				marshaledV, err := json.Marshal(value)
 				if err != nil {
-					panic("could not marshal array")
+					return nil, &AttributeError{Name: name, Err: err}
 				}
 				// for big ints prepend with non null byte
 				marshaledV = append([]byte{MagicByte}, marshaledV...)
@@ -281,40 +770,38 @@ func (claim Claim) ToAttributes() []*Attribute {
 					Typename: "array",
 					Value:    marshaledV,
 				})
-			case reflect.String:
+			case string:
 				attributes = append(attributes, &Attribute{
 					Name:     name,
 					Typename: "string",
-					Value:    []byte(reflected.String()),
+					Value:    []byte(value),
 				})
-			case reflect.Float32, reflect.Float64:
+			case float64:
 				var buf [8]byte
-				binary.BigEndian.PutUint64(buf[:], math.Float64bits(reflected.Float()))
+				binary.BigEndian.PutUint64(buf[:], math.Float64bits(value))
 				attributes = append(attributes, &Attribute{
 					Name:     name,
 					Typename: "float",
 					Value:    buf[:],
 				})
-			case reflect.Bool:
-				var value byte
-				if reflected.Bool() {
-					value = 1
-				} else {
-					value = 0
+			case bool:
+				var b byte
+				if value {
+					b = 1
 				}
 				attributes = append(attributes, &Attribute{
 					Name:     name,
 					Typename: "bool",
-					Value:    []byte{value},
+					Value:    []byte{b},
 				})
-			case 0:
+			case nil:
 				attributes = append(attributes, &Attribute{
 					Name:     name,
 					Typename: "",
 					Value:    []byte{},
 				})
 			default:
-				panic(fmt.Sprintf("unknown type %T of property %s", v, name))
+				return nil, &AttributeError{Name: name, Err: fmt.Errorf("unsupported type %T", v)}
 			}
 		}
 	}
@@ -322,7 +809,159 @@ func (claim Claim) ToAttributes() []*Attribute {
 	sort.Slice(attributes[:], func(i, j int) bool {
 		return strings.Compare(attributes[i].Name, attributes[j].Name) < 0
 	})
-	return attributes
+	return attributes, nil
+}
+
+// joinName returns the name of the attribute stored under the key of the
+// object with the given name.
+func joinName(prefix, key string) string {
+	key = escape(key, []rune(Separator)[0])
+	if prefix == "" {
+		return key
+	}
+	return prefix + Separator + key
+}
+
+// maxSafeInteger is the largest integer below which every integer can be
+// stored exactly as a float.
+const maxSafeInteger = 1 << 53
+
+// normalizeObject converts all values of the object into the types of decoded
+// json, which are nil, bool, float64, string, []interface{} and
+// map[string]interface{}. The name is the attribute name of the object.
+func normalizeObject(name string, object map[string]interface{}) (map[string]interface{}, error) {
+	normalized := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		var err error
+		if normalized[key], err = normalizeValue(joinName(name, key), value); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeValue converts the value like normalizeObject.
+func normalizeValue(name string, v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case nil, bool, string:
+		return value, nil
+	case float64:
+		return normalizeFloat(name, value)
+	case json.Number:
+		return normalizeNumber(name, value)
+	case Claim:
+		return normalizeObject(name, value)
+	case map[string]interface{}:
+		return normalizeObject(name, value)
+	case json.Marshaler, encoding.TextMarshaler:
+		return normalizeJSON(name, value)
+	}
+	reflected := reflect.ValueOf(v)
+	switch reflected.Kind() {
+	case reflect.Bool:
+		return reflected.Bool(), nil
+	case reflect.String:
+		return reflected.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := reflected.Int()
+		if i > maxSafeInteger || i < -maxSafeInteger {
+			return nil, &AttributeError{Name: name, Err: fmt.Errorf("integer %d can not be represented exactly", i)}
+		}
+		return float64(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := reflected.Uint()
+		if u > maxSafeInteger {
+			return nil, &AttributeError{Name: name, Err: fmt.Errorf("integer %d can not be represented exactly", u)}
+		}
+		return float64(u), nil
+	case reflect.Float32, reflect.Float64:
+		return normalizeFloat(name, reflected.Float())
+	case reflect.Ptr, reflect.Interface:
+		if reflected.IsNil() {
+			return nil, nil
+		}
+		return normalizeValue(name, reflected.Elem().Interface())
+	case reflect.Map:
+		if reflected.Type().Key().Kind() != reflect.String {
+			return nil, &AttributeError{Name: name, Err: fmt.Errorf("unsupported map key type %s", reflected.Type().Key())}
+		}
+		object := make(map[string]interface{}, reflected.Len())
+		iter := reflected.MapRange()
+		for iter.Next() {
+			object[iter.Key().String()] = iter.Value().Interface()
+		}
+		return normalizeObject(name, object)
+	case reflect.Slice:
+		if reflected.Type().Elem().Kind() == reflect.Uint8 {
+			// byte slices are base64 encoded strings
+			return normalizeJSON(name, v)
+		}
+		if reflected.IsNil() {
+			return []interface{}(nil), nil
+		}
+		return normalizeArray(name, reflected)
+	case reflect.Array:
+		return normalizeArray(name, reflected)
+	case reflect.Struct:
+		return normalizeJSON(name, v)
+	}
+	return nil, &AttributeError{Name: name, Err: fmt.Errorf("unsupported type %T", v)}
+}
+
+// normalizeArray converts the elements of the array or slice like
+// normalizeObject.
+func normalizeArray(name string, array reflect.Value) ([]interface{}, error) {
+	normalized := make([]interface{}, array.Len())
+	for i := range normalized {
+		var err error
+		elemName := name + Separator + strconv.Itoa(i)
+		if normalized[i], err = normalizeValue(elemName, array.Index(i).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeJSON converts the value using its json encoding, which respects
+// the json tags of structs and custom marshalers.
+func normalizeJSON(name string, v interface{}) (interface{}, error) {
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return nil, &AttributeError{Name: name, Err: err}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bts))
+	// numbers are decoded as json.Number to detect lost precision
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, &AttributeError{Name: name, Err: err}
+	}
+	return normalizeValue(name, decoded)
+}
+
+// normalizeNumber converts a json number into a float. Integers must be
+// representable exactly.
+func normalizeNumber(name string, number json.Number) (interface{}, error) {
+	if !strings.ContainsAny(number.String(), ".eE") {
+		i, err := number.Int64()
+		if err != nil || i > maxSafeInteger || i < -maxSafeInteger {
+			return nil, &AttributeError{Name: name, Err: fmt.Errorf("integer %s can not be represented exactly", number)}
+		}
+		return float64(i), nil
+	}
+	f, err := number.Float64()
+	if err != nil {
+		return nil, &AttributeError{Name: name, Err: err}
+	}
+	return normalizeFloat(name, f)
+}
+
+// normalizeFloat rejects floats which have no json representation.
+func normalizeFloat(name string, f float64) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, &AttributeError{Name: name, Err: fmt.Errorf("unsupported float value %v", f)}
+	}
+	return f, nil
 }
 
 // BigIntsToAttributes takes an array of big ints and unmarshals them into an
@@ -331,6 +970,9 @@ func BigIntsToAttributes(encodedAttributes []*big.Int) ([]*Attribute, error) {
 	attributes := make([]*Attribute, len(encodedAttributes))
 	i := 0
 	for _, bInt := range encodedAttributes {
+		if bInt == nil {
+			return nil, errors.New("missing attribute")
+		}
 		attributes[i] = &Attribute{}
 		if err := attributes[i].UnmarshalBinary(bInt.Bytes()); err != nil {
 			return nil, err
@@ -355,13 +997,18 @@ func attributesToBigInts(attributes []*Attribute) ([]*big.Int, error) {
 	return bInts, nil
 }
 
+// encodedSize returns the length of the binary encoding of the attribute.
+func (p Attribute) encodedSize() int {
+	return len(p.Name) + len(p.Typename) + len(p.Value) + 3*8 + 1
+}
+
 // MarshalBinary writes the attributes into a byte array
 func (p Attribute) MarshalBinary() ([]byte, error) {
 	// good old [length|field] encoding. length is an uint64
 	byteName := []byte(p.Name)
 	byteTypename := []byte(p.Typename)
 
-	b := make([]byte, len(byteName)+len(byteTypename)+len(p.Value)+3*8+1)
+	b := make([]byte, p.encodedSize())
 	// leading zeros are striped from big.Ints...
 	b[0] = MagicByte
 	index := 1
@@ -385,40 +1032,50 @@ func (p Attribute) MarshalBinary() ([]byte, error) {
 // UnmarshalBinary parse a byte array into an attributes
 func (p *Attribute) UnmarshalBinary(data []byte) error {
 	// good old [length|field] encoding. length is an uint64
-	if data[0] != MagicByte {
+	if len(data) < 1 || data[0] != MagicByte {
 		return errors.New("missing magic byte")
 	}
-	if 8 >= (uint64)(len(data)) {
-		return errors.New("invalid length data")
+	rest := data[1:]
+	name, rest, err := readField(rest)
+	if err != nil {
+		return fmt.Errorf("invalid name data: %v", err)
 	}
-	var index uint64 = 1
-	lengthName := binary.BigEndian.Uint64(data[index : index+8])
-	index += 8
-	if index+lengthName >= (uint64)(len(data)) {
-		return errors.New("invalid name data")
+	typename, rest, err := readField(rest)
+	if err != nil {
+		return fmt.Errorf("invalid typename data: %v", err)
 	}
-	p.Name = string(data[index : index+lengthName])
-	index += lengthName
-
-	lengthTypename := binary.BigEndian.Uint64(data[index : index+8])
-	index += 8
-	if index+lengthTypename >= (uint64)(len(data)) {
-		return errors.New("invalid typename data")
+	value, rest, err := readField(rest)
+	if err != nil {
+		return fmt.Errorf("invalid value data: %v", err)
 	}
-	p.Typename = string(data[index : index+lengthTypename])
-	index += lengthTypename
-
-	lengthValue := binary.BigEndian.Uint64(data[index : index+8])
-	index += 8
-	if index+lengthValue > (uint64)(len(data)) {
-		return errors.New("invalid value data")
+	if len(rest) != 0 {
+		return errors.New("unexpected data after the value")
 	}
-	p.Value = data[index : index+lengthValue]
+	p.Name = string(name)
+	p.Typename = string(typename)
+	p.Value = value
 	return nil
 }
 
+// readField reads a field of the binary encoding of an attribute, which is
+// prefixed by its length, and returns the remaining data.
+func readField(data []byte) ([]byte, []byte, error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("missing length")
+	}
+	length := binary.BigEndian.Uint64(data[:8])
+	data = data[8:]
+	if length > uint64(len(data)) {
+		return nil, nil, errors.New("length exceeds the data")
+	}
+	return data[:length], data[length:], nil
+}
+
 func setNestedValue(m map[string]interface{}, key string, value interface{}) error {
-	parts := escapedSplit(key, []rune(Separator)[0])
+	parts, err := escapedSplit(key, []rune(Separator)[0])
+	if err != nil {
+		return err
+	}
 	for _, v := range parts[:len(parts)-1] {
 		key := unescape(v, []rune(Separator)[0])
 		if acc, ok := m[key]; ok {
@@ -2,6 +2,9 @@ package credentials
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -64,6 +67,71 @@ func TestUpdateAttestedClaim(t *testing.T) {
 	assert.Equal(t, oldUpCount+1, cred.UpdateCounter)
 }
 
+func TestValidateAttestedClaim(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	update := &revocation.Update{}
+	err = json.Unmarshal(byteUpdate, update)
+	require.NoError(t, err)
+
+	cred, err := LoadAttestedClaim(byteCredential, attester.PublicKey, update.SignedAccumulator)
+	require.NoError(t, err)
+	require.NotNil(t, cred)
+	assert.NoError(t, cred.Validate(attester.PublicKey, nil))
+
+	// witness is older than the latest accumulator
+	revUpdate := &revocation.Update{}
+	err = json.Unmarshal(byteUpdateRevocation, revUpdate)
+	require.NoError(t, err)
+	assert.Error(t, cred.Validate(attester.PublicKey, revUpdate.SignedAccumulator))
+
+	// claim does not match the attributes
+	contents, ok := cred.Claim["contents"].(map[string]interface{})
+	require.True(t, ok)
+	contents["age"] = 35.
+	assert.Error(t, cred.Validate(attester.PublicKey, nil))
+	contents["age"] = 34.
+	require.NoError(t, cred.Validate(attester.PublicKey, nil))
+
+	// attributes do not match the signature
+	oldAttr := cred.Credential.Attributes[1]
+	cred.Credential.Attributes[1] = new(big.Int).Add(oldAttr, big.NewInt(1))
+	assert.Error(t, cred.Validate(attester.PublicKey, nil))
+	cred.Credential.Attributes[1] = oldAttr
+
+	// expired key
+	expiredKey := *attester.PublicKey
+	expiredKey.ExpiryDate = earlier.Unix()
+	assert.Error(t, cred.Validate(&expiredKey, nil))
+}
+
+func TestClaimEqual(t *testing.T) {
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": Claim{
+			"age": 34.,
+		},
+	}
+	other := Claim{}
+	err := json.Unmarshal(byteClaim, &other)
+	require.NoError(t, err)
+
+	equal, err := claim.Equal(other)
+	require.NoError(t, err)
+	assert.False(t, equal)
+
+	equal, err = claim.Equal(Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"age": 34.,
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, equal)
+}
+
 func TestGetAttributeIndices(t *testing.T) {
 	cred := &AttestedClaim{}
 	err := json.Unmarshal(byteCredential, cred)
@@ -78,6 +146,344 @@ func TestGetAttributeIndices(t *testing.T) {
 	assert.Equal(t, []int{1, 2, 5}, indice)
 }
 
+func TestGetAttributeIndicesSelectors(t *testing.T) {
+	cred := &AttestedClaim{}
+	require.NoError(t, json.Unmarshal(byteCredential, cred))
+
+	indices, err := cred.getAttributeIndices([]string{"contents"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, indices)
+
+	indices, err = cred.getAttributeIndices([]string{"contents.*", "contents.age"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, indices)
+
+	indices, err = cred.getAttributeIndices([]string{Wildcard})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, indices)
+
+	// selectors match whole keys only
+	_, err = cred.getAttributeIndices([]string{"contents.ag"})
+	assert.Error(t, err)
+	_, err = cred.getAttributeIndices([]string{"contents.age.*"})
+	assert.Error(t, err)
+}
+
+// newElementEncodedClaim returns an AttestedClaim without signature, which
+// contains the attributes of the claim using the ElementEncoding.
+func newElementEncodedClaim(t *testing.T, claim Claim) *AttestedClaim {
+	attributes, err := claim.EncodeAttributes(ElementEncoding)
+	require.NoError(t, err)
+	bInts, err := attributesToBigInts(attributes)
+	require.NoError(t, err)
+	return &AttestedClaim{
+		Credential: &gabi.Credential{Attributes: append([]*big.Int{big.NewInt(1)}, bInts...)},
+		Claim:      claim,
+	}
+}
+
+// discloseAttributes decodes the attributes of the credential at the given
+// indices, like a verifier which receives a disclosure proof.
+func discloseAttributes(t *testing.T, cred *AttestedClaim, indices []int) Claim {
+	disclosed := make([]*big.Int, len(indices))
+	for i, index := range indices {
+		disclosed[i] = cred.Credential.Attributes[index]
+	}
+	attributes, err := BigIntsToAttributes(disclosed)
+	require.NoError(t, err)
+	claim, err := newClaimFromAttribute(attributes)
+	require.NoError(t, err)
+	return claim
+}
+
+func TestElementEncoding(t *testing.T) {
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"likedNumbers": []interface{}{1., 2., 3.},
+			"friends": []interface{}{
+				map[string]interface{}{"name": "Anna", "age": 30.},
+				map[string]interface{}{"name": "Bob", "age": 31.},
+			},
+			"matrix": []interface{}{[]interface{}{1., 2.}, []interface{}{}},
+			"empty":  []interface{}{},
+		},
+	}
+	attributes, err := claim.EncodeAttributes(ElementEncoding)
+	require.NoError(t, err)
+	reconstructed, err := newClaimFromAttribute(attributes)
+	require.NoError(t, err)
+	assert.Equal(t, claim, reconstructed)
+
+	cred := newElementEncodedClaim(t, claim)
+	attributes, err = cred.getAttributes()
+	require.NoError(t, err)
+	reconstructed, err = newClaimFromAttribute(attributes)
+	require.NoError(t, err)
+	assert.Equal(t, claim, reconstructed)
+
+	// single elements are disclosed together with the length of the array
+	indices, err := cred.getAttributeIndices([]string{"contents.likedNumbers.1"})
+	require.NoError(t, err)
+	assert.Len(t, indices, 2)
+	assert.Equal(t, Claim{
+		"contents": map[string]interface{}{
+			"likedNumbers": []interface{}{nil, 2., nil},
+		},
+	}, discloseAttributes(t, cred, indices))
+
+	indices, err = cred.getAttributeIndices([]string{"contents.friends.*.name", "contents.matrix.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, Claim{
+		"contents": map[string]interface{}{
+			"friends": []interface{}{
+				map[string]interface{}{"name": "Anna"},
+				map[string]interface{}{"name": "Bob"},
+			},
+			"matrix": []interface{}{[]interface{}{nil, 2.}, nil},
+		},
+	}, discloseAttributes(t, cred, indices))
+
+	// whole arrays can still be disclosed
+	indices, err = cred.getAttributeIndices([]string{"contents.likedNumbers", "contents.empty"})
+	require.NoError(t, err)
+	assert.Equal(t, Claim{
+		"contents": map[string]interface{}{
+			"likedNumbers": []interface{}{1., 2., 3.},
+			"empty":        []interface{}{},
+		},
+	}, discloseAttributes(t, cred, indices))
+
+	_, err = cred.getAttributeIndices([]string{"contents.likedNumbers.3"})
+	assert.Error(t, err)
+}
+
+func TestElementEncodingInvalid(t *testing.T) {
+	length := []byte{0, 0, 0, 0, 0, 0, 0, 2}
+	_, err := newClaimFromAttribute([]*Attribute{
+		{Name: "numbers", Typename: arrayLengthType, Value: length},
+		{Name: "numbers.2", Typename: "bool", Value: []byte{1}},
+	})
+	assert.Error(t, err, "index out of range")
+
+	_, err = newClaimFromAttribute([]*Attribute{
+		{Name: "numbers", Typename: arrayLengthType, Value: length},
+		{Name: "numbers.01", Typename: "bool", Value: []byte{1}},
+	})
+	assert.Error(t, err, "invalid index")
+
+	_, err = newClaimFromAttribute([]*Attribute{
+		{Name: "numbers", Typename: arrayLengthType, Value: []byte{1}},
+	})
+	assert.Error(t, err, "invalid length")
+
+	_, err = newClaimFromAttribute([]*Attribute{
+		{Name: "numbers", Typename: arrayLengthType, Value: []byte{0, 0, 0, 0, 0xFF, 0, 0, 0}},
+	})
+	assert.Error(t, err, "array too long")
+
+	_, err = newClaimFromAttribute([]*Attribute{
+		{Name: "numbers", Typename: arrayLengthType, Value: length},
+		{Name: "numbers", Typename: "bool", Value: []byte{1}},
+	})
+	assert.Error(t, err, "array is not an object")
+}
+
+func TestHashedAttributes(t *testing.T) {
+	// the message space of 4096 bit keys leaves room for small attributes
+	sysParams, ok := gabi.DefaultSystemParameters[4096]
+	require.True(t, ok)
+	// the value of "contents.short" fills the attribute up to the limit, the
+	// same value exceeds it together with a longer name
+	header := Attribute{Name: "contents.short", Typename: "string"}.encodedSize()
+	short := strings.Repeat("a", MaxAttributeSize(sysParams)-header)
+	long := strings.Repeat("a", MaxAttributeSize(sysParams)+1)
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"short":             short,
+			"shortWithLongName": short,
+			"long":              long,
+		},
+	}
+	attributes, preimages, err := claim.ToAttributesWithParams(sysParams, DefaultEncoding)
+	require.NoError(t, err)
+	require.Len(t, preimages, 2)
+	assert.Equal(t, "contents.long", preimages[0].Name)
+	assert.Equal(t, []byte(long), preimages[0].Value)
+	assert.Equal(t, "contents.shortWithLongName", preimages[1].Name)
+	assert.Equal(t, []byte(short), preimages[1].Value)
+	for _, attr := range attributes {
+		if attr.Name == "contents.long" || attr.Name == "contents.shortWithLongName" {
+			assert.Equal(t, hashType, attr.Typename)
+			assert.Len(t, attr.Value, 32)
+		} else {
+			assert.NotEqual(t, hashType, attr.Typename)
+			assert.LessOrEqual(t, attr.encodedSize(), MaxAttributeSize(sysParams))
+		}
+	}
+
+	// the message space of smaller keys is exceeded by every attribute
+	smallParams, ok := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, ok)
+	hashedAll, allPreimages, err := claim.ToAttributesWithParams(smallParams, DefaultEncoding)
+	require.NoError(t, err)
+	assert.Len(t, allPreimages, len(hashedAll))
+
+	// without system parameters nothing is hashed
+	unhashed, noPreimages, err := claim.ToAttributesWithParams(nil, DefaultEncoding)
+	require.NoError(t, err)
+	assert.Empty(t, noPreimages)
+	plain, err := claim.ToAttributes()
+	require.NoError(t, err)
+	assert.Equal(t, plain, unhashed)
+
+	// the hash is disclosed if the preimage is missing
+	hashedClaim, err := newClaimFromAttribute(attributes)
+	require.NoError(t, err)
+	assert.Len(t, hashedClaim["contents"].(map[string]interface{})["long"], 64)
+
+	revealed, err := applyPreimages(attributes, preimages)
+	require.NoError(t, err)
+	reconstructed, err := newClaimFromAttribute(revealed)
+	require.NoError(t, err)
+	assert.Equal(t, claim, reconstructed)
+	assert.Equal(t, hashType, attributes[0].Typename, "attributes are not modified")
+
+	tampered := *preimages[0]
+	tampered.Value = []byte(long + "b")
+	_, err = applyPreimages(attributes, []*Attribute{&tampered})
+	assert.Error(t, err, "preimage does not match the hash")
+	tampered = *preimages[0]
+	tampered.Typename = "array"
+	_, err = applyPreimages(attributes, []*Attribute{&tampered})
+	assert.Error(t, err, "hash covers the type")
+	tampered = *preimages[0]
+	tampered.Name = "contents.short"
+	_, err = applyPreimages(attributes, []*Attribute{&tampered})
+	assert.Error(t, err, "attribute is not hashed")
+	_, err = applyPreimages(attributes, []*Attribute{nil})
+	assert.Error(t, err)
+}
+
+func TestHashedAttributesPresentation(t *testing.T) {
+	sysParams, ok := gabi.DefaultSystemParameters[4096]
+	require.True(t, ok)
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"picture": strings.Repeat("ab", 100),
+			"name":    "Anna",
+		},
+	}
+	attributes, preimages, err := claim.ToAttributesWithParams(sysParams, DefaultEncoding)
+	require.NoError(t, err)
+	bInts, err := attributesToBigInts(attributes)
+	require.NoError(t, err)
+	cred := &AttestedClaim{
+		Credential: &gabi.Credential{Attributes: append([]*big.Int{big.NewInt(1)}, bInts...)},
+		Claim:      claim,
+		Preimages:  preimages,
+	}
+	attributes, err = cred.getClaimAttributes()
+	require.NoError(t, err)
+	reconstructed, err := newClaimFromAttribute(attributes)
+	require.NoError(t, err)
+	assert.Equal(t, claim, reconstructed)
+
+	indices, err := cred.getAttributeIndices([]string{"contents"})
+	require.NoError(t, err)
+	disclosed, err := cred.getPreimages(indices, nil)
+	require.NoError(t, err)
+	assert.Equal(t, preimages, disclosed)
+
+	// the verifier can request the hash only
+	disclosed, err = cred.getPreimages(indices, []string{"contents.picture"})
+	require.NoError(t, err)
+	assert.Empty(t, disclosed)
+	hashedClaim := discloseAttributes(t, cred, indices)
+	assert.Equal(t, "Anna", hashedClaim["contents"].(map[string]interface{})["name"])
+	assert.Len(t, hashedClaim["contents"].(map[string]interface{})["picture"], 64)
+
+	indices, err = cred.getAttributeIndices([]string{"contents.name"})
+	require.NoError(t, err)
+	disclosed, err = cred.getPreimages(indices, nil)
+	require.NoError(t, err)
+	assert.Empty(t, disclosed, "only preimages of disclosed attributes")
+
+	// without the preimages the claim can not be reconstructed
+	cred.Preimages = nil
+	attributes, err = cred.getClaimAttributes()
+	require.NoError(t, err)
+	reconstructed, err = newClaimFromAttribute(attributes)
+	require.NoError(t, err)
+	assert.NotEqual(t, claim, reconstructed)
+	cred.Preimages = []*Attribute{{Name: "contents.name", Typename: "string", Value: []byte("Anna")}}
+	_, err = cred.getClaimAttributes()
+	assert.Error(t, err)
+}
+
+func TestEncodeNativeValues(t *testing.T) {
+	type address struct {
+		Street  string `json:"street"`
+		Number  uint16 `json:"number"`
+		Note    string `json:"note,omitempty"`
+		private int
+	}
+	birthday := time.Date(1990, 1, 2, 3, 4, 5, 0, time.UTC)
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"age":      int32(34),
+			"height":   float32(1.5),
+			"address":  &address{Street: "Main Street", Number: 42},
+			"birthday": birthday,
+			"numbers":  []int{1, 2, 3},
+			"scores":   map[string]int{"a": 1},
+			"missing":  (*address)(nil),
+		},
+	}
+	expected := Claim{}
+	bts, err := json.Marshal(claim)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bts, &expected))
+
+	for _, encoding := range []ClaimEncoding{DefaultEncoding, ElementEncoding} {
+		attributes, err := claim.EncodeAttributes(encoding)
+		require.NoError(t, err)
+		expectedAttributes, err := expected.EncodeAttributes(encoding)
+		require.NoError(t, err)
+		assert.Equal(t, expectedAttributes, attributes)
+		reconstructed, err := newClaimFromAttribute(attributes)
+		require.NoError(t, err)
+		assert.Equal(t, expected, reconstructed)
+	}
+}
+
+func TestEncodeAttributesInvalid(t *testing.T) {
+	for value, expected := range map[interface{}]string{
+		make(chan int):                   "contents.foo: unsupported type chan int",
+		complex(1, 2):                    "contents.foo: unsupported type complex128",
+		int64(1) << 60:                   "contents.foo: integer 1152921504606846976 can not be represented exactly",
+		math.NaN():                       "contents.foo: unsupported float value NaN",
+		&struct{ C func() }{}:            "contents.foo: json: unsupported type: func()",
+		&map[int]string{1: "a"}:          "contents.foo: unsupported map key type int",
+		&[]interface{}{1, uint(1) << 60}: "contents.foo.1: integer 1152921504606846976 can not be represented exactly",
+	} {
+		claim := Claim{"contents": map[string]interface{}{"foo": value}}
+		_, err := claim.EncodeAttributes(DefaultEncoding)
+		assert.EqualError(t, err, expected)
+		var attrErr *AttributeError
+		assert.True(t, errors.As(err, &attrErr))
+		_, _, err = claim.ToAttributesWithParams(nil, ElementEncoding)
+		assert.EqualError(t, err, expected)
+		_, err = claim.ToAttributes()
+		assert.EqualError(t, err, expected)
+	}
+	_, err := Claim{}.EncodeAttributes("unknown")
+	assert.Error(t, err)
+}
+
 func TestGetMissingAttribute(t *testing.T) {
 	req := &PartialPresentationRequest{
 		ReqNonRevocationProof: true,
@@ -94,7 +500,8 @@ func TestGetMissingAttribute(t *testing.T) {
 	require.NoError(t, err)
 
 	indice, err := cred.getAttributeIndices(req.RequestedAttributes)
-	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrAttributeMissing))
+	assert.EqualError(t, err, "could not find attribute with name 'contents.gesdfer'")
 	assert.Nil(t, indice)
 }
 
@@ -109,7 +516,8 @@ func TestReconstructClaim(t *testing.T) {
 			"ups":          nil,
 		},
 	}
-	attributes := oldClaim.ToAttributes()
+	attributes, err := oldClaim.ToAttributes()
+	require.NoError(t, err)
 
 	claim, err := newClaimFromAttribute(attributes)
 	require.NoError(t, err)
@@ -126,7 +534,8 @@ func TestReconstructClaimFull(t *testing.T) {
 			"likedNumbers": []interface{}{1., 2., 3.},
 		},
 	}
-	oldAttributes := oldClaim.ToAttributes()
+	oldAttributes, err := oldClaim.ToAttributes()
+	require.NoError(t, err)
 	bigInts, err := attributesToBigInts(oldAttributes)
 	require.NoError(t, err)
 
@@ -219,6 +628,71 @@ func TestUnarshallAttributeFail(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestUnmarshalAttributeMalformed(t *testing.T) {
+	valid, err := Attribute{Name: "name", Typename: "string", Value: []byte("Anna")}.MarshalBinary()
+	require.NoError(t, err)
+	for i := 0; i < len(valid); i++ {
+		assert.Error(t, (&Attribute{}).UnmarshalBinary(valid[:i]), "truncated to %d bytes", i)
+	}
+	assert.Error(t, (&Attribute{}).UnmarshalBinary(append(valid, 0)), "trailing data")
+
+	huge := append([]byte{MagicByte}, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 1)
+	assert.Error(t, (&Attribute{}).UnmarshalBinary(huge))
+
+	_, err = BigIntsToAttributes([]*big.Int{nil})
+	assert.Error(t, err)
+}
+
+func TestDecodeClaimMalformed(t *testing.T) {
+	for _, attr := range []*Attribute{
+		nil,
+		{Name: "b", Typename: "bool", Value: []byte{}},
+		{Name: "a", Typename: "array", Value: []byte{}},
+		{Name: "f", Typename: "float", Value: []byte{1, 2}},
+		{Name: "n", Typename: arrayLengthType, Value: []byte{1}},
+	} {
+		_, err := decodeClaim([]*Attribute{attr}, false)
+		assert.Error(t, err, "%v", attr)
+		_, err = decodeClaim([]*Attribute{attr}, true)
+		assert.Error(t, err, "%v", attr)
+	}
+
+	// strict mode only accepts values like EncodeAttributes creates them
+	for _, attr := range []*Attribute{
+		{Name: "x", Typename: "unknown", Value: []byte{1}},
+		{Name: "s", Typename: "string", Value: []byte{0xFF}},
+		{Name: "b", Typename: "bool", Value: []byte{2}},
+		{Name: "b", Typename: "bool", Value: []byte{1, 0}},
+		{Name: "a", Typename: "array", Value: []byte("x[]")},
+		{Name: "f", Typename: "float", Value: []byte{0x7F, 0xF8, 0, 0, 0, 0, 0, 1}},
+		{Name: "f", Typename: "float", Value: make([]byte, 9)},
+		{Name: "h", Typename: hashType, Value: []byte{1}},
+		{Name: "z", Typename: "", Value: []byte{1}},
+	} {
+		_, err := decodeClaim([]*Attribute{attr}, false)
+		assert.NoError(t, err, "%v", attr)
+		_, err = decodeClaim([]*Attribute{attr}, true)
+		assert.Error(t, err, "%v", attr)
+	}
+
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"numbers": []interface{}{1., 2.},
+			"name":    "Anna",
+			"married": true,
+			"none":    nil,
+		},
+	}
+	for _, encoding := range []ClaimEncoding{DefaultEncoding, ElementEncoding} {
+		attributes, err := claim.EncodeAttributes(encoding)
+		require.NoError(t, err)
+		decoded, err := decodeClaim(attributes, true)
+		require.NoError(t, err)
+		assert.Equal(t, claim, decoded)
+	}
+}
+
 func TestSetNestedValue(t *testing.T) {
 	testMap := make(map[string]interface{})
 	setNestedValue(testMap, "test"+Separator+"test"+Separator+"test", 1)
@@ -3,73 +3,202 @@ package credentials
 import (
 	"errors"
 	"fmt"
+	"io"
 
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/bip39"
 	"github.com/privacybydesign/gabi"
 	"github.com/privacybydesign/gabi/big"
-	"github.com/privacybydesign/gabi/pkg/common"
+	"github.com/privacybydesign/gabi/keyproof"
 )
 
 // UserIssuanceSession stores information which are used only by the user during
-// the attestation of claims
+// the attestation of claims. HashAttributes is set if large attributes of the
+// claim are hashed (see ToAttributesWithParams), it is missing in sessions
+// which were stored by earlier versions and did not hash attributes.
 type UserIssuanceSession struct {
-	Cb    *gabi.CredentialBuilder `json:"cb"`
-	Claim Claim                   `json:"claim"`
+	Cb             *gabi.CredentialBuilder `json:"cb"`
+	Claim          Claim                   `json:"claim"`
+	Encoding       ClaimEncoding           `json:"encoding,omitempty"`
+	HashAttributes bool                    `json:"hashAttributes,omitempty"`
 }
 
-// Claimer contains information about the claimer.
+// Claimer contains information about the claimer. The nonces of the claimer
+// are read from Rand, if it is nil crypto/rand is used. Rand is not used for
+// the commitments and proofs, gabi always reads their randomness from
+// crypto/rand, so that they differ even if Rand is deterministic.
 type Claimer struct {
-	MasterSecret *big.Int `json:"MasterSecret"`
+	MasterSecret *big.Int  `json:"MasterSecret"`
+	Rand         io.Reader `json:"-"`
 }
 
 // NewClaimer generates a new secret and returns a Claimer
 func NewClaimer(sysParams *gabi.SystemParameters) (*Claimer, error) {
-	masterSecret, err := common.RandomBigInt(sysParams.Lm)
+	return NewClaimerWithRand(sysParams, nil)
+}
+
+// NewClaimerWithRand generates a new secret using the randomness read from rnd
+// and returns a Claimer, which uses rnd for its nonces. Only the secret and
+// the nonces are read from rnd, the commitments and proofs of the claimer are
+// created using crypto/rand. A deterministic rnd therefore does not make
+// attestations or presentations reproducible.
+func NewClaimerWithRand(sysParams *gabi.SystemParameters, rnd io.Reader) (*Claimer, error) {
+	masterSecret, err := randomBigInt(rnd, sysParams.Lm)
 	if err != nil {
 		return nil, err
 	}
-	return &Claimer{masterSecret}, nil
+	return &Claimer{MasterSecret: masterSecret, Rand: rnd}, nil
 }
 
-// NewClaimerFromSecret derives a secret from a given seed
+// NewClaimerFromSecret derives a secret from a given seed. The seed must
+// contain at least Lm bits, only the first Lm bits are used. The first bit of
+// the master secret is always set to ensure the bit length of the secret.
 func NewClaimerFromSecret(sysParams *gabi.SystemParameters, seed []byte) (*Claimer, error) {
 	// Lm is in bit, len returns byte
 	seedLen := uint(len(seed)) * 8
 	if seedLen < sysParams.Lm {
 		return nil, fmt.Errorf("secret to small (was %d, need %d)", seedLen, sysParams.Lm)
-	} else if seedLen > sysParams.Lm {
-		// shorten the seed if it is to long
-		seed = seed[:sysParams.Lm/8]
 	}
-	seed[0] = seed[0] | 0x80 // set the first bit to ensure desired bit length
-	bigSeed := big.NewInt(0).SetBytes(seed)
-	return &Claimer{bigSeed}, nil
+	// copy the seed so that the caller's slice is not modified
+	secret := make([]byte, sysParams.Lm/8)
+	copy(secret, seed)
+	secret[0] = secret[0] | 0x80 // set the first bit to ensure desired bit length
+	bigSeed := big.NewInt(0).SetBytes(secret)
+	return &Claimer{MasterSecret: bigSeed}, nil
+}
+
+// NewClaimerFromMnemonic restores the secret of a claimer from a BIP39 mnemonic
+// and an optional password. The first Lm bits of the substrate seed (see
+// package bip39) are used as the secret. For Lm = 256 this is the mini secret,
+// which makes the derivation identical to passing the hex encoded output of
+// mnemonicToMiniSecret to NewClaimerFromSecret.
+func NewClaimerFromMnemonic(sysParams *gabi.SystemParameters, mnemonic, password string) (*Claimer, error) {
+	seed, err := bip39.Seed(mnemonic, password)
+	if err != nil {
+		return nil, err
+	}
+	return NewClaimerFromSecret(sysParams, seed)
+}
+
+// NewClaimerWithMnemonic generates a new 12 word mnemonic and returns the
+// claimer derived from it. The mnemonic can be used to restore the claimer using
+// NewClaimerFromMnemonic.
+func NewClaimerWithMnemonic(sysParams *gabi.SystemParameters, password string) (*Claimer, string, error) {
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		return nil, "", err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, "", err
+	}
+	claimer, err := NewClaimerFromMnemonic(sysParams, mnemonic, password)
+	if err != nil {
+		return nil, "", err
+	}
+	return claimer, mnemonic, nil
 }
 
 // RequestAttestationForClaim creates a RequestAttestedClaim and a UserIssuanceSession.
-// The request should be sent to the attester.
+// The request should be sent to the attester. The context inside the startMsg
+// is checked against the session parameters and the public key of the attester.
 func (user *Claimer) RequestAttestationForClaim(attesterPubK *gabi.PublicKey, startMsg *StartSessionMsg, claim Claim) (*UserIssuanceSession, *AttestedClaimRequest, error) {
-	nonce, err := common.RandomBigInt(attesterPubK.Params.Lstatzk)
+	return user.RequestAttestationForClaimWithEncoding(attesterPubK, startMsg, claim, DefaultEncoding)
+}
+
+// RequestAttestationWithKeyProof creates a request for the attestation of the
+// claim like RequestAttestationForClaim, but only if the proof of the public
+// key of the attester is valid (see VerifyPublicKey). Verifying the proof
+// takes some time, claimers which already verified the key can use
+// RequestAttestationForClaim.
+func (user *Claimer) RequestAttestationWithKeyProof(attesterPubK *gabi.PublicKey, proof *keyproof.ValidKeyProof,
+	startMsg *StartSessionMsg, claim Claim) (*UserIssuanceSession, *AttestedClaimRequest, error) {
+	if err := VerifyPublicKey(attesterPubK, proof); err != nil {
+		return nil, nil, err
+	}
+	return user.RequestAttestationForClaim(attesterPubK, startMsg, claim)
+}
+
+// RequestAttestationForClaimWithEncoding creates a request for the attestation
+// of the claim like RequestAttestationForClaim. The claim is transformed into
+// attributes using the given encoding, e.g. ElementEncoding allows to disclose
+// the elements of arrays individually. Native Go values inside the claim are
+// converted into their json representation (see EncodeAttributes).
+func (user *Claimer) RequestAttestationForClaimWithEncoding(attesterPubK *gabi.PublicKey, startMsg *StartSessionMsg,
+	claim Claim, encoding ClaimEncoding) (*UserIssuanceSession, *AttestedClaimRequest, error) {
+	cb, claim, err := user.newCredentialBuilder(attesterPubK, startMsg, claim, encoding)
 	if err != nil {
 		return nil, nil, err
 	}
-	cb := gabi.NewCredentialBuilder(attesterPubK, startMsg.Context, user.MasterSecret, nonce)
 	commitMsg := cb.CommitToSecretAndProve(startMsg.Nonce)
 
 	return &UserIssuanceSession{
-			cb,
-			claim,
+			Cb:             cb,
+			Claim:          claim,
+			Encoding:       encoding,
+			HashAttributes: true,
 		}, &AttestedClaimRequest{
 			CommitMsg: commitMsg,
 			Claim:     claim,
+			Encoding:  encoding,
 		}, nil
 }
 
+// newCredentialBuilder checks that the claim can be attested in the session
+// started by the startMsg and returns the normalized claim together with the
+// builder of the credential.
+func (user *Claimer) newCredentialBuilder(attesterPubK *gabi.PublicKey, startMsg *StartSessionMsg,
+	claim Claim, encoding ClaimEncoding) (*gabi.CredentialBuilder, Claim, error) {
+	normalized, err := normalizeObject("", claim)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the claim is encoded once to detect values which can not be attested
+	if _, err := encodeNormalized(normalized, encoding); err != nil {
+		return nil, nil, err
+	}
+	context, err := AttestationContext(attesterPubK, startMsg.SessionID, startMsg.CTypeHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if startMsg.Context == nil || context.Cmp(startMsg.Context) != 0 {
+		return nil, nil, newError(ErrSessionMismatch, "context does not match the session parameters")
+	}
+	if startMsg.CTypeHash != "" && startMsg.CTypeHash != claimCType(normalized) {
+		return nil, nil, newError(ErrSessionMismatch, "ctype of the claim does not match the session")
+	}
+	nonce, err := randomBigInt(user.Rand, attesterPubK.Params.Lstatzk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gabi.NewCredentialBuilder(attesterPubK, startMsg.Context, user.MasterSecret, nonce), normalized, nil
+}
+
 // BuildCredential uses the signature provided by the attester to build a
-// new credential.
+// new credential. The credential is validated and must contain the claim which
+// was requested.
 func (user *Claimer) BuildCredential(signature *gabi.IssueSignatureMessage, session *UserIssuanceSession) (*AttestedClaim, error) {
-	attributes := session.Claim.ToAttributes()
+	var sysParams *gabi.SystemParameters
+	if session.HashAttributes {
+		sysParams = session.Cb.Pk.Params
+	}
+	attributes, preimages, err := session.Claim.ToAttributesWithParams(sysParams, session.Encoding)
+	if err != nil {
+		return nil, err
+	}
 
-	return NewAttestedClaim(session.Cb, attributes, signature)
+	attestedClaim, err := NewAttestedClaim(session.Cb, attributes, preimages, signature)
+	if err != nil {
+		return nil, err
+	}
+	if equal, err := attestedClaim.Claim.Equal(session.Claim); err != nil {
+		return nil, err
+	} else if !equal {
+		return nil, errors.New("attested claim does not match the requested claim")
+	}
+	if err := attestedClaim.Validate(attestedClaim.Credential.Pk, nil); err != nil {
+		return nil, err
+	}
+	return attestedClaim, nil
 }
 
 // BuildPresentation reveals the attributes which are requested by the verifier.
@@ -93,13 +222,21 @@ func (user *Claimer) BuildPresentation(pk *gabi.PublicKey, attestedClaim *Attest
 	if err != nil {
 		return nil, err
 	}
+	keyID, err := PublicKeyID(pk)
+	if err != nil {
+		return nil, err
+	}
+	preimages, err := attestedClaim.getPreimages(attrIndices, partialReq.HashedAttributes)
+	if err != nil {
+		return nil, err
+	}
 	attestedClaim.Credential.Pk = pk
 	proof, err := attestedClaim.Credential.CreateDisclosureProof(attrIndices,
 		partialReq.ReqNonRevocationProof, reqAttributes.Context, reqAttributes.Nonce)
 	if err != nil {
 		return nil, err
 	}
-	return &PresentationResponse{Proof: *proof}, nil
+	return &PresentationResponse{Proof: *proof, KeyID: keyID, Preimages: preimages}, nil
 }
 
 // BuildCombinedPresentation combines multiple credentials and builds a combined
@@ -113,11 +250,19 @@ func (user *Claimer) BuildCombinedPresentation(pubKs []*gabi.PublicKey, credenti
 		return nil, fmt.Errorf("expected %d attested claims, got %d", len(reqAttributes.PartialRequests), len(credentials))
 	}
 	proofBuilder := make([]gabi.ProofBuilder, len(reqAttributes.PartialRequests))
+	keyIDs := make([]*big.Int, len(reqAttributes.PartialRequests))
+	preimages := make([][]*Attribute, len(reqAttributes.PartialRequests))
+	hasPreimages := false
 
 	for i, partialReq := range reqAttributes.PartialRequests {
 		if len(partialReq.RequestedAttributes) < 1 {
 			return nil, fmt.Errorf("requested attributes should not be empty for the %d. credential", i+1)
 		}
+		keyID, err := PublicKeyID(pubKs[i])
+		if err != nil {
+			return nil, err
+		}
+		keyIDs[i] = keyID
 		cred := credentials[i].Credential
 		cred.Pk = pubKs[i]
 		if partialReq.ReqNonRevocationProof {
@@ -135,6 +280,11 @@ func (user *Claimer) BuildCombinedPresentation(pubKs []*gabi.PublicKey, credenti
 		if err != nil {
 			return nil, err
 		}
+		preimages[i], err = credentials[i].getPreimages(attrIndices, partialReq.HashedAttributes)
+		if err != nil {
+			return nil, err
+		}
+		hasPreimages = hasPreimages || len(preimages[i]) > 0
 		proofBuilder[i], err = cred.CreateDisclosureProofBuilder(attrIndices, partialReq.ReqNonRevocationProof)
 		if err != nil {
 			return nil, err
@@ -143,5 +293,8 @@ func (user *Claimer) BuildCombinedPresentation(pubKs []*gabi.PublicKey, credenti
 	builders := gabi.ProofBuilderList(proofBuilder)
 	prooflist := builders.BuildProofList(reqAttributes.Context, reqAttributes.Nonce, false)
 
-	return &CombinedPresentationResponse{Proof: prooflist}, nil
+	if !hasPreimages {
+		preimages = nil
+	}
+	return &CombinedPresentationResponse{Proof: prooflist, KeyIDs: keyIDs, Preimages: preimages}, nil
 }
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/bip39"
 	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -32,6 +34,59 @@ func TestClaimerFromRawSeed(t *testing.T) {
 	assert.Equal(t, sysParams.Lm, uint(secret.MasterSecret.BitLen()))
 }
 
+func TestClaimerFromMnemonic(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success, "Error in sysparams")
+
+	claimer, err := NewClaimerFromMnemonic(sysParams, mnemonic, "")
+	require.NoError(t, err)
+	assert.Equal(t, sysParams.Lm, uint(claimer.MasterSecret.BitLen()))
+
+	miniSecret, err := bip39.MiniSecret(mnemonic, "")
+	require.NoError(t, err)
+	fromSecret, err := NewClaimerFromSecret(sysParams, miniSecret)
+	require.NoError(t, err)
+	assert.Equal(t, fromSecret.MasterSecret, claimer.MasterSecret)
+
+	withPassword, err := NewClaimerFromMnemonic(sysParams, mnemonic, "password")
+	require.NoError(t, err)
+	assert.NotEqual(t, claimer.MasterSecret, withPassword.MasterSecret)
+
+	// the same mnemonic must result in the same key as in the js implementation
+	jsClaimer, err := NewClaimerFromMnemonic(sysParams, "scissors purse again yellow cabbage fat alpha come snack ripple jacket broken", "password")
+	require.NoError(t, err)
+	bts, err := json.Marshal(jsClaimer)
+	require.NoError(t, err)
+	assert.Equal(t, `{"MasterSecret":"kAQp95GiILur3Nne1KGengwhhioS9ycwRiW5xDThano="}`, string(bts))
+
+	_, err = NewClaimerFromMnemonic(sysParams, "opera initial unknown sign minimum sadness crane worth attract ginger category category", "")
+	assert.Error(t, err)
+}
+
+func TestClaimerWithMnemonic(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success, "Error in sysparams")
+
+	claimer, mnemonic, err := NewClaimerWithMnemonic(sysParams, "")
+	require.NoError(t, err)
+	restored, err := NewClaimerFromMnemonic(sysParams, mnemonic, "")
+	require.NoError(t, err)
+	assert.Equal(t, claimer.MasterSecret, restored.MasterSecret)
+}
+
+func TestClaimerFromSecretKeepsSeed(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success, "Error in sysparams")
+
+	seed := make([]byte, 32)
+	_, err := NewClaimerFromSecret(sysParams, seed)
+	require.NoError(t, err)
+	assert.Equal(t, make([]byte, 32), seed)
+
+	_, err = NewClaimerFromSecret(sysParams, seed[:31])
+	assert.Error(t, err)
+}
+
 func TestRequestSignature(t *testing.T) {
 	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
 	require.True(t, success, "Error in sysparams")
@@ -61,6 +116,75 @@ func TestRequestSignature(t *testing.T) {
 	assert.NotNil(t, session)
 }
 
+func TestRequestSignatureInvalidContext(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success, "Error in sysparams")
+
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": Claim{
+			"age": 34.,
+		},
+	}
+	claimer, err := NewClaimer(sysParams)
+	require.NoError(t, err)
+
+	// context was not derived from the session parameters
+	attesterMsg := &StartSessionMsg{}
+	err = json.Unmarshal(byteInitiatAttestation, attesterMsg)
+	require.NoError(t, err)
+	attesterMsg.Context = big.NewInt(42)
+	session, reqMsg, err := claimer.RequestAttestationForClaim(attester.PublicKey, attesterMsg, claim)
+	assert.Error(t, err)
+	assert.Nil(t, reqMsg)
+	assert.Nil(t, session)
+
+	// session was started for a different ctype
+	attesterMsg = &StartSessionMsg{}
+	err = json.Unmarshal(byteInitiatAttestation, attesterMsg)
+	require.NoError(t, err)
+	attesterMsg.CTypeHash = "0xC0FFEE"
+	attesterMsg.Context, err = AttestationContext(attester.PublicKey, attesterMsg.SessionID, attesterMsg.CTypeHash)
+	require.NoError(t, err)
+	session, reqMsg, err = claimer.RequestAttestationForClaim(attester.PublicKey, attesterMsg, claim)
+	assert.Error(t, err)
+	assert.Nil(t, reqMsg)
+	assert.Nil(t, session)
+}
+
+func TestRequestAttestationWithKeyProof(t *testing.T) {
+	attester := provenTestAttester(t)
+	_, startMsg, err := attester.InitiateAttestation()
+	require.NoError(t, err)
+	claim := Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": Claim{
+			"age": 34.,
+		},
+	}
+	claimer, err := NewClaimer(attester.PublicKey.Params)
+	require.NoError(t, err)
+
+	// keys without a valid proof are rejected
+	session, reqMsg, err := claimer.RequestAttestationWithKeyProof(attester.PublicKey, nil, startMsg, claim)
+	assert.Error(t, err)
+	assert.Nil(t, reqMsg)
+	assert.Nil(t, session)
+	fixture := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, fixture))
+	_, _, err = claimer.RequestAttestationWithKeyProof(fixture.PublicKey, attester.PublicKeyProof, startMsg, claim)
+	assert.True(t, errors.Is(err, ErrProofInvalid))
+
+	session, reqMsg, err = claimer.RequestAttestationWithKeyProof(attester.PublicKey, attester.PublicKeyProof, startMsg, claim)
+	require.NoError(t, err)
+	assert.NotNil(t, reqMsg)
+	assert.NotNil(t, session)
+}
+
 func TestBuildCredential(t *testing.T) {
 	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
 	require.True(t, success, "Error in sysparams")
@@ -105,7 +229,8 @@ func TestBuildPresentation(t *testing.T) {
 	emptyAttributes := []string{}
 	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
 	require.True(t, success, "Error in sysparams")
-	_, reqPresentation = RequestPresentation(sysParams, emptyAttributes, true, future)
+	_, reqPresentation, err = RequestPresentation(sysParams, emptyAttributes, true, future, false)
+	require.NoError(t, err)
 	_, err = claimer.BuildPresentation(attester.PublicKey, cred, reqPresentation)
 	assert.Equal(t, err, errors.New("requested attributes should not be empty"))
 }
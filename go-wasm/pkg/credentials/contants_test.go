@@ -8,13 +8,13 @@ const (
 )
 
 var (
-	byteAttester             = []byte(`{"PrivateKey":{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":1613477990,"P":"tUhKmKpPWI5BqCvDZPupCV10Grop0mX2+Hz7A704PXtTPnqptgYdFPoyt4W6RQkEMl6m/pnAV07J9OHRsy3Emw==","Q":"v4Swp0jMSaBGxrTrChzOtbZ6tzIRPjAIbrNhB8GKc4zudF78k1908fl9/60kVMMkdXNWkhCsX/PrHzrwdX8O/w==","PPrime":"WqQlTFUnrEcg1BXhsn3UhK66DV0U6TL7fD59gd6cHr2pnz1U2wMOin0ZW8LdIoSCGS9Tf0zgK6dk+nDo2ZbiTQ==","QPrime":"X8JYU6RmJNAjY1p1hQ5nWts9W5kInxgEN1mwg+DFOcZ3Oi9+Sa+6ePy+/9aSKmGSOrmrSQhWL/n1j514Or+Hfw==","ECDSA":"MHcCAQEEIBKZ+tPx5PvypdEU/RUAjnBbjzKO4YwTGIREjstxxCD0oAoGCCqGSM49AwEHoUQDQgAEGolL3bKgSiqM4578g22HfPR4IgGPN4a5iXv1KMvgMYhvRXGR5BIuki+v9yAwHIDie14QuJHv6XC6Lr2GnCzAHQ=="},"PublicKey":{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":1613477990,"N":"h57mBobHoWLxG4/YJyy1ZVlriEowZtTbG68zU2UNqGWZTyoQJGV+E9zBH+gzF0O6DLFyshUOW5XunUhr+RKRs16gfLdn7Vr6/Dg9qweMk2hSGpxb08p/F+5+lAAzPvbII3kvcjfnHmTmpKmnTxlXjEwjAuJ+6HON3mqljVs8UGU=","Z":"BbJWZTIms6oMGqUsr4a1jKlvm870AZZLA0DvmS5QCpSx5v/btsmefzDburagatx+UnaUKIzZP3e374SpxZq3MRZPzePVxxDcEp7GOusABa4Rqy6b7r/IA8+Z/IzzHinaefXdqmZ0WdyjCSZT+JViTzOI9o48n+anfhp287GCUgA=","S":"Xx6EcafQ+8Lwywqj4e20UxnFGDTK88qIDWNVSV+YDx9ImqnQB1nv/WHrQzj2JxFtOL5VuYgvamusb3NG3ZU1jBIvMsx7qoh98Nhy3mMKkBfT7JfQGHDNt0r51lGENd1ZUSVikG/RjhD1tYY0VHz1JuCscmqa+O8knbOb6l/wj2o=","G":"fxReVXXryvhRjEr5zlqLDGnDTmez4sBi/DxjTYBeH6GmUQ7Snr1QoP1LaTEhKFOZ2t+Uo401PEPUALMja8RfneX/zjHvNpKHqt6edP2fGVdBwSoAYmaYKYSsbl54SIE1gK6aRgoI5Zqd0/lA7XsnVMCGeeLUp27YHb9tVa4VRvs=","H":"cb+1Blpyhc5uwrFeoP0RFK8HsRKqLR1R/dxKBGddbEwLyGU6MOdw5lgYFEycQGNprnAN+7sj3oXQGz7BK2AS56GfY3FLwnFYYf2D8mIW0YRF81h5AfenvsacLzHJdjFKSXSlORK0TXkXGYTIgb5F8oSN0H9yyjrlG7A7iHF3Z/c=","T":"Nk+sho1PK47+0rEpCOeCqrwd2v+80+CU9He0wxtRXGgaAI3l9LIolOg298Z1VodXhAN+WFfZL7Xursff2A1/zJ0NDUdamZNPJ3IToUGWGAG9uDQkohBo4TZGRQAGExufATQxtlHyOvBJ84ZCVYHu5rgm2JwEF+2FvLSNvt+AAjg=","R":["Vjx2wS6ciNxwGimr5l+oZKTFyaYflBQpWpk6yaob0HabVb6jJJojfKFwZO9+3hz1bFl5qiVHakwlKoe4QotTry54CyNarVys/gQOyGpoI/yUobtSW9JSntl4VJEnylXYKOvwizDinI0fOPA50YnlryOiDOiIrCMHD3DsgXtwrEA=","fSurKAfVRR+DyloMtykkfJ28ikK99QfW368lGxdisGgKt/mCpViJfbN9jQVfuNlZ6nJEyqDYkGee1dro3P0/WlLTLw13D1bqPRVeSD7JscspKyKJz8bHqmqi5IPggOrt3ghunSABkqvfO86zCK/+S4bQ0RYkTKoJV+FIbomGuqk=","f0In2G4z5+wUj9PBXdT3cVmRkVDtxDdJZaVj59lx9ZhKjZvqmn49wZXE7MMpB6NcMDgemU7Grn3ApPsohSarKmH8e7brEJ5ySMoyUxHlLOPFaZn21VGuxB7nFlmtZsKlFEmy+62dl1ispLKXb83GIveuj178nkK5iJpoY+YtPH4=","axXRvx754bPFRYikItKRTSGuQPKeiA8LKLqXx/FkvgST3pX/lzWs4joHPARHP8bo2yYBsB/abJzwMVGHoqbk7kbT+/wIyWT32Pgh9ddYZnt3+W6e71XRSuSQxnoZArLTDreAsSLpXmKgZymqtZOreA7x3/5duKDQNPxCuo1ZrLY=","flDsMUtk5Q9/dRyKuJCVD7uialcdcJ9KP90VrA+YBT5zfg7FDkDzoOBukjQgfWVSzX6eJqLsKpm2veULbqlqlJRt3qfryMcwnAQ4FcODXLGlucLIMuMLDclh7Sx2RLSc6ghyWm+veFg+wvQfpftnDx8bELx9AcJNvMvpVoaSx8s=","DATUYvxDgXLQ5dTlFqh57QzdRIigDJKqq9Y1LU0IIIB3fLmu/62RP5A79yZxfdISWH+I/n+5rFR/uCPdwvNMXd7qlGIN4Yqc4JR1qg/Tl6SMFH7c0GEGgHDLvABZ3m5UyRNNTIxIJfxivj8MAYr1LvDumAVFIMulL5MTgAYZrO8="],"EpochLength":432000,"Params":{"LePrime":120,"Lh":256,"Lm":256,"Ln":1024,"Lstatzk":80,"Le":597,"LeCommit":456,"LmCommit":592,"LRA":1104,"LsCommit":593,"Lv":1700,"LvCommit":2036,"LvPrime":1104,"LvPrimeCommit":1440},"Issuer":"","ECDSA":"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEGolL3bKgSiqM4578g22HfPR4IgGPN4a5iXv1KMvgMYhvRXGR5BIuki+v9yAwHIDie14QuJHv6XC6Lr2GnCzAHQ=="}}`)
+	byteAttester             = []byte(`{"PrivateKey":{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":4102444800,"P":"tUhKmKpPWI5BqCvDZPupCV10Grop0mX2+Hz7A704PXtTPnqptgYdFPoyt4W6RQkEMl6m/pnAV07J9OHRsy3Emw==","Q":"v4Swp0jMSaBGxrTrChzOtbZ6tzIRPjAIbrNhB8GKc4zudF78k1908fl9/60kVMMkdXNWkhCsX/PrHzrwdX8O/w==","PPrime":"WqQlTFUnrEcg1BXhsn3UhK66DV0U6TL7fD59gd6cHr2pnz1U2wMOin0ZW8LdIoSCGS9Tf0zgK6dk+nDo2ZbiTQ==","QPrime":"X8JYU6RmJNAjY1p1hQ5nWts9W5kInxgEN1mwg+DFOcZ3Oi9+Sa+6ePy+/9aSKmGSOrmrSQhWL/n1j514Or+Hfw==","ECDSA":"MHcCAQEEIBKZ+tPx5PvypdEU/RUAjnBbjzKO4YwTGIREjstxxCD0oAoGCCqGSM49AwEHoUQDQgAEGolL3bKgSiqM4578g22HfPR4IgGPN4a5iXv1KMvgMYhvRXGR5BIuki+v9yAwHIDie14QuJHv6XC6Lr2GnCzAHQ=="},"PublicKey":{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":4102444800,"N":"h57mBobHoWLxG4/YJyy1ZVlriEowZtTbG68zU2UNqGWZTyoQJGV+E9zBH+gzF0O6DLFyshUOW5XunUhr+RKRs16gfLdn7Vr6/Dg9qweMk2hSGpxb08p/F+5+lAAzPvbII3kvcjfnHmTmpKmnTxlXjEwjAuJ+6HON3mqljVs8UGU=","Z":"BbJWZTIms6oMGqUsr4a1jKlvm870AZZLA0DvmS5QCpSx5v/btsmefzDburagatx+UnaUKIzZP3e374SpxZq3MRZPzePVxxDcEp7GOusABa4Rqy6b7r/IA8+Z/IzzHinaefXdqmZ0WdyjCSZT+JViTzOI9o48n+anfhp287GCUgA=","S":"Xx6EcafQ+8Lwywqj4e20UxnFGDTK88qIDWNVSV+YDx9ImqnQB1nv/WHrQzj2JxFtOL5VuYgvamusb3NG3ZU1jBIvMsx7qoh98Nhy3mMKkBfT7JfQGHDNt0r51lGENd1ZUSVikG/RjhD1tYY0VHz1JuCscmqa+O8knbOb6l/wj2o=","G":"fxReVXXryvhRjEr5zlqLDGnDTmez4sBi/DxjTYBeH6GmUQ7Snr1QoP1LaTEhKFOZ2t+Uo401PEPUALMja8RfneX/zjHvNpKHqt6edP2fGVdBwSoAYmaYKYSsbl54SIE1gK6aRgoI5Zqd0/lA7XsnVMCGeeLUp27YHb9tVa4VRvs=","H":"cb+1Blpyhc5uwrFeoP0RFK8HsRKqLR1R/dxKBGddbEwLyGU6MOdw5lgYFEycQGNprnAN+7sj3oXQGz7BK2AS56GfY3FLwnFYYf2D8mIW0YRF81h5AfenvsacLzHJdjFKSXSlORK0TXkXGYTIgb5F8oSN0H9yyjrlG7A7iHF3Z/c=","T":"Nk+sho1PK47+0rEpCOeCqrwd2v+80+CU9He0wxtRXGgaAI3l9LIolOg298Z1VodXhAN+WFfZL7Xursff2A1/zJ0NDUdamZNPJ3IToUGWGAG9uDQkohBo4TZGRQAGExufATQxtlHyOvBJ84ZCVYHu5rgm2JwEF+2FvLSNvt+AAjg=","R":["Vjx2wS6ciNxwGimr5l+oZKTFyaYflBQpWpk6yaob0HabVb6jJJojfKFwZO9+3hz1bFl5qiVHakwlKoe4QotTry54CyNarVys/gQOyGpoI/yUobtSW9JSntl4VJEnylXYKOvwizDinI0fOPA50YnlryOiDOiIrCMHD3DsgXtwrEA=","fSurKAfVRR+DyloMtykkfJ28ikK99QfW368lGxdisGgKt/mCpViJfbN9jQVfuNlZ6nJEyqDYkGee1dro3P0/WlLTLw13D1bqPRVeSD7JscspKyKJz8bHqmqi5IPggOrt3ghunSABkqvfO86zCK/+S4bQ0RYkTKoJV+FIbomGuqk=","f0In2G4z5+wUj9PBXdT3cVmRkVDtxDdJZaVj59lx9ZhKjZvqmn49wZXE7MMpB6NcMDgemU7Grn3ApPsohSarKmH8e7brEJ5ySMoyUxHlLOPFaZn21VGuxB7nFlmtZsKlFEmy+62dl1ispLKXb83GIveuj178nkK5iJpoY+YtPH4=","axXRvx754bPFRYikItKRTSGuQPKeiA8LKLqXx/FkvgST3pX/lzWs4joHPARHP8bo2yYBsB/abJzwMVGHoqbk7kbT+/wIyWT32Pgh9ddYZnt3+W6e71XRSuSQxnoZArLTDreAsSLpXmKgZymqtZOreA7x3/5duKDQNPxCuo1ZrLY=","flDsMUtk5Q9/dRyKuJCVD7uialcdcJ9KP90VrA+YBT5zfg7FDkDzoOBukjQgfWVSzX6eJqLsKpm2veULbqlqlJRt3qfryMcwnAQ4FcODXLGlucLIMuMLDclh7Sx2RLSc6ghyWm+veFg+wvQfpftnDx8bELx9AcJNvMvpVoaSx8s=","DATUYvxDgXLQ5dTlFqh57QzdRIigDJKqq9Y1LU0IIIB3fLmu/62RP5A79yZxfdISWH+I/n+5rFR/uCPdwvNMXd7qlGIN4Yqc4JR1qg/Tl6SMFH7c0GEGgHDLvABZ3m5UyRNNTIxIJfxivj8MAYr1LvDumAVFIMulL5MTgAYZrO8="],"EpochLength":432000,"Params":{"LePrime":120,"Lh":256,"Lm":256,"Ln":1024,"Lstatzk":80,"Le":597,"LeCommit":456,"LmCommit":592,"LRA":1104,"LsCommit":593,"Lv":1700,"LvCommit":2036,"LvPrime":1104,"LvPrimeCommit":1440},"Issuer":"","ECDSA":"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEGolL3bKgSiqM4578g22HfPR4IgGPN4a5iXv1KMvgMYhvRXGR5BIuki+v9yAwHIDie14QuJHv6XC6Lr2GnCzAHQ=="}}`)
 	byteUpdate               = []byte(`{"sacc":{"data":"omNNc2dYxaRiTnVYgAzm2GdboUsSkFwzRqymGEy8z6xjWeBlB4+xXYQhejC1f1686MkuzKlcQUhN8OEWJTd19495qOSiGTrgWxyV2sWk/XDcjwXT20ggbDXOl6ZfgjY1GYCphKSyImKjmOq16WPf64Y07hLQlHVJVA2iVt41OLleBVlHO1ngrU6iqmCFZUluZGV4AGRUaW1lGl5KhOlpRXZlbnRIYXNoWCISIMiMqOJlUpd1DIx4UEkjTRF0he/yjjM3TQ6I8x7ShWF7Y1NpZ1hHMEUCIFvaw7TyDKYclRGsep4no8B0cek/VFCGNR+ZZgqkGc1iAiEA0TvwYa/R2/znUeS86iuC1BjCllz5dVRWe9OOhy5MAUg=","pk":0},"e":{"i":0,"hash":"EiAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA==","e":["AQ=="]}}`)
 	byteClaimer              = []byte(`{"MasterSecret":"onIfyirb1JJTKOvFc4qMov6os2UdxyQwdRMkQl0Va5c="}`)
 	byteAttesterSession      = []byte(`{"context":"IeGT76iBN7eFNmtRMrEvF833basfkP/MnJBKvB2wuKk=","nonce":"ghbYdDD2rhY77w=="}`)
-	byteInitiatAttestation   = []byte(`{"nonce":"ghbYdDD2rhY77w==","context":"IeGT76iBN7eFNmtRMrEvF833basfkP/MnJBKvB2wuKk="}`)
+	byteInitiatAttestation   = []byte(`{"nonce":"ghbYdDD2rhY77w==","context":"7ek4wfHJuVDqzPI/qicZC1bRrRpVVmJz1avOOe+WjI8=","sessionId":"CbgFhsdiOhXf3i1FXEDbsZZvuIJSj8QEEU3wvPcn5VI="}`)
 	byteAttestationRequest   = []byte(`{"commitMsg":{"U":"P7c65Ryi/Bf3H5XQVuluHgzeBrP+WJwpMZRIolMLT7gX90plXO8wnhHI0PjX7Ov1Td9OK2y0CcJOH/MppCc9qefVcN6yBg2LUdCKxJ7sWsSltl8sHHCAciY8ksFMW1DZzbitf9IAhhU46mIIUx0GgEUYDqYrhd1GYRjaQ4Dy6mw=","n_2":"h2h56oEcsVlQqA==","combinedProofs":[{"U":"P7c65Ryi/Bf3H5XQVuluHgzeBrP+WJwpMZRIolMLT7gX90plXO8wnhHI0PjX7Ov1Td9OK2y0CcJOH/MppCc9qefVcN6yBg2LUdCKxJ7sWsSltl8sHHCAciY8ksFMW1DZzbitf9IAhhU46mIIUx0GgEUYDqYrhd1GYRjaQ4Dy6mw=","c":"0YOivdLJY4vjOgeQonOWGytW3fHQvpJZDuq0s3+q1cc=","v_prime_response":"f9VTtUYWDj/QLlGE3BQJIcyWFCcJ1ntEMIOL7XydHakF1g+QPgx3eMkqJTyF+YQVNPn7o5mYHgXTQ/9VLH6Dh+4EBkDIOPu3s/0sCKggkzC0tovO7BUfpPvUuj7zRXbAMQxmX47dan+Laatc3tkXu4TDP5LV6CF4PbWZF7Sx2XeFB0GbhkDHQ6mhz+kg41opTQCdg747sJQTo60QnuDxNV988bpFxwMkWM2rQRprP8TXZtu8","s_response":"Af0h1T5yoXf+DNRSabf6FYhTQ5Edj0V05I0YoYms3HMLlwggt9T35n/e21xuAkkfZ7TO+NpbihTdW3sRUDPSHutn84EUs5PXTPv9"}]},"claim":{"contents":{"age":34,"gender":"female","name":[{"a":1,"b":2},2,3],"special":true},"ctype":"0xDEADBEEFCOFEE"}}`)
-	byteAttestClaimerSession = []byte(`{"cb":{"Secret":"onIfyirb1JJTKOvFc4qMov6os2UdxyQwdRMkQl0Va5c=","VPrime":"8G+56oPnO6FGKviB9e5PyhgjfzM+t8WQa2xhhXaKCR1E+kwACE3G0b7AIwD47sBk6QOE8JKyyu7kND7JNDgrbPDp4Cuq++mk+1qxUpceGtn3mWsKgOsNd6Dd2jvTLsLHc9M9FeUbYm9nS4R+ZyUJsFRnwJ1Cg0A6ePme1Iw7oyALgyfBjmkydNfk","VPrimeCommit":null,"Nonce2":"h2h56oEcsVlQqA==","U":"P7c65Ryi/Bf3H5XQVuluHgzeBrP+WJwpMZRIolMLT7gX90plXO8wnhHI0PjX7Ov1Td9OK2y0CcJOH/MppCc9qefVcN6yBg2LUdCKxJ7sWsSltl8sHHCAciY8ksFMW1DZzbitf9IAhhU46mIIUx0GgEUYDqYrhd1GYRjaQ4Dy6mw=","UCommit":"AQ==","SkRandomizer":null,"Pk":{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":1613477990,"N":"h57mBobHoWLxG4/YJyy1ZVlriEowZtTbG68zU2UNqGWZTyoQJGV+E9zBH+gzF0O6DLFyshUOW5XunUhr+RKRs16gfLdn7Vr6/Dg9qweMk2hSGpxb08p/F+5+lAAzPvbII3kvcjfnHmTmpKmnTxlXjEwjAuJ+6HON3mqljVs8UGU=","Z":"BbJWZTIms6oMGqUsr4a1jKlvm870AZZLA0DvmS5QCpSx5v/btsmefzDburagatx+UnaUKIzZP3e374SpxZq3MRZPzePVxxDcEp7GOusABa4Rqy6b7r/IA8+Z/IzzHinaefXdqmZ0WdyjCSZT+JViTzOI9o48n+anfhp287GCUgA=","S":"Xx6EcafQ+8Lwywqj4e20UxnFGDTK88qIDWNVSV+YDx9ImqnQB1nv/WHrQzj2JxFtOL5VuYgvamusb3NG3ZU1jBIvMsx7qoh98Nhy3mMKkBfT7JfQGHDNt0r51lGENd1ZUSVikG/RjhD1tYY0VHz1JuCscmqa+O8knbOb6l/wj2o=","G":"fxReVXXryvhRjEr5zlqLDGnDTmez4sBi/DxjTYBeH6GmUQ7Snr1QoP1LaTEhKFOZ2t+Uo401PEPUALMja8RfneX/zjHvNpKHqt6edP2fGVdBwSoAYmaYKYSsbl54SIE1gK6aRgoI5Zqd0/lA7XsnVMCGeeLUp27YHb9tVa4VRvs=","H":"cb+1Blpyhc5uwrFeoP0RFK8HsRKqLR1R/dxKBGddbEwLyGU6MOdw5lgYFEycQGNprnAN+7sj3oXQGz7BK2AS56GfY3FLwnFYYf2D8mIW0YRF81h5AfenvsacLzHJdjFKSXSlORK0TXkXGYTIgb5F8oSN0H9yyjrlG7A7iHF3Z/c=","T":"Nk+sho1PK47+0rEpCOeCqrwd2v+80+CU9He0wxtRXGgaAI3l9LIolOg298Z1VodXhAN+WFfZL7Xursff2A1/zJ0NDUdamZNPJ3IToUGWGAG9uDQkohBo4TZGRQAGExufATQxtlHyOvBJ84ZCVYHu5rgm2JwEF+2FvLSNvt+AAjg=","R":["Vjx2wS6ciNxwGimr5l+oZKTFyaYflBQpWpk6yaob0HabVb6jJJojfKFwZO9+3hz1bFl5qiVHakwlKoe4QotTry54CyNarVys/gQOyGpoI/yUobtSW9JSntl4VJEnylXYKOvwizDinI0fOPA50YnlryOiDOiIrCMHD3DsgXtwrEA=","fSurKAfVRR+DyloMtykkfJ28ikK99QfW368lGxdisGgKt/mCpViJfbN9jQVfuNlZ6nJEyqDYkGee1dro3P0/WlLTLw13D1bqPRVeSD7JscspKyKJz8bHqmqi5IPggOrt3ghunSABkqvfO86zCK/+S4bQ0RYkTKoJV+FIbomGuqk=","f0In2G4z5+wUj9PBXdT3cVmRkVDtxDdJZaVj59lx9ZhKjZvqmn49wZXE7MMpB6NcMDgemU7Grn3ApPsohSarKmH8e7brEJ5ySMoyUxHlLOPFaZn21VGuxB7nFlmtZsKlFEmy+62dl1ispLKXb83GIveuj178nkK5iJpoY+YtPH4=","axXRvx754bPFRYikItKRTSGuQPKeiA8LKLqXx/FkvgST3pX/lzWs4joHPARHP8bo2yYBsB/abJzwMVGHoqbk7kbT+/wIyWT32Pgh9ddYZnt3+W6e71XRSuSQxnoZArLTDreAsSLpXmKgZymqtZOreA7x3/5duKDQNPxCuo1ZrLY=","flDsMUtk5Q9/dRyKuJCVD7uialcdcJ9KP90VrA+YBT5zfg7FDkDzoOBukjQgfWVSzX6eJqLsKpm2veULbqlqlJRt3qfryMcwnAQ4FcODXLGlucLIMuMLDclh7Sx2RLSc6ghyWm+veFg+wvQfpftnDx8bELx9AcJNvMvpVoaSx8s=","DATUYvxDgXLQ5dTlFqh57QzdRIigDJKqq9Y1LU0IIIB3fLmu/62RP5A79yZxfdISWH+I/n+5rFR/uCPdwvNMXd7qlGIN4Yqc4JR1qg/Tl6SMFH7c0GEGgHDLvABZ3m5UyRNNTIxIJfxivj8MAYr1LvDumAVFIMulL5MTgAYZrO8="],"EpochLength":432000,"Params":{"LePrime":120,"Lh":256,"Lm":256,"Ln":1024,"Lstatzk":80,"Le":597,"LeCommit":456,"LmCommit":592,"LRA":1104,"LsCommit":593,"Lv":1700,"LvCommit":2036,"LvPrime":1104,"LvPrimeCommit":1440},"Issuer":"","ECDSA":"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEGolL3bKgSiqM4578g22HfPR4IgGPN4a5iXv1KMvgMYhvRXGR5BIuki+v9yAwHIDie14QuJHv6XC6Lr2GnCzAHQ=="},"Context":"IeGT76iBN7eFNmtRMrEvF833basfkP/MnJBKvB2wuKk=","ProofPcomm":null},"claim":{"contents":{"age":34,"gender":"female","name":[{"a":1,"b":2},2,3],"special":true},"ctype":"0xDEADBEEFCOFEE"}}`)
+	byteAttestClaimerSession = []byte(`{"cb":{"Secret":"onIfyirb1JJTKOvFc4qMov6os2UdxyQwdRMkQl0Va5c=","VPrime":"8G+56oPnO6FGKviB9e5PyhgjfzM+t8WQa2xhhXaKCR1E+kwACE3G0b7AIwD47sBk6QOE8JKyyu7kND7JNDgrbPDp4Cuq++mk+1qxUpceGtn3mWsKgOsNd6Dd2jvTLsLHc9M9FeUbYm9nS4R+ZyUJsFRnwJ1Cg0A6ePme1Iw7oyALgyfBjmkydNfk","VPrimeCommit":null,"Nonce2":"h2h56oEcsVlQqA==","U":"P7c65Ryi/Bf3H5XQVuluHgzeBrP+WJwpMZRIolMLT7gX90plXO8wnhHI0PjX7Ov1Td9OK2y0CcJOH/MppCc9qefVcN6yBg2LUdCKxJ7sWsSltl8sHHCAciY8ksFMW1DZzbitf9IAhhU46mIIUx0GgEUYDqYrhd1GYRjaQ4Dy6mw=","UCommit":"AQ==","SkRandomizer":null,"Pk":{"XMLName":{"Space":"","Local":""},"Counter":0,"ExpiryDate":4102444800,"N":"h57mBobHoWLxG4/YJyy1ZVlriEowZtTbG68zU2UNqGWZTyoQJGV+E9zBH+gzF0O6DLFyshUOW5XunUhr+RKRs16gfLdn7Vr6/Dg9qweMk2hSGpxb08p/F+5+lAAzPvbII3kvcjfnHmTmpKmnTxlXjEwjAuJ+6HON3mqljVs8UGU=","Z":"BbJWZTIms6oMGqUsr4a1jKlvm870AZZLA0DvmS5QCpSx5v/btsmefzDburagatx+UnaUKIzZP3e374SpxZq3MRZPzePVxxDcEp7GOusABa4Rqy6b7r/IA8+Z/IzzHinaefXdqmZ0WdyjCSZT+JViTzOI9o48n+anfhp287GCUgA=","S":"Xx6EcafQ+8Lwywqj4e20UxnFGDTK88qIDWNVSV+YDx9ImqnQB1nv/WHrQzj2JxFtOL5VuYgvamusb3NG3ZU1jBIvMsx7qoh98Nhy3mMKkBfT7JfQGHDNt0r51lGENd1ZUSVikG/RjhD1tYY0VHz1JuCscmqa+O8knbOb6l/wj2o=","G":"fxReVXXryvhRjEr5zlqLDGnDTmez4sBi/DxjTYBeH6GmUQ7Snr1QoP1LaTEhKFOZ2t+Uo401PEPUALMja8RfneX/zjHvNpKHqt6edP2fGVdBwSoAYmaYKYSsbl54SIE1gK6aRgoI5Zqd0/lA7XsnVMCGeeLUp27YHb9tVa4VRvs=","H":"cb+1Blpyhc5uwrFeoP0RFK8HsRKqLR1R/dxKBGddbEwLyGU6MOdw5lgYFEycQGNprnAN+7sj3oXQGz7BK2AS56GfY3FLwnFYYf2D8mIW0YRF81h5AfenvsacLzHJdjFKSXSlORK0TXkXGYTIgb5F8oSN0H9yyjrlG7A7iHF3Z/c=","T":"Nk+sho1PK47+0rEpCOeCqrwd2v+80+CU9He0wxtRXGgaAI3l9LIolOg298Z1VodXhAN+WFfZL7Xursff2A1/zJ0NDUdamZNPJ3IToUGWGAG9uDQkohBo4TZGRQAGExufATQxtlHyOvBJ84ZCVYHu5rgm2JwEF+2FvLSNvt+AAjg=","R":["Vjx2wS6ciNxwGimr5l+oZKTFyaYflBQpWpk6yaob0HabVb6jJJojfKFwZO9+3hz1bFl5qiVHakwlKoe4QotTry54CyNarVys/gQOyGpoI/yUobtSW9JSntl4VJEnylXYKOvwizDinI0fOPA50YnlryOiDOiIrCMHD3DsgXtwrEA=","fSurKAfVRR+DyloMtykkfJ28ikK99QfW368lGxdisGgKt/mCpViJfbN9jQVfuNlZ6nJEyqDYkGee1dro3P0/WlLTLw13D1bqPRVeSD7JscspKyKJz8bHqmqi5IPggOrt3ghunSABkqvfO86zCK/+S4bQ0RYkTKoJV+FIbomGuqk=","f0In2G4z5+wUj9PBXdT3cVmRkVDtxDdJZaVj59lx9ZhKjZvqmn49wZXE7MMpB6NcMDgemU7Grn3ApPsohSarKmH8e7brEJ5ySMoyUxHlLOPFaZn21VGuxB7nFlmtZsKlFEmy+62dl1ispLKXb83GIveuj178nkK5iJpoY+YtPH4=","axXRvx754bPFRYikItKRTSGuQPKeiA8LKLqXx/FkvgST3pX/lzWs4joHPARHP8bo2yYBsB/abJzwMVGHoqbk7kbT+/wIyWT32Pgh9ddYZnt3+W6e71XRSuSQxnoZArLTDreAsSLpXmKgZymqtZOreA7x3/5duKDQNPxCuo1ZrLY=","flDsMUtk5Q9/dRyKuJCVD7uialcdcJ9KP90VrA+YBT5zfg7FDkDzoOBukjQgfWVSzX6eJqLsKpm2veULbqlqlJRt3qfryMcwnAQ4FcODXLGlucLIMuMLDclh7Sx2RLSc6ghyWm+veFg+wvQfpftnDx8bELx9AcJNvMvpVoaSx8s=","DATUYvxDgXLQ5dTlFqh57QzdRIigDJKqq9Y1LU0IIIB3fLmu/62RP5A79yZxfdISWH+I/n+5rFR/uCPdwvNMXd7qlGIN4Yqc4JR1qg/Tl6SMFH7c0GEGgHDLvABZ3m5UyRNNTIxIJfxivj8MAYr1LvDumAVFIMulL5MTgAYZrO8="],"EpochLength":432000,"Params":{"LePrime":120,"Lh":256,"Lm":256,"Ln":1024,"Lstatzk":80,"Le":597,"LeCommit":456,"LmCommit":592,"LRA":1104,"LsCommit":593,"Lv":1700,"LvCommit":2036,"LvPrime":1104,"LvPrimeCommit":1440},"Issuer":"","ECDSA":"MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEGolL3bKgSiqM4578g22HfPR4IgGPN4a5iXv1KMvgMYhvRXGR5BIuki+v9yAwHIDie14QuJHv6XC6Lr2GnCzAHQ=="},"Context":"IeGT76iBN7eFNmtRMrEvF833basfkP/MnJBKvB2wuKk=","ProofPcomm":null},"claim":{"contents":{"age":34,"gender":"female","name":[{"a":1,"b":2},2,3],"special":true},"ctype":"0xDEADBEEFCOFEE"}}`)
 	byteAttestationResponse  = []byte(`{"proof":{"c":"FjKLBzXpPxB/bdG47ZffZpNUVCExAc2HAhM0xHW3/9M=","e_response":"Abivd/9wLCN+qnt3ijOESr39TcllE7ZMywFt+08W2Vy0rLMEawzN3pyjoQM4CasC4CtpAUr5oNIOk111rWsp9NsHfNMZPbiZtIRJP76uBA0navJ39N3tjhfC3TetAwRan1tkM9xpOrKaxWOCnulDm9oI116QakG68XFwj4m44A8="},"signature":{"A":"UQFlvgZGXyZdg3JEpVmbxpCwaedbApyvh9prqsOClfrfzjKmmHchvlsohuPEyXi0v5wTkw0AG9C+IIukQPGUkcLcZyWdvf33xoPYnJqUlBs5Rv6SiBfukMIADM6EYx/mn6WhHB8GMtwBD07vLmqmX3/qnls/GveEJCUJYXuLdxo=","e":"EAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAADRuH3EtPg7iY6UHc7gC/","v":"D8BaYc1s/EciC8BtQeDeXx3rrVfh8BMq8JQI+9zD8UjI3h2xbobODWCeRWDet1U+yTdFtfgS7sdVWWuHynXF9sYPZ3JddwqVZh+W9RZvUFUq4IQ1r6yjsH8DgA6zKOD9+A95/lqTo+4S0uJ90FHGEG8vdEQhbm3vwANf05TotqHS4ObdeMuIX89ZWMFkIs5/0E2TIv9VpRqVL/SrWbxTVWEUWVsnBX3uet3AJC4KTS9broywbkp4LJkK3YRkLjtxj5l8PEDos/BYEh4NYGp2XMhBzDJL","KeyshareP":null},"nonrev":{"u":"O9VsP8l58FjTcUqDPP9yDRpNMEkD5ll/DLPpA1iYuXgQB7ZP8wTsGAIVQp1kfXys1oSJpBMeTehj8eIek/NEw+wfsad06O5dyRTtpf1UzwGroTlZ9VcV5pesuSIs5bjU6N6N1llFVehIXpbqW09Ir2kN0n6DUmNsVlep7++fX0M=","e":"ArxaIkdPjgX9Z+k7C6w8PO1HGxrEQSrYEQ==","sacc":{"data":"omNNc2dYxaRiTnVYgAzm2GdboUsSkFwzRqymGEy8z6xjWeBlB4+xXYQhejC1f1686MkuzKlcQUhN8OEWJTd19495qOSiGTrgWxyV2sWk/XDcjwXT20ggbDXOl6ZfgjY1GYCphKSyImKjmOq16WPf64Y07hLQlHVJVA2iVt41OLleBVlHO1ngrU6iqmCFZUluZGV4AGRUaW1lGl5KhOlpRXZlbnRIYXNoWCISIMiMqOJlUpd1DIx4UEkjTRF0he/yjjM3TQ6I8x7ShWF7Y1NpZ1hHMEUCIFvaw7TyDKYclRGsep4no8B0cek/VFCGNR+ZZgqkGc1iAiEA0TvwYa/R2/znUeS86iuC1BjCllz5dVRWe9OOhy5MAUg=","pk":0},"Updated":"2020-02-17T13:19:53+01:00"}}`)
 	byteCredential           = []byte(`{"credential":{"signature":{"A":"UQFlvgZGXyZdg3JEpVmbxpCwaedbApyvh9prqsOClfrfzjKmmHchvlsohuPEyXi0v5wTkw0AG9C+IIukQPGUkcLcZyWdvf33xoPYnJqUlBs5Rv6SiBfukMIADM6EYx/mn6WhHB8GMtwBD07vLmqmX3/qnls/GveEJCUJYXuLdxo=","e":"EAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAADRuH3EtPg7iY6UHc7gC/","v":"D8BaYc1s/EciC8BtQeDeXx3rrVfh8BMq8JQI+9zD8UjI3h2xbobODWCeRWDet1U+yTdFtfgS7sdVWWuHynXF9sYPZ3JddwqVZh+X5YYpOtkSHCV72qUlpm1TSibWqBQ8r9UKacb1KWSc2//Cyp3GGLz2RgLhkW7orsPEvJhtpzSFq9XBrQpRlAeExbJOAvoqzDc4HloG97GzSs6i8ydd1kwh0PwE37nBqaCHmAFHYxR3EPwXuc72k74UjdjL7ti0Etm2tTqHiHyTtT4Y45I36zF0QQov","KeyshareP":null},"attributes":["onIfyirb1JJTKOvFc4qMov6os2UdxyQwdRMkQl0Va5c=","/wAAAAAAAAAMY29udGVudHMuYWdlAAAAAAAAAAVmbG9hdAAAAAAAAAAIQEEAAAAAAAA=","/wAAAAAAAAAPY29udGVudHMuZ2VuZGVyAAAAAAAAAAZzdHJpbmcAAAAAAAAABmZlbWFsZQ==","/wAAAAAAAAANY29udGVudHMubmFtZQAAAAAAAAAFYXJyYXkAAAAAAAAAFP9beyJhIjoxLCJiIjoyfSwyLDNd","/wAAAAAAAAAQY29udGVudHMuc3BlY2lhbAAAAAAAAAAEYm9vbAAAAAAAAAABAQ==","/wAAAAAAAAAFY3R5cGUAAAAAAAAABnN0cmluZwAAAAAAAAAPMHhERUFEQkVFRkNPRkVF"],"nonrevWitness":{"u":"O9VsP8l58FjTcUqDPP9yDRpNMEkD5ll/DLPpA1iYuXgQB7ZP8wTsGAIVQp1kfXys1oSJpBMeTehj8eIek/NEw+wfsad06O5dyRTtpf1UzwGroTlZ9VcV5pesuSIs5bjU6N6N1llFVehIXpbqW09Ir2kN0n6DUmNsVlep7++fX0M=","e":"ArxaIkdPjgX9Z+k7C6w8PO1HGxrEQSrYEQ==","sacc":{"data":"omNNc2dYxaRiTnVYgAzm2GdboUsSkFwzRqymGEy8z6xjWeBlB4+xXYQhejC1f1686MkuzKlcQUhN8OEWJTd19495qOSiGTrgWxyV2sWk/XDcjwXT20ggbDXOl6ZfgjY1GYCphKSyImKjmOq16WPf64Y07hLQlHVJVA2iVt41OLleBVlHO1ngrU6iqmCFZUluZGV4AGRUaW1lGl5KhOlpRXZlbnRIYXNoWCISIMiMqOJlUpd1DIx4UEkjTRF0he/yjjM3TQ6I8x7ShWF7Y1NpZ1hHMEUCIFvaw7TyDKYclRGsep4no8B0cek/VFCGNR+ZZgqkGc1iAiEA0TvwYa/R2/znUeS86iuC1BjCllz5dVRWe9OOhy5MAUg=","pk":0},"Updated":"2020-02-17T13:19:53+01:00"}},"claim":{"contents":{"age":34,"gender":"female","name":[{"a":1,"b":2},2,3],"special":true},"ctype":"0xDEADBEEFCOFEE"}}`)
 	byteClaim                = []byte(`{"contents":{"age":34,"gender":"female","name":[{"a":1,"b":2},2,3],"special":true},"ctype":"0xDEADBEEFCOFEE"}`)
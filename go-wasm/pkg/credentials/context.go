@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"errors"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/pkg/common"
+)
+
+// attestationContextTag separates the attestation context from other hashes
+// which are computed over the same values.
+var attestationContextTag = new(big.Int).SetBytes([]byte("portablegabi/attestation-context/v1"))
+
+// PublicKeyID returns an identifier for the given public key of an attester.
+//
+// The identifier is the SHA-256 hash over the ASN.1 encoding of the list
+// [N, Z, S, R_0, ..., R_n, Counter] (see common.HashCommit).
+func PublicKeyID(attesterPubK *gabi.PublicKey) (*big.Int, error) {
+	if attesterPubK == nil || attesterPubK.N == nil || attesterPubK.Z == nil || attesterPubK.S == nil {
+		return nil, errors.New("incomplete public key")
+	}
+	values := []*big.Int{attesterPubK.N, attesterPubK.Z, attesterPubK.S}
+	for _, r := range attesterPubK.R {
+		if r == nil {
+			return nil, errors.New("incomplete public key")
+		}
+		values = append(values, r)
+	}
+	values = append(values, new(big.Int).SetUint64(uint64(attesterPubK.Counter)))
+	return common.HashCommit(values, false), nil
+}
+
+// AttestationContext derives the context of an attestation session.
+//
+// The context is the SHA-256 hash over the ASN.1 encoding of the list
+// [tag, PublicKeyID(attesterPubK), sessionID, cTypeHash] where tag is the
+// big-endian integer of the string "portablegabi/attestation-context/v1" and
+// cTypeHash is the big-endian integer of the UTF-8 bytes of the ctype hash. An
+// empty ctype hash is encoded as 0. The resulting context has a length of 256
+// bits, which equals Lh for all default system parameters.
+func AttestationContext(attesterPubK *gabi.PublicKey, sessionID *big.Int, cTypeHash string) (*big.Int, error) {
+	if sessionID == nil {
+		return nil, errors.New("missing session id")
+	}
+	keyID, err := PublicKeyID(attesterPubK)
+	if err != nil {
+		return nil, err
+	}
+	return common.HashCommit([]*big.Int{
+		attestationContextTag,
+		keyID,
+		sessionID,
+		new(big.Int).SetBytes([]byte(cTypeHash)),
+	}, false), nil
+}
+
+// claimCType returns the ctype of the claim or an empty string if the claim
+// does not specify a ctype.
+func claimCType(claim Claim) string {
+	if cType, ok := claim["ctype"].(string); ok {
+		return cType
+	}
+	return ""
+}
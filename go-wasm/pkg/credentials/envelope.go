@@ -0,0 +1,208 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+)
+
+// ProtocolVersion is the version of the protocol messages created by this
+// package. Messages without an envelope are treated as version 0.
+const ProtocolVersion = 1
+
+// MessageType identifies the message contained in an envelope.
+type MessageType string
+
+// The message types which can be wrapped into an envelope.
+const (
+	MsgStartSession                 MessageType = "startSession"
+	MsgAttestedClaimRequest         MessageType = "attestedClaimRequest"
+	MsgAttestation                  MessageType = "attestation"
+	MsgPresentationRequest          MessageType = "presentationRequest"
+	MsgCombinedPresentationRequest  MessageType = "combinedPresentationRequest"
+	MsgPresentationResponse         MessageType = "presentationResponse"
+	MsgCombinedPresentationResponse MessageType = "combinedPresentationResponse"
+)
+
+// Envelope wraps a protocol message together with its type and the version of
+// the protocol. The session id is optional and can be used to assign the
+// message to a session.
+type Envelope struct {
+	Type      MessageType     `json:"type"`
+	Version   int             `json:"version"`
+	SessionID *big.Int        `json:"sessionId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// newMessage returns a pointer to an empty message of the given type.
+func newMessage(msgType MessageType) (interface{}, error) {
+	switch msgType {
+	case MsgStartSession:
+		return &StartSessionMsg{}, nil
+	case MsgAttestedClaimRequest:
+		return &AttestedClaimRequest{}, nil
+	case MsgAttestation:
+		return &gabi.IssueSignatureMessage{}, nil
+	case MsgPresentationRequest:
+		return &PresentationRequest{}, nil
+	case MsgCombinedPresentationRequest:
+		return &CombinedPresentationRequest{}, nil
+	case MsgPresentationResponse:
+		return &PresentationResponse{}, nil
+	case MsgCombinedPresentationResponse:
+		return &CombinedPresentationResponse{}, nil
+	default:
+		return nil, fmt.Errorf("unknown message type %q", msgType)
+	}
+}
+
+// messageType returns the type of the message.
+func messageType(msg interface{}) (MessageType, error) {
+	switch msg.(type) {
+	case *StartSessionMsg:
+		return MsgStartSession, nil
+	case *AttestedClaimRequest:
+		return MsgAttestedClaimRequest, nil
+	case *gabi.IssueSignatureMessage:
+		return MsgAttestation, nil
+	case *PresentationRequest:
+		return MsgPresentationRequest, nil
+	case *CombinedPresentationRequest:
+		return MsgCombinedPresentationRequest, nil
+	case *PresentationResponse:
+		return MsgPresentationResponse, nil
+	case *CombinedPresentationResponse:
+		return MsgCombinedPresentationResponse, nil
+	default:
+		return "", fmt.Errorf("%T is not a protocol message", msg)
+	}
+}
+
+// NewEnvelope wraps the message into an envelope of the current protocol
+// version. The session id is optional.
+func NewEnvelope(msg interface{}, sessionID *big.Int) (*Envelope, error) {
+	msgType, err := messageType(msg)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Type:      msgType,
+		Version:   ProtocolVersion,
+		SessionID: sessionID,
+		Payload:   payload,
+	}, nil
+}
+
+// DecodeMessage parses an envelope and returns it together with the decoded
+// message. The type of the message depends on the type of the envelope.
+// Messages without an envelope are rejected, since their type is unknown.
+func DecodeMessage(data []byte) (*Envelope, interface{}, error) {
+	envelope, err := parseEnvelope(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if envelope == nil {
+		return nil, nil, errors.New("message has no envelope, the message type is unknown")
+	}
+	msg, err := newMessage(envelope.Type)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := envelope.open(msg); err != nil {
+		return nil, nil, err
+	}
+	return envelope, msg, nil
+}
+
+// DecodeMessageAs decodes the message into msg, which must be a pointer to a
+// protocol message. The data is either an envelope containing a message of the
+// same type or a message without envelope, which is migrated from version 0.
+// The returned envelope contains the migrated message.
+func DecodeMessageAs(data []byte, msg interface{}) (*Envelope, error) {
+	msgType, err := messageType(msg)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if envelope == nil {
+		envelope = &Envelope{
+			Type:    msgType,
+			Version: 0,
+			Payload: data,
+		}
+	}
+	if envelope.Type != msgType {
+		return nil, fmt.Errorf("expected message of type %q, got %q", msgType, envelope.Type)
+	}
+	if err := envelope.open(msg); err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// parseEnvelope parses the envelope. It returns nil if the data does not
+// contain an envelope.
+func parseEnvelope(data []byte) (*Envelope, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid message: %v", err)
+	}
+	_, hasType := fields["type"]
+	_, hasPayload := fields["payload"]
+	if !hasType || !hasPayload {
+		return nil, nil
+	}
+	envelope := &Envelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %v", err)
+	}
+	return envelope, nil
+}
+
+// open migrates the payload to the current protocol version and decodes it
+// into msg.
+func (envelope *Envelope) open(msg interface{}) error {
+	switch {
+	case envelope.Version > ProtocolVersion:
+		return fmt.Errorf("message version %d is newer than the supported version %d", envelope.Version, ProtocolVersion)
+	case envelope.Version < 0:
+		return fmt.Errorf("invalid message version %d", envelope.Version)
+	}
+	if err := json.Unmarshal(envelope.Payload, msg); err != nil {
+		return fmt.Errorf("invalid %s message: %v", envelope.Type, err)
+	}
+	if envelope.Version == 0 {
+		if err := migrateV0(msg); err != nil {
+			return err
+		}
+		envelope.Version = ProtocolVersion
+	}
+	if startMsg, ok := msg.(*StartSessionMsg); ok {
+		if envelope.SessionID == nil {
+			envelope.SessionID = startMsg.SessionID
+		} else if startMsg.SessionID == nil || envelope.SessionID.Cmp(startMsg.SessionID) != 0 {
+			return errors.New("session id of the envelope does not match the message")
+		}
+	}
+	return nil
+}
+
+// migrateV0 migrates a message without envelope to version 1. The payloads of
+// both versions are identical except for the start session message, which
+// requires the session id since version 1.
+func migrateV0(msg interface{}) error {
+	if startMsg, ok := msg.(*StartSessionMsg); ok && startMsg.SessionID == nil {
+		return errors.New("version 0 start session message without session id can not be migrated, the attestation has to be restarted")
+	}
+	return nil
+}
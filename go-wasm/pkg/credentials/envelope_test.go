@@ -0,0 +1,81 @@
+package credentials
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/privacybydesign/gabi/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	request := &PresentationRequest{}
+	require.NoError(t, json.Unmarshal(bytePresentationRequest, request))
+
+	envelope, err := NewEnvelope(request, big.NewInt(42))
+	require.NoError(t, err)
+	assert.Equal(t, MsgPresentationRequest, envelope.Type)
+	assert.Equal(t, ProtocolVersion, envelope.Version)
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+
+	decodedEnvelope, msg, err := DecodeMessage(data)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(42), decodedEnvelope.SessionID)
+	assert.Equal(t, request, msg)
+
+	decoded := &PresentationRequest{}
+	_, err = DecodeMessageAs(data, decoded)
+	require.NoError(t, err)
+	assert.Equal(t, request, decoded)
+
+	// wrong message type
+	_, err = DecodeMessageAs(data, &PresentationResponse{})
+	assert.Error(t, err)
+
+	_, err = NewEnvelope(&Claimer{}, nil)
+	assert.Error(t, err)
+}
+
+func TestEnvelopeVersions(t *testing.T) {
+	// messages without envelope are migrated if the type is known
+	decoded := &PresentationRequest{}
+	envelope, err := DecodeMessageAs(bytePresentationRequest, decoded)
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolVersion, envelope.Version)
+	assert.Equal(t, MsgPresentationRequest, envelope.Type)
+
+	_, _, err = DecodeMessage(bytePresentationRequest)
+	assert.Error(t, err)
+
+	// start session messages without session id can not be migrated
+	_, err = DecodeMessageAs([]byte(`{"nonce":"ghbYdDD2rhY77w==","context":"7ek4wfHJuVDqzPI/qicZC1bRrRpVVmJz1avOOe+WjI8="}`), &StartSessionMsg{})
+	assert.Error(t, err)
+	startMsg := &StartSessionMsg{}
+	envelope, err = DecodeMessageAs(byteInitiatAttestation, startMsg)
+	require.NoError(t, err)
+	assert.Equal(t, startMsg.SessionID, envelope.SessionID)
+
+	// newer versions are rejected
+	newer := []byte(`{"type":"presentationRequest","version":2,"payload":{}}`)
+	_, _, err = DecodeMessage(newer)
+	assert.Error(t, err)
+	_, err = DecodeMessageAs(newer, &PresentationRequest{})
+	assert.Error(t, err)
+
+	// unknown type
+	_, _, err = DecodeMessage([]byte(`{"type":"unknown","version":1,"payload":{}}`))
+	assert.Error(t, err)
+}
+
+func TestEnvelopeSessionIDMismatch(t *testing.T) {
+	startMsg := &StartSessionMsg{}
+	require.NoError(t, json.Unmarshal(byteInitiatAttestation, startMsg))
+	envelope, err := NewEnvelope(startMsg, big.NewInt(1))
+	require.NoError(t, err)
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	_, _, err = DecodeMessage(data)
+	assert.Error(t, err)
+}
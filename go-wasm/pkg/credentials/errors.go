@@ -0,0 +1,67 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors which describe why an operation failed. They are wrapped by the
+// errors returned from this package and can be checked using errors.Is.
+var (
+	// ErrProofInvalid is returned if a proof or a signature could not be
+	// verified, e.g. because it was tampered with or was created for a
+	// different session.
+	ErrProofInvalid = errors.New("proof is invalid")
+	// ErrAccumulatorStale is returned if the accumulator of a proof or a
+	// witness does not match the latest accumulator of the attester.
+	ErrAccumulatorStale = errors.New("accumulator is stale")
+	// ErrAttributeMissing is returned if a requested attribute is not part of
+	// the credential.
+	ErrAttributeMissing = errors.New("attribute is missing")
+	// ErrKeyExpired is returned if the public key of the attester expired.
+	ErrKeyExpired = errors.New("key expired")
+	// ErrKeyUnknown is returned if a key could not be found.
+	ErrKeyUnknown = errors.New("key is unknown")
+	// ErrSessionMismatch is returned if a message does not belong to the
+	// session, e.g. because the context or the ctype differs.
+	ErrSessionMismatch = errors.New("message does not match the session")
+	// ErrUntrusted is returned if the attester is not trusted.
+	ErrUntrusted = errors.New("attester is not trusted")
+)
+
+// Error is an error of one of the kinds above. The message describes the
+// failure in detail, Kind is used by errors.Is.
+type Error struct {
+	Kind error
+	Msg  string
+}
+
+func (err *Error) Error() string {
+	return err.Msg
+}
+
+// Unwrap returns the kind of the error.
+func (err *Error) Unwrap() error {
+	return err.Kind
+}
+
+// newError creates an Error of the given kind.
+func newError(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+// AttributeError is returned if an attribute of a claim could not be encoded.
+// Name is the name of the attribute (see Separator).
+type AttributeError struct {
+	Name string
+	Err  error
+}
+
+func (err *AttributeError) Error() string {
+	return err.Name + ": " + err.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (err *AttributeError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,32 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	err := newError(ErrProofInvalid, "proof %d could not be verified", 1)
+	assert.EqualError(t, err, "proof 1 could not be verified")
+	assert.True(t, errors.Is(err, ErrProofInvalid))
+	assert.False(t, errors.Is(err, ErrAccumulatorStale))
+
+	wrapped := fmt.Errorf("1. proof: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrProofInvalid))
+	var credErr *Error
+	assert.True(t, errors.As(wrapped, &credErr))
+	assert.Equal(t, ErrProofInvalid, credErr.Kind)
+}
+
+func TestAttributeError(t *testing.T) {
+	err := &AttributeError{Name: "contents.age", Err: ErrAttributeMissing}
+	assert.EqualError(t, err, "contents.age: attribute is missing")
+	assert.True(t, errors.Is(err, ErrAttributeMissing))
+
+	var attrErr *AttributeError
+	assert.True(t, errors.As(fmt.Errorf("invalid claim: %w", err), &attrErr))
+	assert.Equal(t, "contents.age", attrErr.Name)
+}
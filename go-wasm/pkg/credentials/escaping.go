@@ -1,24 +1,30 @@
 package credentials
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
-func escapedSplit(s string, sep rune) []string {
-	slices := escapedSplitBytes([]byte(s), (byte)(sep))
+func escapedSplit(s string, sep rune) ([]string, error) {
+	slices, err := escapedSplitBytes([]byte(s), (byte)(sep))
+	if err != nil {
+		return nil, err
+	}
 	strSlices := make([]string, len(slices))
 	for i, s := range slices {
 		strSlices[i] = string(s)
 	}
-	return strSlices
+	return strSlices, nil
 }
 
-func escapedSplitBytes(s []byte, sep byte) [][]byte {
+func escapedSplitBytes(s []byte, sep byte) ([][]byte, error) {
 	backslash := `\`[0]
 	var slices [][]byte
 	lastSplit := 0
 	backslashes := 0
 
 	if sep == backslash {
-		panic("sep must not equal '\\'")
+		return nil, errors.New("separator must not be a backslash")
 	}
 	for i, r := range s {
 		if r == sep && (backslashes == 0 || backslashes%2 == 0) {
@@ -34,7 +40,7 @@ func escapedSplitBytes(s []byte, sep byte) [][]byte {
 		}
 	}
 	slices = append(slices, s[lastSplit:len(s)])
-	return slices
+	return slices, nil
 }
 
 func unescape(s string, escaped rune) string {
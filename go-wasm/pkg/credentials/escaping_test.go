@@ -12,29 +12,40 @@ func TestEscapedSplit(t *testing.T) {
 	b := "oipoi23o"
 	c := "界a界世" // hopefully not an insult!
 
-	seq := escapedSplit(a+Separator+b+Separator+c, rune(Separator[0]))
+	seq, err := escapedSplit(a+Separator+b+Separator+c, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{a, b, c}, seq)
 
-	seq = escapedSplit(a+"\\"+Separator+b+Separator+c, rune(Separator[0]))
+	seq, err = escapedSplit(a+"\\"+Separator+b+Separator+c, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{a + "\\" + Separator + b, c}, seq)
 
-	seq = escapedSplit(a+"\\\\"+Separator+b+Separator+c, rune(Separator[0]))
+	seq, err = escapedSplit(a+"\\\\"+Separator+b+Separator+c, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{a + "\\\\", b, c}, seq)
 
-	seq = escapedSplit(a+"\\\\\\"+Separator+b+Separator+c, rune(Separator[0]))
+	seq, err = escapedSplit(a+"\\\\\\"+Separator+b+Separator+c, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{a + "\\\\\\" + Separator + b, c}, seq)
 
-	seq = escapedSplit(a+"\t\t\t\t"+Separator+b+Separator+c, rune(Separator[0]))
+	seq, err = escapedSplit(a+"\t\t\t\t"+Separator+b+Separator+c, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{a + "\t\t\t\t", b, c}, seq)
 
-	seq = escapedSplit(a+"\\t\\"+Separator+b+Separator+c, rune(Separator[0]))
+	seq, err = escapedSplit(a+"\\t\\"+Separator+b+Separator+c, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{a + "\\t\\" + Separator + b, c}, seq)
 
-	seq = escapedSplit(Separator+Separator+c+Separator, rune(Separator[0]))
+	seq, err = escapedSplit(Separator+Separator+c+Separator, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{"", "", c, ""}, seq)
 
-	seq = escapedSplit(Separator+c+Separator+Separator, rune(Separator[0]))
+	seq, err = escapedSplit(Separator+c+Separator+Separator, rune(Separator[0]))
+	require.NoError(t, err)
 	assert.Equal(t, []string{"", c, "", ""}, seq)
+
+	_, err = escapedSplit(a+"\\"+b, '\\')
+	assert.Error(t, err, "backslash can not be used as separator")
 }
 
 func TestSeparator(t *testing.T) {
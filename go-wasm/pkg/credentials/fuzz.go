@@ -0,0 +1,68 @@
+// +build gofuzz
+
+package credentials
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/privacybydesign/gabi/big"
+)
+
+// The functions in this file are the entry points for go-fuzz
+// (https://github.com/dvyukov/go-fuzz), e.g.
+//
+//	go-fuzz-build -func FuzzBigIntsToAttributes
+//	go-fuzz -bin credentials-fuzz.zip -workdir testdata/fuzz/FuzzBigIntsToAttributes
+//
+// The initial corpus of every function is stored in testdata/fuzz.
+
+// FuzzBigIntsToAttributes decodes the data like a disclosed attribute inside a
+// proof.
+func FuzzBigIntsToAttributes(data []byte) int {
+	attributes, err := BigIntsToAttributes([]*big.Int{new(big.Int).SetBytes(data)})
+	if err != nil {
+		return 0
+	}
+	if _, err := decodeClaim(attributes, true); err != nil {
+		return 0
+	}
+	return 1
+}
+
+// FuzzEscapedSplit splits the data using the first byte as separator.
+func FuzzEscapedSplit(data []byte) int {
+	if len(data) < 1 {
+		return 0
+	}
+	sep, s := data[0], string(data[1:])
+	parts, err := escapedSplit(s, rune(sep))
+	if err != nil {
+		return 0
+	}
+	if strings.Join(parts, string([]byte{sep})) != s {
+		panic("split parts do not join to the input")
+	}
+	return 1
+}
+
+// FuzzNewClaimFromAttribute decodes a json encoded list of attributes into a
+// claim.
+func FuzzNewClaimFromAttribute(data []byte) int {
+	var attributes []*Attribute
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return 0
+	}
+	claim, err := newClaimFromAttribute(attributes)
+	if err != nil {
+		return 0
+	}
+	if _, err := decodeClaim(attributes, true); err == nil {
+		// strictly decoded claims can be encoded again
+		if _, err := claim.EncodeAttributes(DefaultEncoding); err != nil {
+			panic(err)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package credentials
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/privacybydesign/gabi/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readCorpus returns the inputs of the fuzzing corpus of the given fuzz
+// function (see fuzz.go).
+func readCorpus(t *testing.T, fuzzFunc string) map[string][]byte {
+	files, err := filepath.Glob(filepath.Join("testdata", "fuzz", fuzzFunc, "corpus", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	corpus := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		corpus[filepath.Base(file)] = data
+	}
+	return corpus
+}
+
+func TestFuzzCorpusBigIntsToAttributes(t *testing.T) {
+	for name, data := range readCorpus(t, "FuzzBigIntsToAttributes") {
+		assert.NotPanics(t, func() {
+			attributes, err := BigIntsToAttributes([]*big.Int{new(big.Int).SetBytes(data)})
+			if err != nil {
+				return
+			}
+			_, _ = decodeClaim(attributes, false)
+			_, _ = decodeClaim(attributes, true)
+		}, name)
+	}
+}
+
+func TestFuzzCorpusEscapedSplit(t *testing.T) {
+	for name, data := range readCorpus(t, "FuzzEscapedSplit") {
+		sep, s := data[0], string(data[1:])
+		assert.NotPanics(t, func() {
+			parts, err := escapedSplit(s, rune(sep))
+			if err == nil {
+				assert.Equal(t, s, strings.Join(parts, string([]byte{sep})), name)
+			}
+		}, name)
+	}
+}
+
+func TestFuzzCorpusNewClaimFromAttribute(t *testing.T) {
+	for name, data := range readCorpus(t, "FuzzNewClaimFromAttribute") {
+		var attributes []*Attribute
+		require.NoError(t, json.Unmarshal(data, &attributes), name)
+		assert.NotPanics(t, func() {
+			claim, err := newClaimFromAttribute(attributes)
+			if _, strictErr := decodeClaim(attributes, true); strictErr == nil {
+				require.NoError(t, err, name)
+				_, err = claim.EncodeAttributes(DefaultEncoding)
+				assert.NoError(t, err, name)
+			}
+		}, name)
+	}
+}
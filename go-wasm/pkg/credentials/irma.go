@@ -0,0 +1,191 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+)
+
+// NewAttesterFromXML imports the keys of an attester from the IRMA key format
+// (PublicKey.xml and PrivateKey.xml). Both keys must have the same counter and
+// belong to each other. IRMA keys without revocation support are extended by a
+// revocation key pair, in this case the exported public key replaces the
+// original one.
+func NewAttesterFromXML(publicKeyXML, privateKeyXML []byte) (*Attester, error) {
+	pubK, err := gabi.NewPublicKeyFromBytes(publicKeyXML)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	privK, err := gabi.NewPrivateKeyFromXML(string(privateKeyXML))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	if pubK.Counter != privK.Counter {
+		return nil, fmt.Errorf("counter of the public key (%d) does not match the private key (%d)", pubK.Counter, privK.Counter)
+	}
+	if privK.P == nil || privK.Q == nil || pubK.N.Cmp(new(big.Int).Mul(privK.P, privK.Q)) != 0 {
+		return nil, errors.New("private key does not belong to the public key")
+	}
+	if !pubK.RevocationSupported() || !privK.RevocationSupported() {
+		if pubK.RevocationSupported() || privK.RevocationSupported() {
+			return nil, errors.New("revocation is only supported by one of the keys")
+		}
+		if err := gabi.GenerateRevocationKeypair(privK, pubK); err != nil {
+			return nil, err
+		}
+	}
+	// the xml names are only needed for the xml encoding
+	pubK.XMLName = xml.Name{}
+	privK.XMLName = xml.Name{}
+	return &Attester{
+		PrivateKey: privK,
+		PublicKey:  pubK,
+	}, nil
+}
+
+// PublicKeyXML exports the public key of the attester in the IRMA key format.
+func (attester *Attester) PublicKeyXML() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := attester.PublicKey.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PrivateKeyXML exports the private key of the attester in the IRMA key format.
+func (attester *Attester) PrivateKeyXML() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := attester.PrivateKey.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type (
+	// CredentialType is an IRMA credential type description (description.xml
+	// of a credential inside an IRMA scheme). It describes the layout of a
+	// claim: the identifier of the credential type is stored as "ctype" and the
+	// attributes are stored as strings inside "contents", e.g. the attribute
+	// "firstname" is the attribute "contents.firstname" of the claim.
+	CredentialType struct {
+		XMLName         xml.Name                  `xml:"IssueSpecification"`
+		SchemeManagerID string                    `xml:"SchemeManager"`
+		IssuerID        string                    `xml:"IssuerID"`
+		ID              string                    `xml:"CredentialID"`
+		Attributes      []CredentialTypeAttribute `xml:"Attributes>Attribute"`
+	}
+
+	// CredentialTypeAttribute describes an attribute of a CredentialType.
+	CredentialTypeAttribute struct {
+		ID       string `xml:"id,attr"`
+		Optional string `xml:"optional,attr"`
+	}
+)
+
+// NewCredentialTypeFromXML parses an IRMA credential type description.
+func NewCredentialTypeFromXML(data []byte) (*CredentialType, error) {
+	credType := &CredentialType{}
+	if err := xml.Unmarshal(data, credType); err != nil {
+		return nil, err
+	}
+	if credType.SchemeManagerID == "" || credType.IssuerID == "" || credType.ID == "" {
+		return nil, errors.New("incomplete credential type identifier")
+	}
+	if len(credType.Attributes) < 1 {
+		return nil, errors.New("credential type has no attributes")
+	}
+	seen := make(map[string]bool, len(credType.Attributes))
+	for _, attr := range credType.Attributes {
+		if attr.ID == "" || seen[attr.ID] {
+			return nil, fmt.Errorf("invalid attribute id %q", attr.ID)
+		}
+		seen[attr.ID] = true
+	}
+	return credType, nil
+}
+
+// Identifier returns the IRMA identifier of the credential type
+// (scheme.issuer.credential), which is used as ctype of the claim.
+func (credType *CredentialType) Identifier() string {
+	return strings.Join([]string{credType.SchemeManagerID, credType.IssuerID, credType.ID}, ".")
+}
+
+// IsOptional reports whether the attribute can be omitted.
+func (attr *CredentialTypeAttribute) IsOptional() bool {
+	return attr.Optional == "true"
+}
+
+// AttributeNames returns the names of the claim attributes of the credential
+// type. They can be requested from the claimer.
+func (credType *CredentialType) AttributeNames() []string {
+	names := make([]string, len(credType.Attributes))
+	for i, attr := range credType.Attributes {
+		names[i] = "contents" + Separator + escape(attr.ID, []rune(Separator)[0])
+	}
+	return names
+}
+
+// NewClaim creates a claim of the credential type containing the given
+// attribute values. Omitted optional attributes are set to null.
+func (credType *CredentialType) NewClaim(values map[string]string) (Claim, error) {
+	contents := make(map[string]interface{}, len(credType.Attributes))
+	for _, attr := range credType.Attributes {
+		value, ok := values[attr.ID]
+		switch {
+		case ok:
+			contents[attr.ID] = value
+		case attr.IsOptional():
+			contents[attr.ID] = nil
+		default:
+			return nil, fmt.Errorf("missing value for attribute %q", attr.ID)
+		}
+	}
+	for id := range values {
+		if _, ok := contents[id]; !ok {
+			return nil, fmt.Errorf("unknown attribute %q", id)
+		}
+	}
+	return Claim{
+		"ctype":    credType.Identifier(),
+		"contents": contents,
+	}, nil
+}
+
+// ClaimValues returns the attribute values of a claim of the credential type.
+// Omitted optional attributes are not contained in the returned map.
+func (credType *CredentialType) ClaimValues(claim Claim) (map[string]string, error) {
+	if cType := claimCType(claim); cType != credType.Identifier() {
+		return nil, fmt.Errorf("expected claim of type %q, got %q", credType.Identifier(), cType)
+	}
+	contents, ok := claim["contents"].(map[string]interface{})
+	if !ok {
+		if c, isClaim := claim["contents"].(Claim); isClaim {
+			contents, ok = c, true
+		}
+	}
+	if !ok {
+		return nil, errors.New("claim has no contents")
+	}
+	values := make(map[string]string, len(credType.Attributes))
+	for _, attr := range credType.Attributes {
+		switch value := contents[attr.ID].(type) {
+		case string:
+			values[attr.ID] = value
+		case nil:
+			if !attr.IsOptional() {
+				return nil, fmt.Errorf("missing value for attribute %q", attr.ID)
+			}
+		default:
+			return nil, fmt.Errorf("attribute %q is not a string", attr.ID)
+		}
+	}
+	if len(contents) > len(credType.Attributes) {
+		return nil, errors.New("claim contains unknown attributes")
+	}
+	return values, nil
+}
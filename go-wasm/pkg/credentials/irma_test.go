@@ -0,0 +1,138 @@
+package credentials
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var byteCredentialType = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<IssueSpecification version="4">
+	<SchemeManager>irma-demo</SchemeManager>
+	<IssuerID>MijnOverheid</IssuerID>
+	<CredentialID>fullName</CredentialID>
+	<Name>
+		<en>Full name</en>
+		<nl>Volledige naam</nl>
+	</Name>
+	<Attributes>
+		<Attribute id="firstname">
+			<Name><en>First name</en></Name>
+		</Attribute>
+		<Attribute id="familyname">
+			<Name><en>Family name</en></Name>
+		</Attribute>
+		<Attribute id="prefix" optional="true">
+			<Name><en>Prefix</en></Name>
+		</Attribute>
+	</Attributes>
+</IssueSpecification>`)
+
+func TestAttesterXML(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+
+	pubXML, err := attester.PublicKeyXML()
+	require.NoError(t, err)
+	privXML, err := attester.PrivateKeyXML()
+	require.NoError(t, err)
+	assert.Contains(t, string(pubXML), "<IssuerPublicKey")
+	assert.Contains(t, string(privXML), "<IssuerPrivateKey")
+
+	imported, err := NewAttesterFromXML(pubXML, privXML)
+	require.NoError(t, err)
+	expected, err := json.Marshal(attester)
+	require.NoError(t, err)
+	actual, err := json.Marshal(imported)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+
+	// counters must match
+	otherCounter := strings.Replace(string(privXML), "<Counter>0</Counter>", "<Counter>1</Counter>", 1)
+	_, err = NewAttesterFromXML(pubXML, []byte(otherCounter))
+	assert.Error(t, err)
+
+	// keys must belong to each other
+	imported.PrivateKey.P = imported.PrivateKey.Q
+	otherKey, err := imported.PrivateKeyXML()
+	require.NoError(t, err)
+	_, err = NewAttesterFromXML(pubXML, otherKey)
+	assert.Error(t, err)
+
+	_, err = NewAttesterFromXML(privXML, pubXML)
+	assert.Error(t, err)
+}
+
+func TestAttesterXMLWithoutRevocation(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	attester.PublicKey.ECDSA = ""
+	attester.PublicKey.G = nil
+	attester.PublicKey.H = nil
+	attester.PrivateKey.ECDSA = ""
+	pubXML, err := attester.PublicKeyXML()
+	require.NoError(t, err)
+	privXML, err := attester.PrivateKeyXML()
+	require.NoError(t, err)
+
+	imported, err := NewAttesterFromXML(pubXML, privXML)
+	require.NoError(t, err)
+	assert.True(t, imported.PublicKey.RevocationSupported())
+	assert.True(t, imported.PrivateKey.RevocationSupported())
+	_, err = imported.CreateAccumulator()
+	assert.NoError(t, err)
+}
+
+func TestCredentialType(t *testing.T) {
+	credType, err := NewCredentialTypeFromXML(byteCredentialType)
+	require.NoError(t, err)
+	assert.Equal(t, "irma-demo.MijnOverheid.fullName", credType.Identifier())
+	assert.Equal(t, []string{"contents.firstname", "contents.familyname", "contents.prefix"}, credType.AttributeNames())
+
+	claim, err := credType.NewClaim(map[string]string{
+		"firstname":  "Berta",
+		"familyname": "Bar",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "irma-demo.MijnOverheid.fullName", claimCType(claim))
+
+	// the attributes of the claim match the credential type
+	attributes, err := claim.ToAttributes()
+	require.NoError(t, err)
+	var names []string
+	for _, attr := range attributes {
+		names = append(names, attr.Name)
+	}
+	assert.ElementsMatch(t, append(credType.AttributeNames(), "ctype"), names)
+
+	// the layout survives the json encoding
+	bts, err := json.Marshal(claim)
+	require.NoError(t, err)
+	decoded := Claim{}
+	require.NoError(t, json.Unmarshal(bts, &decoded))
+	values, err := credType.ClaimValues(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"firstname": "Berta", "familyname": "Bar"}, values)
+
+	_, err = credType.NewClaim(map[string]string{"firstname": "Berta"})
+	assert.Error(t, err)
+	_, err = credType.NewClaim(map[string]string{"firstname": "Berta", "familyname": "Bar", "age": "34"})
+	assert.Error(t, err)
+
+	decoded["ctype"] = "irma-demo.MijnOverheid.address"
+	_, err = credType.ClaimValues(decoded)
+	assert.Error(t, err)
+}
+
+func TestCredentialTypeInvalid(t *testing.T) {
+	_, err := NewCredentialTypeFromXML([]byte("<IssueSpecification></IssueSpecification>"))
+	assert.Error(t, err)
+	duplicate := strings.Replace(string(byteCredentialType), `id="prefix"`, `id="firstname"`, 1)
+	_, err = NewCredentialTypeFromXML([]byte(duplicate))
+	assert.Error(t, err)
+	_, err = NewCredentialTypeFromXML([]byte("no xml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,367 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/keyproof"
+)
+
+// The stages of the key generation which are reported by KeyGenProgress.
+const (
+	// StageSafePrimes searches the two safe primes of the modulus.
+	StageSafePrimes = "safePrimes"
+	// StageKeyPair derives the bases of the public key from the primes.
+	StageKeyPair = "keyPair"
+	// StageKeyProof creates the proof of the public key (see ProvePublicKey).
+	StageKeyProof = "keyProof"
+)
+
+// progressInterval is the number of safe prime candidates which are tested
+// between two progress reports and checks for cancellation.
+const progressInterval = 1000
+
+type (
+	// KeyGenProgress describes the progress of the key generation.
+	//
+	// During StageSafePrimes Attempts counts the tested candidates and Primes
+	// the safe primes which were found. A pair of primes is usually found
+	// after a few primes, the number of attempts is random. During
+	// StageKeyProof Step describes the current step of the proof, Done counts
+	// the finished parts of the step and Total is their number, if known.
+	KeyGenProgress struct {
+		Stage    string `json:"stage"`
+		Attempts int    `json:"attempts,omitempty"`
+		Primes   int    `json:"primes,omitempty"`
+		Step     string `json:"step,omitempty"`
+		Done     int    `json:"done,omitempty"`
+		Total    int    `json:"total,omitempty"`
+	}
+
+	// ProgressFunc is called with the progress of the key generation. It is
+	// called from the goroutine which generates the key.
+	ProgressFunc func(KeyGenProgress)
+
+	// proofFollower reports the progress of a single proof.
+	proofFollower struct {
+		progress ProgressFunc
+		current  KeyGenProgress
+	}
+
+	// proofDispatcher is the global keyproof.Follower. keyproof reports the
+	// progress of every proof to this follower, partly from its worker
+	// goroutines, so the progress can not be attributed to a proof. The
+	// dispatcher therefore forwards the progress to the follower of the
+	// running proof, the proofs are created one at a time.
+	proofDispatcher struct {
+		mutex   sync.Mutex
+		current *proofFollower
+	}
+)
+
+var (
+	dispatcher        = &proofDispatcher{}
+	installDispatcher sync.Once
+	// proofSlot is held by the running proof.
+	proofSlot = make(chan struct{}, 1)
+)
+
+// NewAttesterContext creates a new key pair for an attester together with the
+// proof of the public key like NewAttesterWithCounter. The key generation
+// stops with the error of the context, if the context is done. If progress is
+// not nil, it is called regularly with the progress of the key generation.
+func NewAttesterContext(ctx context.Context, sysParams *gabi.SystemParameters, attributeCount int,
+	periodOfValidity int64, counter uint, progress ProgressFunc) (*Attester, error) {
+	expiryDate := time.Now().Add(time.Duration(periodOfValidity))
+	privK, pubK, err := GenerateKeyPairContext(ctx, sysParams, attributeCount, counter, expiryDate, progress)
+	if err != nil {
+		return nil, err
+	}
+	attester := &Attester{
+		PrivateKey: privK,
+		PublicKey:  pubK,
+	}
+	if err := attester.ProveKey(ctx, progress); err != nil {
+		return nil, err
+	}
+	return attester, nil
+}
+
+// GenerateKeyPairContext generates a key pair which supports revocation like
+// gabi.GenerateKeyPair. The search for the safe primes checks the context
+// regularly and stops with its error if it is done.
+func GenerateKeyPairContext(ctx context.Context, sysParams *gabi.SystemParameters, attributeCount int,
+	counter uint, expiryDate time.Time, progress ProgressFunc) (*gabi.PrivateKey, *gabi.PublicKey, error) {
+	if sysParams == nil {
+		return nil, nil, errors.New("missing system parameters")
+	}
+	if attributeCount < 1 {
+		return nil, nil, errors.New("a key must support at least one attribute")
+	}
+	if progress == nil {
+		progress = func(KeyGenProgress) {}
+	}
+	p, q, err := generateSafePrimePair(ctx, sysParams, progress)
+	if err != nil {
+		return nil, nil, err
+	}
+	progress(KeyGenProgress{Stage: StageKeyPair})
+	return GenerateKeyPairFromPrimes(sysParams, attributeCount, counter, expiryDate, p, q)
+}
+
+// GenerateKeyPairFromPrimes generates a key pair like GenerateKeyPairContext
+// using the given safe primes, e.g. taken from a SafePrimePool. The primes
+// must not be used for any other key.
+func GenerateKeyPairFromPrimes(sysParams *gabi.SystemParameters, attributeCount int, counter uint,
+	expiryDate time.Time, p, q *big.Int) (*gabi.PrivateKey, *gabi.PublicKey, error) {
+	if sysParams == nil {
+		return nil, nil, errors.New("missing system parameters")
+	}
+	if attributeCount < 1 {
+		return nil, nil, errors.New("a key must support at least one attribute")
+	}
+	if err := checkSafePrimePair(sysParams, p, q); err != nil {
+		return nil, nil, err
+	}
+	privK := &gabi.PrivateKey{
+		P:          p,
+		Q:          q,
+		PPrime:     new(big.Int).Rsh(p, 1),
+		QPrime:     new(big.Int).Rsh(q, 1),
+		Counter:    counter,
+		ExpiryDate: expiryDate.Unix(),
+	}
+	pubK := &gabi.PublicKey{
+		Params:      sysParams,
+		EpochLength: gabi.DefaultEpochLength,
+		Counter:     counter,
+		ExpiryDate:  expiryDate.Unix(),
+		N:           new(big.Int).Mul(p, q),
+	}
+
+	// S is a random quadratic residue modulo N, Z and R_i are random powers
+	// of S.
+	var err error
+	for {
+		pubK.S, err = randomBigInt(nil, sysParams.Ln)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pubK.S.Cmp(pubK.N) < 0 && big.Jacobi(pubK.S, p) == 1 && big.Jacobi(pubK.S, q) == 1 {
+			break
+		}
+	}
+	if pubK.Z, err = randomPower(pubK.S, pubK.N, sysParams.Ln/2); err != nil {
+		return nil, nil, err
+	}
+	pubK.R = make([]*big.Int, attributeCount)
+	for i := range pubK.R {
+		if pubK.R[i], err = randomPower(pubK.S, pubK.N, sysParams.Ln/2); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := gabi.GenerateRevocationKeypair(privK, pubK); err != nil {
+		return nil, nil, err
+	}
+	return privK, pubK, nil
+}
+
+// ProvePublicKeyContext creates the proof of the public key like
+// ProvePublicKey and reports its progress to the given function. Proofs are
+// created one at a time, a call waits until the running proof is finished.
+// If the context is done, the call returns the error of the context and
+// progress is not called anymore. keyproof can not be interrupted, an aborted
+// proof therefore keeps running in the background and delays the next proof.
+func ProvePublicKeyContext(ctx context.Context, privK *gabi.PrivateKey, pubK *gabi.PublicKey,
+	progress ProgressFunc) (*keyproof.ValidKeyProof, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(KeyGenProgress) {}
+	}
+	installDispatcher.Do(func() {
+		keyproof.Follower = dispatcher
+	})
+	select {
+	case proofSlot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	type result struct {
+		proof *keyproof.ValidKeyProof
+		err   error
+	}
+	follower := &proofFollower{progress: progress}
+	dispatcher.follow(follower)
+	done := make(chan result, 1)
+	go func() {
+		proof, err := ProvePublicKey(privK, pubK)
+		dispatcher.follow(nil)
+		<-proofSlot
+		done <- result{proof, err}
+	}()
+	select {
+	case r := <-done:
+		return r.proof, r.err
+	case <-ctx.Done():
+		dispatcher.unfollow(follower)
+		return nil, ctx.Err()
+	}
+}
+
+// randomPower returns base^x mod n for a random x of the given number of bits
+// with 2 < x < n.
+func randomPower(base, n *big.Int, numBits uint) (*big.Int, error) {
+	two := big.NewInt(2)
+	for {
+		x, err := randomBigInt(nil, numBits)
+		if err != nil {
+			return nil, err
+		}
+		if x.Cmp(two) > 0 && x.Cmp(n) < 0 {
+			return new(big.Int).Exp(base, x, n), nil
+		}
+	}
+}
+
+// generateSafePrimePair searches two safe primes which are a suitable pair
+// (see isSafePrimePair).
+func generateSafePrimePair(ctx context.Context, sysParams *gabi.SystemParameters,
+	progress ProgressFunc) (*big.Int, *big.Int, error) {
+	status := KeyGenProgress{Stage: StageSafePrimes}
+	progress(status)
+
+	var primes []*big.Int
+	for {
+		p, err := generateSafePrime(ctx, int(sysParams.Ln/2), &status, progress)
+		if err != nil {
+			return nil, nil, err
+		}
+		status.Primes++
+		progress(status)
+		if !isUsableSafePrime(p) {
+			continue
+		}
+		for _, q := range primes {
+			if isSafePrimePair(sysParams, p, q) {
+				return p, q, nil
+			}
+		}
+		primes = append(primes, p)
+	}
+}
+
+// isUsableSafePrime reports whether the safe prime p can be part of a key,
+// which requires (p-1)/2 mod 8 != 1 like gabi.GenerateKeyPair.
+func isUsableSafePrime(p *big.Int) bool {
+	return new(big.Int).Mod(new(big.Int).Rsh(p, 1), big.NewInt(8)).Int64() != 1
+}
+
+// isSafePrimePair reports whether the usable safe primes p and q form the
+// modulus of a key: p*q has the length of the modulus and p mod 8 != q mod 8.
+func isSafePrimePair(sysParams *gabi.SystemParameters, p, q *big.Int) bool {
+	eight := big.NewInt(8)
+	return uint(new(big.Int).Mul(p, q).BitLen()) == sysParams.Ln &&
+		new(big.Int).Mod(p, eight).Cmp(new(big.Int).Mod(q, eight)) != 0
+}
+
+// isSafePrime reports whether p is a safe prime of the given number of bits.
+func isSafePrime(p *big.Int, numBits int) bool {
+	return p != nil && p.BitLen() == numBits && p.ProbablyPrime(40) && new(big.Int).Rsh(p, 1).ProbablyPrime(40)
+}
+
+// checkSafePrimePair checks that p and q are safe primes which form the
+// modulus of a key.
+func checkSafePrimePair(sysParams *gabi.SystemParameters, p, q *big.Int) error {
+	for _, prime := range []*big.Int{p, q} {
+		if !isSafePrime(prime, int(sysParams.Ln/2)) {
+			return fmt.Errorf("expected a safe prime of %d bits", sysParams.Ln/2)
+		}
+		if !isUsableSafePrime(prime) {
+			return errors.New("safe prime is not suitable for a key")
+		}
+	}
+	if !isSafePrimePair(sysParams, p, q) {
+		return errors.New("safe primes do not form a modulus")
+	}
+	return nil
+}
+
+// generateSafePrime searches a safe prime 2q+1 of the given number of bits.
+// A candidate q is accepted if it is prime and 2^(2q) = 1 mod 2q+1, which
+// implies that 2q+1 is prime. The attempts are counted in status, which is
+// reported every progressInterval attempts.
+func generateSafePrime(ctx context.Context, numBits int, status *KeyGenProgress,
+	progress ProgressFunc) (*big.Int, error) {
+	one, two := big.NewInt(1), big.NewInt(2)
+	twoQ, p, check := new(big.Int), new(big.Int), new(big.Int)
+	for {
+		status.Attempts++
+		if status.Attempts%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			progress(*status)
+		}
+
+		// q has numBits-1 bits and is odd
+		q, err := randomBigInt(nil, uint(numBits-1))
+		if err != nil {
+			return nil, err
+		}
+		if q.BitLen() != numBits-1 || q.Bit(0) != 1 {
+			continue
+		}
+		twoQ.Lsh(q, 1)
+		p.Add(twoQ, one)
+		if check.Exp(two, twoQ, p).Cmp(one) != 0 || !q.ProbablyPrime(40) {
+			continue
+		}
+		return new(big.Int).Set(p), nil
+	}
+}
+
+// follow forwards the progress to the given follower.
+func (d *proofDispatcher) follow(follower *proofFollower) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.current = follower
+}
+
+// unfollow stops forwarding the progress to the follower, if it is the
+// current follower. Once it returns, the follower is not called anymore.
+func (d *proofDispatcher) unfollow(follower *proofFollower) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.current == follower {
+		d.current = nil
+	}
+}
+
+func (d *proofDispatcher) StepStart(desc string, intermediates int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.current != nil {
+		d.current.current = KeyGenProgress{Stage: StageKeyProof, Step: desc, Total: intermediates}
+		d.current.progress(d.current.current)
+	}
+}
+
+func (d *proofDispatcher) Tick() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.current != nil {
+		d.current.current.Done++
+		d.current.progress(d.current.current)
+	}
+}
+
+func (d *proofDispatcher) StepDone() {}
@@ -0,0 +1,103 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/keyproof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateKeyPairContext(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+
+	stages := map[string]bool{}
+	attempts := 0
+	privK, pubK, err := GenerateKeyPairContext(context.Background(), sysParams, 5, 2, time.Now(),
+		func(progress KeyGenProgress) {
+			stages[progress.Stage] = true
+			if progress.Stage == StageSafePrimes {
+				assert.True(t, progress.Attempts >= attempts)
+				attempts = progress.Attempts
+			}
+		})
+	require.NoError(t, err)
+	assert.True(t, stages[StageSafePrimes])
+	assert.True(t, stages[StageKeyPair])
+	assert.True(t, attempts > 0)
+
+	one := big.NewInt(1)
+	for _, prime := range []*big.Int{privK.P, privK.Q} {
+		assert.True(t, prime.ProbablyPrime(20))
+		assert.True(t, new(big.Int).Rsh(new(big.Int).Sub(prime, one), 1).ProbablyPrime(20))
+	}
+	assert.Equal(t, int(sysParams.Ln), pubK.N.BitLen())
+	assert.Len(t, pubK.R, 5)
+	assert.Equal(t, uint(2), pubK.Counter)
+	assert.True(t, pubK.RevocationSupported())
+	assert.True(t, privK.RevocationSupported())
+}
+
+func TestGenerateKeyPairContextCanceled(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := GenerateKeyPairContext(ctx, sysParams, 5, 0, time.Now(), nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	_, err = NewAttesterContext(ctx, sysParams, 5, OneYear, 0, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	_, _, err = GenerateKeyPairContext(context.Background(), sysParams, 0, 0, time.Now(), nil)
+	assert.Error(t, err)
+}
+
+func TestProvePublicKeyContextWaiting(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+
+	// another proof is running
+	proofSlot <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	proof, err := ProvePublicKeyContext(ctx, attester.PrivateKey, attester.PublicKey, func(KeyGenProgress) {
+		t.Error("progress of a waiting proof")
+	})
+	<-proofSlot
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Nil(t, proof)
+}
+
+func TestProvePublicKeyContextCanceled(t *testing.T) {
+	attester := newTestAttester(t, smallSysParams(t), 3, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mutex sync.Mutex
+	returned := false
+	steps := []string{}
+	proof, err := ProvePublicKeyContext(ctx, attester.PrivateKey, attester.PublicKey, func(progress KeyGenProgress) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.False(t, returned, "progress after the proof was aborted")
+		assert.Equal(t, StageKeyProof, progress.Stage)
+		steps = append(steps, progress.Step)
+		cancel()
+	})
+	mutex.Lock()
+	returned = true
+	mutex.Unlock()
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Nil(t, proof)
+	assert.NotEmpty(t, steps)
+	assert.Equal(t, dispatcher, keyproof.Follower)
+}
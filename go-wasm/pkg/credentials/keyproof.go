@@ -0,0 +1,82 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/keyproof"
+)
+
+// ProvePublicKey creates a zero-knowledge proof that the public key is well
+// formed: N is the product of two safe primes and the bases Z, S, R_0, ...,
+// R_n, G and H are quadratic residues modulo N. Without this proof a malicious
+// attester could choose a key which allows to track claimers.
+//
+// Creating the proof takes several minutes for larger keys.
+func ProvePublicKey(privK *gabi.PrivateKey, pubK *gabi.PublicKey) (*keyproof.ValidKeyProof, error) {
+	if privK == nil || privK.PPrime == nil || privK.QPrime == nil {
+		return nil, errors.New("incomplete private key")
+	}
+	structure, err := newValidKeyProofStructure(pubK)
+	if err != nil {
+		return nil, err
+	}
+	p := new(big.Int).Add(new(big.Int).Lsh(privK.PPrime, 1), big.NewInt(1))
+	q := new(big.Int).Add(new(big.Int).Lsh(privK.QPrime, 1), big.NewInt(1))
+	if pubK.N.Cmp(new(big.Int).Mul(p, q)) != 0 {
+		return nil, errors.New("private key does not belong to the public key")
+	}
+	proof := structure.BuildProof(privK.PPrime, privK.QPrime)
+	return &proof, nil
+}
+
+// VerifyPublicKey verifies the proof of the public key, which was created
+// using ProvePublicKey. Claimers should verify the key before requesting an
+// attestation (see Claimer.RequestAttestationWithKeyProof), verifiers should
+// verify it before trusting a new attester.
+func VerifyPublicKey(pubK *gabi.PublicKey, proof *keyproof.ValidKeyProof) (err error) {
+	if proof == nil {
+		return errors.New("missing public key proof")
+	}
+	structure, err := newValidKeyProofStructure(pubK)
+	if err != nil {
+		return err
+	}
+	if pubK.Params != nil && pubK.N.BitLen() != int(pubK.Params.Ln) {
+		return fmt.Errorf("expected modulus of %d bits, got %d bits", pubK.Params.Ln, pubK.N.BitLen())
+	}
+	// the proof is provided by the attester, malformed proofs must not crash
+	// the verifier
+	defer func() {
+		if r := recover(); r != nil {
+			err = newError(ErrProofInvalid, "malformed public key proof: %v", r)
+		}
+	}()
+	if !structure.VerifyProof(*proof) {
+		return newError(ErrProofInvalid, "public key proof is invalid")
+	}
+	return nil
+}
+
+// newValidKeyProofStructure returns the structure of the proof for the public
+// key. The revocation bases G and H are included if the key supports
+// revocation.
+func newValidKeyProofStructure(pubK *gabi.PublicKey) (*keyproof.ValidKeyProofStructure, error) {
+	if pubK == nil || pubK.N == nil || pubK.Z == nil || pubK.S == nil || len(pubK.R) < 1 {
+		return nil, errors.New("incomplete public key")
+	}
+	bases := make([]*big.Int, 0, len(pubK.R)+2)
+	for _, r := range pubK.R {
+		if r == nil {
+			return nil, errors.New("incomplete public key")
+		}
+		bases = append(bases, r)
+	}
+	if pubK.G != nil && pubK.H != nil {
+		bases = append(bases, pubK.G, pubK.H)
+	}
+	structure := keyproof.NewValidKeyProofStructure(pubK.N, pubK.Z, pubK.S, bases)
+	return &structure, nil
+}
@@ -0,0 +1,30 @@
+package credentials
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/keyproof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicKeyProofInvalid(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+
+	assert.Error(t, VerifyPublicKey(attester.PublicKey, nil))
+	assert.Error(t, VerifyPublicKey(attester.PublicKey, &keyproof.ValidKeyProof{}))
+
+	incomplete := *attester.PublicKey
+	incomplete.Z = nil
+	assert.Error(t, VerifyPublicKey(&incomplete, &keyproof.ValidKeyProof{}))
+
+	otherKey := *attester.PrivateKey
+	otherKey.PPrime = big.NewInt(11)
+	_, err := ProvePublicKey(&otherKey, attester.PublicKey)
+	assert.Error(t, err)
+	_, err = ProvePublicKey(nil, attester.PublicKey)
+	assert.Error(t, err)
+}
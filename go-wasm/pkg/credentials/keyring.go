@@ -0,0 +1,384 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/keyproof"
+	"github.com/privacybydesign/gabi/revocation"
+)
+
+type (
+	// AttesterKeyring contains all key versions of an attester. Every key has
+	// its own counter and accumulator. New attestations are always issued using
+	// the current key, which is the key with the highest counter. Older keys
+	// stay valid until they expire.
+	AttesterKeyring struct {
+		Keys []*Attester `json:"keys"`
+	}
+
+	// PublicKeyring contains the public keys of an attester. It is published by
+	// the attester and used by verifiers to resolve the key of a presentation.
+	// Proofs contains the proof of each key (see VerifyPublicKey).
+	PublicKeyring struct {
+		Keys   []*gabi.PublicKey         `json:"keys"`
+		Proofs []*keyproof.ValidKeyProof `json:"proofs,omitempty"`
+	}
+
+	// MigrationRequest is send from the claimer to the attester in order to
+	// migrate a credential to the current key of the attester. The commitment
+	// message of the request contains a single proof list, which consists of
+	// the proof of the commitment to the master secret and a disclosure proof
+	// of the old credential. Both proofs share the response of the master
+	// secret, so that the new credential is bound to the same master secret.
+	// The disclosure proof discloses all attributes of the old credential,
+	// KeyID identifies the old key.
+	MigrationRequest struct {
+		Request   *AttestedClaimRequest `json:"request"`
+		KeyID     *big.Int              `json:"keyId"`
+		Preimages []*Attribute          `json:"preimages,omitempty"`
+	}
+)
+
+// NewAttesterKeyring creates a keyring containing the given keys.
+func NewAttesterKeyring(keys ...*Attester) (*AttesterKeyring, error) {
+	keyring := &AttesterKeyring{}
+	for _, key := range keys {
+		if err := keyring.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+// Add adds a key to the keyring. The counter of the key must be unique.
+func (keyring *AttesterKeyring) Add(key *Attester) error {
+	if key == nil || key.PublicKey == nil || key.PrivateKey == nil {
+		return errors.New("incomplete attester key")
+	}
+	if key.PublicKey.Counter != key.PrivateKey.Counter {
+		return errors.New("counter of the public key does not match the private key")
+	}
+	for _, k := range keyring.Keys {
+		if k.PublicKey.Counter == key.PublicKey.Counter {
+			return fmt.Errorf("key with counter %d already exists", key.PublicKey.Counter)
+		}
+	}
+	keyring.Keys = append(keyring.Keys, key)
+	sort.Slice(keyring.Keys, func(i, j int) bool {
+		return keyring.Keys[i].PublicKey.Counter < keyring.Keys[j].PublicKey.Counter
+	})
+	return nil
+}
+
+// Current returns the key which is used for new attestations.
+func (keyring *AttesterKeyring) Current() (*Attester, error) {
+	if len(keyring.Keys) < 1 {
+		return nil, errors.New("keyring is empty")
+	}
+	return keyring.Keys[len(keyring.Keys)-1], nil
+}
+
+// Key returns the key with the given counter.
+func (keyring *AttesterKeyring) Key(counter uint) (*Attester, error) {
+	for _, key := range keyring.Keys {
+		if key.PublicKey.Counter == counter {
+			return key, nil
+		}
+	}
+	return nil, newError(ErrKeyUnknown, "no key with counter %d", counter)
+}
+
+// Rotate generates the next version of the key and the accumulator for the
+// new key. The new key becomes the current key. Revocations of attestations
+// which were issued using an older key still have to be applied to the
+// accumulator of the older key. The new key is created together with its
+// proof, which takes several minutes for larger keys.
+func (keyring *AttesterKeyring) Rotate(sysParams *gabi.SystemParameters, attributeCount int, periodOfValidity int64) (*Attester, *revocation.Update, error) {
+	var counter uint
+	if current, err := keyring.Current(); err == nil {
+		counter = current.PublicKey.Counter + 1
+	}
+	key, err := NewAttesterWithCounter(sysParams, attributeCount, periodOfValidity, counter)
+	if err != nil {
+		return nil, nil, err
+	}
+	update, err := key.CreateAccumulator()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := keyring.Add(key); err != nil {
+		return nil, nil, err
+	}
+	return key, update, nil
+}
+
+// RemoveExpired removes all keys which expired before the given time. The
+// current key is never removed.
+func (keyring *AttesterKeyring) RemoveExpired(now time.Time) {
+	keys := keyring.Keys[:0]
+	for i, key := range keyring.Keys {
+		if i == len(keyring.Keys)-1 || !isExpired(key.PublicKey, now) {
+			keys = append(keys, key)
+		}
+	}
+	keyring.Keys = keys
+}
+
+// PublicKeys returns the public keyring, which can be published.
+func (keyring *AttesterKeyring) PublicKeys() *PublicKeyring {
+	pubKeys := make([]*gabi.PublicKey, len(keyring.Keys))
+	proofs := make([]*keyproof.ValidKeyProof, len(keyring.Keys))
+	for i, key := range keyring.Keys {
+		pubKeys[i] = key.PublicKey
+		proofs[i] = key.PublicKeyProof
+	}
+	return &PublicKeyring{Keys: pubKeys, Proofs: proofs}
+}
+
+// Verify verifies the proofs of all keys. It should be called before the
+// keyring of a new attester is trusted.
+func (keyring *PublicKeyring) Verify() error {
+	if len(keyring.Proofs) != len(keyring.Keys) {
+		return fmt.Errorf("expected %d public key proofs, got %d", len(keyring.Keys), len(keyring.Proofs))
+	}
+	for i, key := range keyring.Keys {
+		if err := VerifyPublicKey(key, keyring.Proofs[i]); err != nil {
+			return fmt.Errorf("key with counter %d: %v", key.Counter, err)
+		}
+	}
+	return nil
+}
+
+// Current returns the public key which is used for new attestations.
+func (keyring *PublicKeyring) Current() (*gabi.PublicKey, error) {
+	var current *gabi.PublicKey
+	for _, key := range keyring.Keys {
+		if current == nil || key.Counter > current.Counter {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, errors.New("keyring is empty")
+	}
+	return current, nil
+}
+
+// Lookup returns the public key with the given identifier (see PublicKeyID).
+// Expired keys are not returned.
+func (keyring *PublicKeyring) Lookup(keyID *big.Int) (*gabi.PublicKey, error) {
+	if keyID == nil {
+		return nil, errors.New("missing key id")
+	}
+	for _, key := range keyring.Keys {
+		id, err := PublicKeyID(key)
+		if err != nil {
+			return nil, err
+		}
+		if id.Cmp(keyID) != 0 {
+			continue
+		}
+		if isExpired(key, time.Now()) {
+			return nil, newError(ErrKeyExpired, "key with counter %d expired", key.Counter)
+		}
+		return key, nil
+	}
+	return nil, newError(ErrKeyUnknown, "unknown key id")
+}
+
+// VerifyKeyringPresentation verifies the response of a claimer using the public
+// key identified by the response. The latest accumulators of all keys can be
+// passed, even of keys of other attesters, the accumulator is selected using
+// the revocation key of the public key (see latestAccumulator).
+func VerifyKeyringPresentation(keyring *PublicKeyring, latestAccs []*revocation.SignedAccumulator,
+	response *PresentationResponse, session *VerifierSession) (bool, Claim, error) {
+	pubK, err := keyring.Lookup(response.KeyID)
+	if err != nil {
+		return false, nil, err
+	}
+	latestAcc, err := latestAccumulator(latestAccs, pubK)
+	if err != nil && session.ReqNonRevocationProof {
+		return false, nil, err
+	}
+	return VerifyPresentation(pubK, latestAcc, response, session)
+}
+
+// latestAccumulator returns the accumulator with the highest index which was
+// signed using the revocation key of the public key. The counter only
+// identifies a key of one attester, accumulators of other attesters with the
+// same counter are skipped since their signature does not verify. The
+// accumulators are not modified.
+func latestAccumulator(accs []*revocation.SignedAccumulator, pubK *gabi.PublicKey) (*revocation.SignedAccumulator, error) {
+	revPubKey, err := pubK.RevocationKey()
+	if err != nil {
+		return nil, err
+	}
+	var latest *revocation.SignedAccumulator
+	var latestIndex uint64
+	for _, signedAcc := range accs {
+		if signedAcc == nil || signedAcc.PKCounter != pubK.Counter {
+			continue
+		}
+		acc, err := verifyAccumulator(signedAcc, revPubKey)
+		if err != nil {
+			continue
+		}
+		if latest == nil || acc.Index > latestIndex {
+			latest, latestIndex = signedAcc, acc.Index
+		}
+	}
+	if latest == nil {
+		return nil, newError(ErrKeyUnknown, "missing accumulator for key with counter %d", pubK.Counter)
+	}
+	return latest, nil
+}
+
+// verifyAccumulator verifies the signature of the accumulator. In contrast to
+// SignedAccumulator.UnmarshalVerify the signature is always checked and the
+// decoded accumulator is not cached in signedAcc.
+func verifyAccumulator(signedAcc *revocation.SignedAccumulator, revPubKey *revocation.PublicKey) (*revocation.Accumulator, error) {
+	cpy := &revocation.SignedAccumulator{Data: signedAcc.Data, PKCounter: signedAcc.PKCounter}
+	return cpy.UnmarshalVerify(revPubKey)
+}
+
+// RequestMigration creates a MigrationRequest, which migrates the attested
+// claim from the old key of the attester to the key of the attestation session
+// started by the startMsg.
+func (user *Claimer) RequestMigration(oldPubK, newPubK *gabi.PublicKey, attestedClaim *AttestedClaim,
+	startMsg *StartSessionMsg) (*UserIssuanceSession, *MigrationRequest, error) {
+	attributes, err := attestedClaim.getAttributes()
+	if err != nil {
+		return nil, nil, err
+	}
+	// keep the encoding of the old credential
+	encoding := DefaultEncoding
+	for _, attr := range attributes {
+		if attr.Typename == arrayLengthType {
+			encoding = ElementEncoding
+			break
+		}
+	}
+	cb, claim, err := user.newCredentialBuilder(newPubK, startMsg, attestedClaim.Claim, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// disclose all attributes of the old credential
+	cred := attestedClaim.Credential
+	revKey, err := oldPubK.RevocationKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cred.NonRevocationWitness.Verify(revKey); err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, len(attributes))
+	for i, attr := range attributes {
+		names[i] = attr.Name
+	}
+	attrIndices, err := attestedClaim.getAttributeIndices(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	preimages, err := attestedClaim.getPreimages(attrIndices, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyID, err := PublicKeyID(oldPubK)
+	if err != nil {
+		return nil, nil, err
+	}
+	cred.Pk = oldPubK
+	disclosure, err := cred.CreateDisclosureProofBuilder(attrIndices, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	builders := gabi.ProofBuilderList{cb, disclosure}
+	proofs := builders.BuildProofList(startMsg.Context, startMsg.Nonce, false)
+	return &UserIssuanceSession{
+			Cb:             cb,
+			Claim:          claim,
+			Encoding:       encoding,
+			HashAttributes: true,
+		}, &MigrationRequest{
+			Request: &AttestedClaimRequest{
+				CommitMsg: cb.CreateIssueCommitmentMessage(proofs),
+				Claim:     claim,
+				Encoding:  encoding,
+			},
+			KeyID:     keyID,
+			Preimages: preimages,
+		}, nil
+}
+
+// MigrateClaim attests the claim of the MigrationRequest using the current
+// key. The commitment and the disclosure proof of the old credential are
+// verified as one proof list, which ensures that both use the same master
+// secret. The old credential must not be revoked according to the latest
+// accumulators and must disclose exactly the requested claim. The session must
+// have been started using the current key.
+func (keyring *AttesterKeyring) MigrateClaim(req *MigrationRequest, session *AttesterSession,
+	latestAccs []*revocation.SignedAccumulator, update *revocation.Update) (*gabi.IssueSignatureMessage, *revocation.Witness, error) {
+	if req.Request == nil || req.Request.CommitMsg == nil || len(req.Request.CommitMsg.Proofs) != 2 {
+		return nil, nil, errors.New("incomplete migration request")
+	}
+	commitMsg := req.Request.CommitMsg
+	proofU, ok := commitMsg.Proofs[0].(*gabi.ProofU)
+	if !ok || proofU.U == nil || commitMsg.U == nil || proofU.U.Cmp(commitMsg.U) != 0 {
+		return nil, nil, errors.New("expected the proof of the commitment as first proof")
+	}
+	proofD, ok := commitMsg.Proofs[1].(*gabi.ProofD)
+	if !ok {
+		return nil, nil, errors.New("expected the disclosure proof of the old credential as second proof")
+	}
+	current, err := keyring.Current()
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.CTypeHash != "" && session.CTypeHash != claimCType(req.Request.Claim) {
+		return nil, nil, newError(ErrSessionMismatch, "ctype of the claim does not match the session")
+	}
+	oldPubK, err := keyring.PublicKeys().Lookup(req.KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var latestAcc *revocation.SignedAccumulator
+	for _, acc := range latestAccs {
+		if acc != nil && acc.PKCounter == oldPubK.Counter {
+			latestAcc = acc
+			break
+		}
+	}
+
+	var proofErr error
+	if !commitMsg.Proofs.Verify([]*gabi.PublicKey{current.PublicKey, oldPubK}, session.Context, session.Nonce, false, nil) {
+		proofErr = newError(ErrProofInvalid, "migration request could not be verified")
+	}
+	result, err := checkDisclosure(addCheck(nil, CheckProof, proofErr), oldPubK, latestAcc, proofD, req.Preimages,
+		&PartialPresentationRequest{
+			ReqNonRevocationProof: true,
+			ReqUpdatedAfter:       time.Now(),
+		}, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, nil, err
+	}
+	if equal, err := result.Claim.Equal(req.Request.Claim); err != nil {
+		return nil, nil, err
+	} else if !equal {
+		return nil, nil, newError(ErrSessionMismatch, "claim does not match the old credential")
+	}
+	return current.signClaim(req.Request, session, update)
+}
+
+// isExpired reports whether the key expired before the given time.
+func isExpired(pubK *gabi.PublicKey, now time.Time) bool {
+	return now.After(time.Unix(pubK.ExpiryDate, 0))
+}
@@ -0,0 +1,208 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttesterKeyring(t *testing.T) {
+	if testing.Short() {
+		t.Skip("key proof is slow")
+	}
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+
+	keyring, err := NewAttesterKeyring(attester)
+	require.NoError(t, err)
+	assert.Error(t, keyring.Add(attester))
+
+	// a small key, since the new key is proven
+	key, update, err := keyring.Rotate(smallSysParams(t), 3, OneYear)
+	require.NoError(t, err)
+	require.NotNil(t, update)
+	assert.NotNil(t, key.PublicKeyProof)
+	assert.Equal(t, uint(1), key.PublicKey.Counter)
+	assert.Equal(t, key.PublicKey.Counter, update.SignedAccumulator.PKCounter)
+	current, err := keyring.Current()
+	require.NoError(t, err)
+	assert.Equal(t, key, current)
+	old, err := keyring.Key(0)
+	require.NoError(t, err)
+	assert.Equal(t, attester, old)
+	_, err = keyring.Key(2)
+	assert.Error(t, err)
+
+	// the public keyring can be published
+	bts, err := json.Marshal(keyring.PublicKeys())
+	require.NoError(t, err)
+	pubKeyring := &PublicKeyring{}
+	require.NoError(t, json.Unmarshal(bts, pubKeyring))
+	pubCurrent, err := pubKeyring.Current()
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), pubCurrent.Counter)
+	for _, k := range keyring.Keys {
+		keyID, err := PublicKeyID(k.PublicKey)
+		require.NoError(t, err)
+		found, err := pubKeyring.Lookup(keyID)
+		require.NoError(t, err)
+		assert.Equal(t, k.PublicKey.Counter, found.Counter)
+	}
+	_, err = pubKeyring.Lookup(big.NewInt(42))
+	assert.True(t, errors.Is(err, ErrKeyUnknown))
+	_, err = pubKeyring.Lookup(nil)
+	assert.Error(t, err)
+
+	// expired keys are removed, but the current key is kept
+	keyring.RemoveExpired(time.Unix(attester.PublicKey.ExpiryDate+1, 0))
+	require.Len(t, keyring.Keys, 1)
+	assert.Equal(t, key, keyring.Keys[0])
+}
+
+func TestKeyringLookupExpired(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	attester.PublicKey.ExpiryDate = time.Now().Add(-time.Hour).Unix()
+	keyID, err := PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+
+	keyring := &PublicKeyring{Keys: []*gabi.PublicKey{attester.PublicKey}}
+	_, err = keyring.Lookup(keyID)
+	assert.True(t, errors.Is(err, ErrKeyExpired))
+}
+
+func TestLatestAccumulator(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+
+	// the keys of both attesters have counter 0
+	other := newTestAttester(t, smallSysParams(t), 3, 0)
+	require.Equal(t, attester.PublicKey.Counter, other.PublicKey.Counter)
+	otherUpdate, err := other.CreateAccumulator()
+	require.NoError(t, err)
+
+	for _, accs := range [][]*revocation.SignedAccumulator{
+		{otherUpdate.SignedAccumulator, update.SignedAccumulator},
+		{update.SignedAccumulator, otherUpdate.SignedAccumulator},
+	} {
+		acc, err := latestAccumulator(accs, attester.PublicKey)
+		require.NoError(t, err)
+		assert.Same(t, update.SignedAccumulator, acc)
+		acc, err = latestAccumulator(accs, other.PublicKey)
+		require.NoError(t, err)
+		assert.Same(t, otherUpdate.SignedAccumulator, acc)
+	}
+	_, err = latestAccumulator([]*revocation.SignedAccumulator{otherUpdate.SignedAccumulator, nil}, attester.PublicKey)
+	assert.True(t, errors.Is(err, ErrKeyUnknown))
+}
+
+func TestVerifyKeyringPresentation(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+	response := &PresentationResponse{}
+	require.NoError(t, json.Unmarshal(bytePresentationResponse, response))
+	verifierSession := &VerifierSession{}
+	require.NoError(t, json.Unmarshal(byteVerifierSession, verifierSession))
+
+	keyring := &PublicKeyring{Keys: []*gabi.PublicKey{attester.PublicKey}}
+	_, _, err := VerifyKeyringPresentation(keyring, []*revocation.SignedAccumulator{update.SignedAccumulator},
+		response, verifierSession)
+	assert.Error(t, err, "presentation without key id")
+
+	response.KeyID, err = PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+	_, _, err = VerifyKeyringPresentation(keyring, nil, response, verifierSession)
+	assert.Error(t, err, "missing accumulator")
+
+	ok, claim, err := VerifyKeyringPresentation(keyring, []*revocation.SignedAccumulator{update.SignedAccumulator},
+		response, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotNil(t, claim)
+}
+
+func TestMigrateClaim(t *testing.T) {
+	if testing.Short() {
+		t.Skip("key generation is slow")
+	}
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	oldUpdate := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, oldUpdate))
+	cred := &AttestedClaim{}
+	require.NoError(t, json.Unmarshal(byteCredential, cred))
+	claimer := &Claimer{}
+	require.NoError(t, json.Unmarshal(byteClaimer, claimer))
+
+	keyring, err := NewAttesterKeyring(attester)
+	require.NoError(t, err)
+	newKey := newTestAttester(t, sysParams, 10, 1)
+	require.NoError(t, keyring.Add(newKey))
+	newUpdate, err := newKey.CreateAccumulator()
+	require.NoError(t, err)
+
+	attesterSession, startMsg, err := newKey.InitiateAttestation()
+	require.NoError(t, err)
+	claimerSession, req, err := claimer.RequestMigration(attester.PublicKey, newKey.PublicKey, cred, startMsg)
+	require.NoError(t, err)
+
+	// the old credential must not be revoked
+	_, _, err = keyring.MigrateClaim(req, attesterSession, nil, newUpdate)
+	assert.Error(t, err)
+
+	// the claim must match the old credential
+	changed := &MigrationRequest{
+		Request:   &AttestedClaimRequest{CommitMsg: req.Request.CommitMsg, Claim: Claim{"ctype": "other"}},
+		KeyID:     req.KeyID,
+		Preimages: req.Preimages,
+	}
+	_, _, err = keyring.MigrateClaim(changed, attesterSession, []*revocation.SignedAccumulator{oldUpdate.SignedAccumulator}, newUpdate)
+	assert.Error(t, err)
+
+	// the commitment of another master secret can not be combined with the
+	// disclosure proof of the old credential
+	other, err := NewClaimer(sysParams)
+	require.NoError(t, err)
+	_, otherReq, err := other.RequestAttestationForClaim(newKey.PublicKey, startMsg, cred.Claim)
+	require.NoError(t, err)
+	mixedCommitMsg := *otherReq.CommitMsg
+	mixedCommitMsg.Proofs = gabi.ProofList{otherReq.CommitMsg.Proofs[0], req.Request.CommitMsg.Proofs[1]}
+	mixed := &MigrationRequest{
+		Request:   &AttestedClaimRequest{CommitMsg: &mixedCommitMsg, Claim: otherReq.Claim},
+		KeyID:     req.KeyID,
+		Preimages: req.Preimages,
+	}
+	_, _, err = keyring.MigrateClaim(mixed, attesterSession, []*revocation.SignedAccumulator{oldUpdate.SignedAccumulator}, newUpdate)
+	assert.True(t, errors.Is(err, ErrProofInvalid))
+
+	// the commitment alone is not sufficient
+	single := *req.Request.CommitMsg
+	single.Proofs = single.Proofs[:1]
+	_, _, err = keyring.MigrateClaim(&MigrationRequest{
+		Request: &AttestedClaimRequest{CommitMsg: &single, Claim: req.Request.Claim},
+		KeyID:   req.KeyID,
+	}, attesterSession, []*revocation.SignedAccumulator{oldUpdate.SignedAccumulator}, newUpdate)
+	assert.Error(t, err)
+
+	sig, _, err := keyring.MigrateClaim(req, attesterSession, []*revocation.SignedAccumulator{oldUpdate.SignedAccumulator}, newUpdate)
+	require.NoError(t, err)
+	migrated, err := claimer.BuildCredential(sig, claimerSession)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), migrated.Credential.Pk.Counter)
+	equal, err := migrated.Claim.Equal(cred.Claim)
+	require.NoError(t, err)
+	assert.True(t, equal)
+}
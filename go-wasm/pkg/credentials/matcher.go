@@ -0,0 +1,221 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+)
+
+type (
+	// MatchPreferences influences the ranking of the candidates. All fields are
+	// optional.
+	MatchPreferences struct {
+		// CTypeHash is the preferred ctype of the credential.
+		CTypeHash string
+		// Attesters contains the preferred attesters. Credentials of attesters
+		// which appear earlier in the list are ranked higher.
+		Attesters []*gabi.PublicKey
+	}
+
+	// CredentialCandidate is a credential which can be used to answer a partial
+	// presentation request.
+	CredentialCandidate struct {
+		// Index is the position of the credential inside the pool.
+		Index        int
+		Credential   *AttestedClaim
+		AttesterPubK *gabi.PublicKey
+		// CTypeMatch is true if the credential has the preferred ctype.
+		CTypeMatch bool
+		// AttesterRank is the position of the attester inside the preferred
+		// attesters or -1 if the attester is not preferred.
+		AttesterRank int
+		// WitnessUpdated is the time of the accumulator the witness belongs to.
+		WitnessUpdated time.Time
+		// WitnessIndex is the index of the accumulator the witness belongs to.
+		WitnessIndex uint64
+		// Outdated is true if the witness was updated before the time requested
+		// by the verifier. The verifier will only accept the credential if there
+		// is no newer accumulator.
+		Outdated bool
+	}
+
+	// CredentialRejection explains why a credential can not be used to answer a
+	// partial presentation request.
+	CredentialRejection struct {
+		// Index is the position of the credential inside the pool.
+		Index  int
+		Reason error
+	}
+
+	// PartialRequestMatch contains the result of matching the credential pool
+	// against a single partial presentation request. The candidates are ranked,
+	// the best candidate is the first element.
+	PartialRequestMatch struct {
+		Request    *PartialPresentationRequest
+		Candidates []*CredentialCandidate
+		Rejected   []*CredentialRejection
+	}
+)
+
+// Best returns the highest ranked candidate or nil if there is no candidate.
+func (match *PartialRequestMatch) Best() *CredentialCandidate {
+	if len(match.Candidates) < 1 {
+		return nil
+	}
+	return match.Candidates[0]
+}
+
+// MatchPresentationRequest selects the credentials of the pool which can be used
+// to answer the presentation request. The i-th public key must belong to the
+// attester of the i-th credential.
+func MatchPresentationRequest(pool []*AttestedClaim, attesterPubKs []*gabi.PublicKey,
+	request *PresentationRequest, prefs *MatchPreferences) (*PartialRequestMatch, error) {
+	if request.PartialPresentationRequest == nil {
+		return nil, errors.New("missing partial presentation request")
+	}
+	matches, err := matchPartialRequests(pool, attesterPubKs,
+		[]*PartialPresentationRequest{request.PartialPresentationRequest}, prefs)
+	if err != nil {
+		return nil, err
+	}
+	return matches[0], nil
+}
+
+// MatchCombinedPresentationRequest selects the credentials of the pool which
+// can be used to answer each partial request of the combined presentation
+// request. The i-th public key must belong to the attester of the i-th
+// credential. The returned matches have the same order as the partial requests.
+func MatchCombinedPresentationRequest(pool []*AttestedClaim, attesterPubKs []*gabi.PublicKey,
+	request *CombinedPresentationRequest, prefs *MatchPreferences) ([]*PartialRequestMatch, error) {
+	partialRequests := make([]*PartialPresentationRequest, len(request.PartialRequests))
+	for i := range request.PartialRequests {
+		partialRequests[i] = &request.PartialRequests[i]
+	}
+	return matchPartialRequests(pool, attesterPubKs, partialRequests, prefs)
+}
+
+func matchPartialRequests(pool []*AttestedClaim, attesterPubKs []*gabi.PublicKey,
+	partialRequests []*PartialPresentationRequest, prefs *MatchPreferences) ([]*PartialRequestMatch, error) {
+	if len(pool) != len(attesterPubKs) {
+		return nil, fmt.Errorf("expected %d public keys, got %d", len(pool), len(attesterPubKs))
+	}
+	if prefs == nil {
+		prefs = &MatchPreferences{}
+	}
+	preferredIDs := make([]string, len(prefs.Attesters))
+	for i, pk := range prefs.Attesters {
+		id, err := PublicKeyID(pk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %d. preferred attester: %v", i+1, err)
+		}
+		preferredIDs[i] = id.String()
+	}
+
+	matches := make([]*PartialRequestMatch, len(partialRequests))
+	for i, partialReq := range partialRequests {
+		match := &PartialRequestMatch{Request: partialReq}
+		for j, cred := range pool {
+			candidate, err := matchCredential(cred, attesterPubKs[j], partialReq, prefs.CTypeHash, preferredIDs)
+			if err != nil {
+				match.Rejected = append(match.Rejected, &CredentialRejection{
+					Index:  j,
+					Reason: err,
+				})
+				continue
+			}
+			candidate.Index = j
+			match.Candidates = append(match.Candidates, candidate)
+		}
+		sort.SliceStable(match.Candidates, func(p, q int) bool {
+			return rankedBefore(match.Candidates[p], match.Candidates[q])
+		})
+		matches[i] = match
+	}
+	return matches, nil
+}
+
+// matchCredential checks whether the credential can answer the partial request.
+func matchCredential(cred *AttestedClaim, attesterPubK *gabi.PublicKey, partialReq *PartialPresentationRequest,
+	cTypeHash string, preferredIDs []string) (*CredentialCandidate, error) {
+	if cred == nil || cred.Credential == nil {
+		return nil, errors.New("empty credential")
+	}
+	if attesterPubK == nil {
+		return nil, errors.New("missing public key of the attester")
+	}
+	if len(partialReq.RequestedAttributes) < 1 {
+		return nil, errors.New("requested attributes should not be empty")
+	}
+	if time.Now().After(time.Unix(attesterPubK.ExpiryDate, 0)) {
+		return nil, newError(ErrKeyExpired, "public key of the attester expired")
+	}
+	// getAttributeIndices sorts the slice, don't modify the request
+	reqAttributes := append([]string{}, partialReq.RequestedAttributes...)
+	if _, err := cred.getAttributeIndices(reqAttributes); err != nil {
+		return nil, err
+	}
+
+	candidate := &CredentialCandidate{
+		Credential:   cred,
+		AttesterPubK: attesterPubK,
+		CTypeMatch:   cTypeHash != "" && claimCType(cred.Claim) == cTypeHash,
+		AttesterRank: -1,
+	}
+	if len(preferredIDs) > 0 {
+		id, err := PublicKeyID(attesterPubK)
+		if err != nil {
+			return nil, err
+		}
+		for i, preferred := range preferredIDs {
+			if preferred == id.String() {
+				candidate.AttesterRank = i
+				break
+			}
+		}
+	}
+
+	witness := cred.Credential.NonRevocationWitness
+	if witness == nil {
+		if partialReq.ReqNonRevocationProof {
+			return nil, errors.New("missing non revocation witness")
+		}
+		return candidate, nil
+	}
+	revKey, err := attesterPubK.RevocationKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = witness.Verify(revKey); err != nil {
+		return nil, fmt.Errorf("invalid non revocation witness: %v", err)
+	}
+	acc := witness.SignedAccumulator.Accumulator
+	candidate.WitnessUpdated = acc.Time
+	candidate.WitnessIndex = acc.Index
+	candidate.Outdated = partialReq.ReqNonRevocationProof && acc.Time.Before(partialReq.ReqUpdatedAfter)
+	return candidate, nil
+}
+
+// rankedBefore reports whether candidate a should be preferred over candidate
+// b. Candidates are ranked by ctype match, then by the preferred attesters and
+// finally by the freshness of their witness.
+func rankedBefore(a, b *CredentialCandidate) bool {
+	if a.CTypeMatch != b.CTypeMatch {
+		return a.CTypeMatch
+	}
+	if a.AttesterRank != b.AttesterRank {
+		if a.AttesterRank < 0 || b.AttesterRank < 0 {
+			return a.AttesterRank >= 0
+		}
+		return a.AttesterRank < b.AttesterRank
+	}
+	if a.Outdated != b.Outdated {
+		return !a.Outdated
+	}
+	if !a.WitnessUpdated.Equal(b.WitnessUpdated) {
+		return a.WitnessUpdated.After(b.WitnessUpdated)
+	}
+	return a.WitnessIndex > b.WitnessIndex
+}
@@ -0,0 +1,119 @@
+package credentials
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPresentationRequest(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	cred := &AttestedClaim{}
+	err = json.Unmarshal(byteCredential, cred)
+	require.NoError(t, err)
+
+	cred2 := &AttestedClaim{}
+	err = json.Unmarshal(byteCredential2, cred2)
+	require.NoError(t, err)
+
+	reqPresentation := &PresentationRequest{}
+	err = json.Unmarshal(bytePresentationRequest, reqPresentation)
+	require.NoError(t, err)
+	requested := append([]string{}, reqPresentation.PartialPresentationRequest.RequestedAttributes...)
+
+	match, err := MatchPresentationRequest([]*AttestedClaim{cred2, cred},
+		[]*gabi.PublicKey{attester.PublicKey, attester.PublicKey}, reqPresentation, nil)
+	require.NoError(t, err)
+	require.Len(t, match.Candidates, 1)
+	assert.Equal(t, 1, match.Best().Index)
+	assert.Equal(t, cred, match.Best().Credential)
+
+	// the second credential does not contain the gender
+	require.Len(t, match.Rejected, 1)
+	assert.Equal(t, 0, match.Rejected[0].Index)
+	assert.Error(t, match.Rejected[0].Reason)
+
+	// the request must not be modified
+	assert.Equal(t, requested, reqPresentation.PartialPresentationRequest.RequestedAttributes)
+
+	_, err = MatchPresentationRequest([]*AttestedClaim{cred2, cred},
+		[]*gabi.PublicKey{attester.PublicKey}, reqPresentation, nil)
+	assert.Error(t, err)
+}
+
+func TestMatchCombinedPresentationRequest(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	cred := &AttestedClaim{}
+	err = json.Unmarshal(byteCredential, cred)
+	require.NoError(t, err)
+
+	cred2 := &AttestedClaim{}
+	err = json.Unmarshal(byteCredential2, cred2)
+	require.NoError(t, err)
+
+	reqPresentation := &CombinedPresentationRequest{}
+	err = json.Unmarshal(byteCombPresentationRequest, reqPresentation)
+	require.NoError(t, err)
+
+	matches, err := MatchCombinedPresentationRequest([]*AttestedClaim{cred, cred2},
+		[]*gabi.PublicKey{attester.PublicKey, attester.PublicKey}, reqPresentation, &MatchPreferences{
+			CTypeHash: "0xDEADBEEFCOFEE",
+			Attesters: []*gabi.PublicKey{attester.PublicKey},
+		})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+
+	require.NotNil(t, matches[0].Best())
+	assert.Equal(t, 0, matches[0].Best().Index)
+	assert.Equal(t, 0, matches[0].Best().AttesterRank)
+	require.NotNil(t, matches[1].Best())
+	assert.Equal(t, 1, matches[1].Best().Index)
+}
+
+func TestMatchExpiredKey(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+	expiredKey := *attester.PublicKey
+	expiredKey.ExpiryDate = earlier.Unix()
+
+	cred := &AttestedClaim{}
+	err = json.Unmarshal(byteCredential, cred)
+	require.NoError(t, err)
+
+	reqPresentation := &PresentationRequest{}
+	err = json.Unmarshal(bytePresentationRequest, reqPresentation)
+	require.NoError(t, err)
+
+	match, err := MatchPresentationRequest([]*AttestedClaim{cred},
+		[]*gabi.PublicKey{&expiredKey}, reqPresentation, nil)
+	require.NoError(t, err)
+	assert.Nil(t, match.Best())
+	require.Len(t, match.Rejected, 1)
+}
+
+func TestRankCandidates(t *testing.T) {
+	now := time.Now()
+	fresh := &CredentialCandidate{AttesterRank: -1, WitnessUpdated: now}
+	old := &CredentialCandidate{AttesterRank: -1, WitnessUpdated: now.Add(-time.Hour)}
+	preferred := &CredentialCandidate{AttesterRank: 1, WitnessUpdated: now.Add(-time.Hour)}
+	mostPreferred := &CredentialCandidate{AttesterRank: 0, WitnessUpdated: now.Add(-time.Hour)}
+	cTypeMatch := &CredentialCandidate{AttesterRank: -1, CTypeMatch: true, WitnessUpdated: now.Add(-time.Hour)}
+
+	assert.True(t, rankedBefore(fresh, old))
+	assert.False(t, rankedBefore(old, fresh))
+	assert.True(t, rankedBefore(preferred, fresh))
+	assert.True(t, rankedBefore(mostPreferred, preferred))
+	assert.True(t, rankedBefore(cTypeMatch, mostPreferred))
+	assert.False(t, rankedBefore(fresh, fresh))
+}
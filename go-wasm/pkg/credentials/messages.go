@@ -9,17 +9,22 @@ import (
 
 type (
 	// StartSessionMsg is send from the attester to the claimer to start the
-	// attestation session
+	// attestation session. The context is derived from the public key of the
+	// attester, the session id and the ctype hash (see AttestationContext).
 	StartSessionMsg struct {
-		Nonce   *big.Int `json:"nonce"`
-		Context *big.Int `json:"context"`
+		Nonce     *big.Int `json:"nonce"`
+		Context   *big.Int `json:"context"`
+		SessionID *big.Int `json:"sessionId"`
+		CTypeHash string   `json:"cTypeHash,omitempty"`
 	}
 
 	// AttestedClaimRequest is send from the claimer to the attester as a response
-	// to the StartSessionMsg. It contains the values which should get attested.
+	// to the StartSessionMsg. It contains the values which should get attested
+	// and the encoding which is used to transform the claim into attributes.
 	AttestedClaimRequest struct {
 		CommitMsg *gabi.IssueCommitmentMessage `json:"commitMsg"`
 		Claim     Claim                        `json:"claim"`
+		Encoding  ClaimEncoding                `json:"encoding,omitempty"`
 	}
 
 	// PresentationRequest is send from the verifier to the claimer. The
@@ -30,11 +35,15 @@ type (
 		Nonce                      *big.Int                    `json:"nonce"`
 	}
 
-	// PartialPresentationRequest contains partial information for a combined disclosure request
+	// PartialPresentationRequest contains partial information for a combined disclosure request.
+	// HashedAttributes selects requested attributes (like RequestedAttributes)
+	// whose values were hashed and which should be disclosed without their
+	// preimage, so that the verifier only learns the hash.
 	PartialPresentationRequest struct {
 		RequestedAttributes   []string  `json:"requestedAttributes"`
 		ReqNonRevocationProof bool      `json:"reqNonRevocationProof"`
 		ReqUpdatedAfter       time.Time `json:"reqUpdatedAfter"`
+		HashedAttributes      []string  `json:"hashedAttributes,omitempty"`
 	}
 
 	// CombinedPresentationRequest request multiple credentials from a claimer
@@ -46,13 +55,21 @@ type (
 
 	// PresentationResponse represents the message that is send from the claimer to the verifier in order to disclose attributes.
 	// All disclosed attributes are inside the Proof. There should be no attributes elsewhere.
+	// The KeyID identifies the public key of the attester (see PublicKeyID).
+	// Preimages contains the values of disclosed attributes which were hashed.
 	PresentationResponse struct {
-		Proof gabi.ProofD `json:"proof"`
+		Proof     gabi.ProofD  `json:"proof"`
+		KeyID     *big.Int     `json:"keyId,omitempty"`
+		Preimages []*Attribute `json:"preimages,omitempty"`
 	}
 
 	// CombinedPresentationResponse contains a list of proofs. It can be used to
-	// reconstruct multiple claims.
+	// reconstruct multiple claims. KeyIDs identifies the public key of the
+	// attester of each proof (see PublicKeyID), in the same order as the proofs.
+	// Preimages contains the values of the hashed attributes of each proof.
 	CombinedPresentationResponse struct {
-		Proof gabi.ProofList `json:"prooflist"`
+		Proof     gabi.ProofList `json:"prooflist"`
+		KeyIDs    []*big.Int     `json:"keyIds,omitempty"`
+		Preimages [][]*Attribute `json:"preimages,omitempty"`
 	}
 )
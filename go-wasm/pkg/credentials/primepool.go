@@ -0,0 +1,317 @@
+package credentials
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+)
+
+// ErrPoolEmpty is returned if a SafePrimePool contains no pair of safe primes
+// for the requested key length.
+var ErrPoolEmpty = errors.New("safe prime pool contains no suitable pair")
+
+// lockTimeout is the time SafePrimePool waits for another process, which
+// currently modifies the file of the pool.
+const lockTimeout = 30 * time.Second
+
+type (
+	// SafePrimePool stores safe primes for the key generation in a file. The
+	// primes can be precomputed using Fill, e.g. using cmd/primepool, and are
+	// taken by NewAttesterFromPool.
+	//
+	// A prime is removed from the file before it is used for a key and the
+	// SHA-256 hash of every taken prime is kept inside the file, so that it is
+	// rejected if it is added to the pool again. Every prime is checked to be
+	// a safe prime when the pool is loaded. Copies of the file are separate
+	// pools, the primes of a copy are not known to be used.
+	//
+	// The primes are the factors of future private keys, the file must be
+	// kept as secret as the private keys.
+	SafePrimePool struct {
+		path  string
+		mutex sync.Mutex
+		data  safePrimePoolData
+		// verified contains the hashes of the primes which were checked
+		verified map[string]bool
+	}
+
+	// safePrimePoolData is the content of the file of a SafePrimePool. The
+	// primes are stored by their number of bits.
+	safePrimePoolData struct {
+		Primes map[string][]*big.Int `json:"primes"`
+		Used   []string              `json:"used"`
+	}
+)
+
+// OpenSafePrimePool loads the safe prime pool stored at path. If the file does
+// not exist, an empty pool is returned which is created on the first change.
+func OpenSafePrimePool(path string) (*SafePrimePool, error) {
+	pool := &SafePrimePool{path: path, verified: map[string]bool{}}
+	if err := pool.load(); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Len returns the number of safe primes for keys of the given system
+// parameters.
+func (pool *SafePrimePool) Len(sysParams *gabi.SystemParameters) int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return len(pool.data.Primes[primeSizeKey(sysParams)])
+}
+
+// Add adds safe primes for keys of the given system parameters to the pool
+// and stores the pool. Primes which are not suitable for a key are skipped.
+// An error is returned if a prime is not a safe prime of the required size, is
+// already part of the pool or was already taken from the pool.
+func (pool *SafePrimePool) Add(sysParams *gabi.SystemParameters, primes ...*big.Int) error {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return pool.update(func() error {
+		for _, p := range primes {
+			if err := pool.data.add(sysParams, p, pool.verified); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Fill generates safe primes for keys of the given system parameters until
+// the pool contains count primes. Every prime is stored as soon as it is
+// found, the generation stops with the error of the context, if the context
+// is done.
+func (pool *SafePrimePool) Fill(ctx context.Context, sysParams *gabi.SystemParameters, count int,
+	progress ProgressFunc) error {
+	if progress == nil {
+		progress = func(KeyGenProgress) {}
+	}
+	status := KeyGenProgress{Stage: StageSafePrimes}
+	for pool.Len(sysParams) < count {
+		p, err := generateSafePrime(ctx, int(sysParams.Ln/2), &status, progress)
+		if err != nil {
+			return err
+		}
+		status.Primes++
+		progress(status)
+		if err := pool.Add(sysParams, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TakePair removes a pair of safe primes for a key of the given system
+// parameters from the pool. The pool is stored before the primes are
+// returned. ErrPoolEmpty is returned if the pool contains no suitable pair.
+func (pool *SafePrimePool) TakePair(sysParams *gabi.SystemParameters) (*big.Int, *big.Int, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	var p, q *big.Int
+	err := pool.update(func() error {
+		key := primeSizeKey(sysParams)
+		primes := pool.data.Primes[key]
+		for i := range primes {
+			for j := i + 1; j < len(primes); j++ {
+				if !isSafePrimePair(sysParams, primes[i], primes[j]) {
+					continue
+				}
+				p, q = primes[i], primes[j]
+				remaining := append(append(append([]*big.Int{}, primes[:i]...), primes[i+1:j]...), primes[j+1:]...)
+				pool.data.Primes[key] = remaining
+				pool.data.Used = append(pool.data.Used, primeHash(p), primeHash(q))
+				return nil
+			}
+		}
+		return ErrPoolEmpty
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, q, nil
+}
+
+// NewAttesterFromPool creates a new attester like NewAttesterContext, but
+// takes the safe primes of the key from the pool. No primes are taken if the
+// context is already done. The proof of the public key is not created (see
+// ProveKey).
+func NewAttesterFromPool(ctx context.Context, pool *SafePrimePool, sysParams *gabi.SystemParameters,
+	attributeCount int, periodOfValidity int64, counter uint, progress ProgressFunc) (*Attester, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, q, err := pool.TakePair(sysParams)
+	if err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		progress(KeyGenProgress{Stage: StageKeyPair})
+	}
+	expiryDate := time.Now().Add(time.Duration(periodOfValidity))
+	privK, pubK, err := GenerateKeyPairFromPrimes(sysParams, attributeCount, counter, expiryDate, p, q)
+	if err != nil {
+		return nil, err
+	}
+	return &Attester{
+		PrivateKey: privK,
+		PublicKey:  pubK,
+	}, nil
+}
+
+// update reloads the pool, applies the change and stores the pool. The file
+// is locked in the meantime, so that other processes can share the pool.
+func (pool *SafePrimePool) update(change func() error) error {
+	unlock, err := lockFile(pool.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := pool.load(); err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	return pool.store()
+}
+
+// load reads the file of the pool and checks every prime.
+func (pool *SafePrimePool) load() error {
+	data := safePrimePoolData{}
+	bytes, err := ioutil.ReadFile(pool.path)
+	if os.IsNotExist(err) {
+		pool.data = safePrimePoolData{Primes: map[string][]*big.Int{}}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return fmt.Errorf("could not parse safe prime pool: %w", err)
+	}
+
+	// add all primes again, this checks that they are unique, unused and safe.
+	// Primes which were already checked by this pool are not checked again.
+	loaded := safePrimePoolData{Primes: map[string][]*big.Int{}, Used: data.Used}
+	for key, primes := range data.Primes {
+		numBits, err := strconv.Atoi(key)
+		if err != nil || numBits <= 0 {
+			return fmt.Errorf("invalid prime size '%s' in safe prime pool", key)
+		}
+		for _, p := range primes {
+			if err := loaded.addPrime(numBits, p, pool.verified); err != nil {
+				return err
+			}
+		}
+	}
+	pool.data = loaded
+	return nil
+}
+
+// store writes the pool into a temporary file and replaces the file of the
+// pool, so that the file is never partially written.
+func (pool *SafePrimePool) store() error {
+	bytes, err := json.MarshalIndent(pool.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(pool.path), filepath.Base(pool.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), pool.path)
+}
+
+// add adds a safe prime for keys of the given system parameters. Safe primes
+// which are not suitable for a key are skipped.
+func (data *safePrimePoolData) add(sysParams *gabi.SystemParameters, p *big.Int, verified map[string]bool) error {
+	if p != nil && !isUsableSafePrime(p) {
+		return nil
+	}
+	return data.addPrime(int(sysParams.Ln/2), p, verified)
+}
+
+// addPrime adds the safe prime of the given number of bits. The hashes of
+// checked primes are recorded in verified.
+func (data *safePrimePoolData) addPrime(numBits int, p *big.Int, verified map[string]bool) error {
+	if p == nil {
+		return errors.New("safe prime pool contains an empty prime")
+	}
+	hash := primeHash(p)
+	if !verified[hash] || p.BitLen() != numBits {
+		if !isSafePrime(p, numBits) || !isUsableSafePrime(p) {
+			return fmt.Errorf("safe prime pool contains an invalid prime of %d bits", numBits)
+		}
+		verified[hash] = true
+	}
+	for _, used := range data.Used {
+		if used == hash {
+			return errors.New("safe prime was already used for a key")
+		}
+	}
+	key := strconv.Itoa(numBits)
+	for _, q := range data.Primes[key] {
+		if q.Cmp(p) == 0 {
+			return errors.New("safe prime is already part of the pool")
+		}
+	}
+	data.Primes[key] = append(data.Primes[key], p)
+	return nil
+}
+
+// primeSizeKey returns the key of the primes for keys of the given system
+// parameters.
+func primeSizeKey(sysParams *gabi.SystemParameters) string {
+	return strconv.Itoa(int(sysParams.Ln / 2))
+}
+
+// primeHash returns the hex encoded SHA-256 hash of the big-endian bytes of p.
+func primeHash(p *big.Int) string {
+	hash := sha256.Sum256(p.Bytes())
+	return hex.EncodeToString(hash[:])
+}
+
+// lockFile creates the lock file at path. It waits up to lockTimeout while the
+// file exists. The returned function removes the lock file.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("safe prime pool is locked, remove %s if no other process uses the pool", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
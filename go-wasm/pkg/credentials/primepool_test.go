@@ -0,0 +1,163 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempPoolPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "primepool")
+	require.NoError(t, err)
+	return filepath.Join(dir, "primes.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestSafePrimePool(t *testing.T) {
+	sysParams := smallSysParams(t)
+	path, cleanup := tempPoolPath(t)
+	defer cleanup()
+
+	pool, err := OpenSafePrimePool(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pool.Len(sysParams))
+	_, _, err = pool.TakePair(sysParams)
+	assert.True(t, errors.Is(err, ErrPoolEmpty))
+
+	// random primes do not always contain a suitable pair
+	p, q, err := generateSafePrimePair(context.Background(), sysParams, func(KeyGenProgress) {})
+	require.NoError(t, err)
+	require.NoError(t, pool.Add(sysParams, p, q))
+	require.NoError(t, pool.Fill(context.Background(), sysParams, 8, nil))
+	assert.Equal(t, 8, pool.Len(sysParams))
+
+	pool, err = OpenSafePrimePool(path)
+	require.NoError(t, err)
+	assert.Equal(t, 8, pool.Len(sysParams))
+
+	p, q, err = pool.TakePair(sysParams)
+	require.NoError(t, err)
+	assert.NoError(t, checkSafePrimePair(sysParams, p, q))
+	assert.Equal(t, 6, pool.Len(sysParams))
+
+	// the primes are removed from the file and can not be added again
+	pool, err = OpenSafePrimePool(path)
+	require.NoError(t, err)
+	assert.Equal(t, 6, pool.Len(sysParams))
+	assert.Error(t, pool.Add(sysParams, p))
+	assert.Error(t, pool.Add(sysParams, q))
+	assert.Equal(t, 6, pool.Len(sysParams))
+
+	// the keys use the primes
+	privK, pubK, err := GenerateKeyPairFromPrimes(sysParams, 3, 0, time.Now(), p, q)
+	require.NoError(t, err)
+	assert.Equal(t, 0, privK.P.Cmp(p))
+	assert.Equal(t, 0, pubK.N.Cmp(new(big.Int).Mul(p, q)))
+
+	// take all primes, the pairs are never repeated
+	taken := map[string]bool{primeHash(p): true, primeHash(q): true}
+	for {
+		p, q, err := pool.TakePair(sysParams)
+		if errors.Is(err, ErrPoolEmpty) {
+			break
+		}
+		require.NoError(t, err)
+		assert.False(t, taken[primeHash(p)])
+		assert.False(t, taken[primeHash(q)])
+		taken[primeHash(p)], taken[primeHash(q)] = true, true
+	}
+}
+
+func TestSafePrimePoolInvalid(t *testing.T) {
+	sysParams := smallSysParams(t)
+	path, cleanup := tempPoolPath(t)
+	defer cleanup()
+
+	pool, err := OpenSafePrimePool(path)
+	require.NoError(t, err)
+	require.NoError(t, pool.Fill(context.Background(), sysParams, 1, nil))
+	assert.Error(t, pool.Add(sysParams, big.NewInt(15)))
+	assert.Error(t, pool.Add(sysParams, nil))
+
+	// a prime which is already inside the pool
+	bytes, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data := safePrimePoolData{}
+	require.NoError(t, json.Unmarshal(bytes, &data))
+	key := primeSizeKey(sysParams)
+	require.Len(t, data.Primes[key], 1)
+	assert.Error(t, pool.Add(sysParams, data.Primes[key][0]))
+
+	// the primes are checked on load
+	for _, invalid := range [][]*big.Int{
+		{new(big.Int).Add(data.Primes[key][0], big.NewInt(2))},
+		{data.Primes[key][0], data.Primes[key][0]},
+	} {
+		bytes, err := json.Marshal(safePrimePoolData{Primes: map[string][]*big.Int{key: invalid}})
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, bytes, 0600))
+		_, err = OpenSafePrimePool(path)
+		assert.Error(t, err)
+	}
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	_, err = OpenSafePrimePool(path)
+	assert.Error(t, err)
+
+	_, _, err = GenerateKeyPairFromPrimes(sysParams, 3, 0, time.Now(), big.NewInt(23), big.NewInt(47))
+	assert.Error(t, err)
+}
+
+func TestNewAttesterFromPool(t *testing.T) {
+	sysParams := smallSysParams(t)
+	path, cleanup := tempPoolPath(t)
+	defer cleanup()
+
+	pool, err := OpenSafePrimePool(path)
+	require.NoError(t, err)
+	p, q, err := generateSafePrimePair(context.Background(), sysParams, func(KeyGenProgress) {})
+	require.NoError(t, err)
+	require.NoError(t, pool.Add(sysParams, p, q))
+
+	// no primes are taken if the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewAttesterFromPool(ctx, pool, sysParams, 3, OneYear, 1, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 2, pool.Len(sysParams))
+
+	stages := []string{}
+	attester, err := NewAttesterFromPool(context.Background(), pool, sysParams, 3, OneYear, 1, func(progress KeyGenProgress) {
+		stages = append(stages, progress.Stage)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, pool.Len(sysParams))
+	assert.Equal(t, []string{StageKeyPair}, stages)
+	assert.Equal(t, uint(1), attester.PublicKey.Counter)
+	assert.Nil(t, attester.PublicKeyProof, "the proof is created by ProveKey")
+}
+
+func TestSafePrimePoolCanceled(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+	path, cleanup := tempPoolPath(t)
+	defer cleanup()
+
+	pool, err := OpenSafePrimePool(path)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = pool.Fill(ctx, sysParams, 2, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	_, err = NewAttesterFromPool(context.Background(), pool, sysParams, 5, OneYear, 0, nil)
+	assert.True(t, errors.Is(err, ErrPoolEmpty))
+}
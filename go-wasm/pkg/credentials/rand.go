@@ -0,0 +1,23 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/privacybydesign/gabi/big"
+)
+
+// randomBigInt returns a uniformly random integer of at most the given number
+// of bits, which is read from rnd. If rnd is nil, crypto/rand is used.
+//
+// Only nonces, contexts, session ids and secrets are read from rnd. gabi has no
+// parameter for the source of randomness and always reads the randomness of
+// keys, commitments, signatures and proofs from crypto/rand. Setting rnd
+// therefore does not make these values reproducible.
+func randomBigInt(rnd io.Reader, numBits uint) (*big.Int, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), numBits)
+	return big.RandInt(rnd, max)
+}
@@ -0,0 +1,74 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errReader is a randomness source which always fails.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestRequestPresentationWithRand(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success, "Error in sysparams")
+	disclosedAttr := []string{"contents.special", "ctype"}
+
+	session1, msg1, err := RequestPresentationWithRand(rand.New(rand.NewSource(1)), sysParams, disclosedAttr, true, future, false)
+	require.NoError(t, err)
+	session2, msg2, err := RequestPresentationWithRand(rand.New(rand.NewSource(1)), sysParams, disclosedAttr, true, future, false)
+	require.NoError(t, err)
+	assert.Equal(t, session1, session2)
+	assert.Equal(t, msg1, msg2)
+	assert.NotEqual(t, msg1.Context, msg1.Nonce)
+
+	_, _, err = RequestPresentationWithRand(errReader{}, sysParams, disclosedAttr, true, future, false)
+	assert.EqualError(t, err, "no entropy")
+	_, _, err = RequestCombinedPresentationWithRand(errReader{}, sysParams, []PartialPresentationRequest{}, false)
+	assert.EqualError(t, err, "no entropy")
+}
+
+func TestClaimerWithRand(t *testing.T) {
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success, "Error in sysparams")
+
+	claimer1, err := NewClaimerWithRand(sysParams, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	claimer2, err := NewClaimerWithRand(sysParams, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	assert.Equal(t, claimer1.MasterSecret, claimer2.MasterSecret)
+
+	_, err = NewClaimerWithRand(sysParams, errReader{})
+	assert.EqualError(t, err, "no entropy")
+
+	// the source is not serialized
+	bts, err := json.Marshal(claimer1)
+	require.NoError(t, err)
+	assert.NotContains(t, string(bts), "Rand")
+}
+
+func TestAttesterWithRand(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+
+	attester.Rand = rand.New(rand.NewSource(1))
+	session1, _, err := attester.InitiateAttestation()
+	require.NoError(t, err)
+	attester.Rand = rand.New(rand.NewSource(1))
+	session2, _, err := attester.InitiateAttestation()
+	require.NoError(t, err)
+	assert.Equal(t, session1, session2)
+
+	attester.Rand = errReader{}
+	_, _, err = attester.InitiateAttestation()
+	assert.EqualError(t, err, "no entropy")
+}
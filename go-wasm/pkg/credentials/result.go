@@ -0,0 +1,130 @@
+package credentials
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/privacybydesign/gabi/big"
+)
+
+// The checks which are performed during the verification of a presentation.
+const (
+	// CheckProof verifies the zero knowledge proof of the presentation.
+	CheckProof = "proof"
+	// CheckNonRevocationProof checks that the presentation contains a non
+	// revocation proof. The proof itself is verified by CheckProof.
+	CheckNonRevocationProof = "nonRevocationProof"
+	// CheckAccumulatorSignature verifies the signature of the accumulator
+	// which was used by the non revocation proof.
+	CheckAccumulatorSignature = "accumulatorSignature"
+	// CheckAccumulatorFreshness checks that the accumulator was created after
+	// ReqUpdatedAfter or is the latest accumulator of the attester.
+	CheckAccumulatorFreshness = "accumulatorFreshness"
+	// CheckDisclosure decodes the disclosed attributes and their preimages.
+	CheckDisclosure = "disclosure"
+)
+
+type (
+	// Check is the outcome of a single check. If the check failed, Error
+	// describes the failure.
+	Check struct {
+		Name   string `json:"name"`
+		Passed bool   `json:"passed"`
+		Error  string `json:"error,omitempty"`
+		err    error
+	}
+
+	// AccumulatorInfo describes the accumulator used by a non revocation
+	// proof.
+	AccumulatorInfo struct {
+		Index uint64    `json:"index"`
+		Time  time.Time `json:"time"`
+	}
+
+	// VerificationResult lists the checks which were performed to verify a
+	// presentation together with the key of the attester and the accumulator
+	// which were used. Claim contains the disclosed claim, if all checks passed.
+	// HashedAttributes lists the disclosed attributes whose preimage was not
+	// disclosed, Claim contains the hex encoded SHA-256 hash of their value.
+	VerificationResult struct {
+		Checks           []*Check         `json:"checks"`
+		KeyID            *big.Int         `json:"keyId"`
+		KeyCounter       uint             `json:"keyCounter"`
+		Accumulator      *AccumulatorInfo `json:"accumulator,omitempty"`
+		Claim            Claim            `json:"claim"`
+		HashedAttributes []string         `json:"hashedAttributes,omitempty"`
+	}
+
+	// CombinedVerificationResult contains the checks of the combined proof
+	// and a VerificationResult for every credential of a combined
+	// presentation. The outcome of the combined proof is also the CheckProof
+	// of every VerificationResult.
+	CombinedVerificationResult struct {
+		Checks  []*Check              `json:"checks"`
+		Results []*VerificationResult `json:"results"`
+	}
+)
+
+// addCheck records the outcome of a check. The check passed if err is nil.
+func addCheck(checks []*Check, name string, err error) []*Check {
+	check := &Check{Name: name, Passed: err == nil, err: err}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return append(checks, check)
+}
+
+// firstError returns the error of the first failed check.
+func firstError(checks []*Check) error {
+	for _, check := range checks {
+		if !check.Passed {
+			return check.err
+		}
+	}
+	return nil
+}
+
+// Verified reports whether all checks passed.
+func (result *VerificationResult) Verified() bool {
+	return len(result.Checks) > 0 && firstError(result.Checks) == nil
+}
+
+// Err returns the error of the first failed check, e.g. an error wrapping
+// ErrProofInvalid or ErrAccumulatorStale. It returns nil if all checks
+// passed.
+func (result *VerificationResult) Err() error {
+	return firstError(result.Checks)
+}
+
+// Verified reports whether all checks of the combined proof and of every
+// credential passed.
+func (result *CombinedVerificationResult) Verified() bool {
+	return len(result.Checks) > 0 && result.Err() == nil
+}
+
+// Err returns the error of the first failed check like
+// VerificationResult.Err. Errors of a credential are prefixed by its
+// position.
+func (result *CombinedVerificationResult) Err() error {
+	if err := firstError(result.Checks); err != nil {
+		return err
+	}
+	for i, r := range result.Results {
+		if err := r.Err(); err != nil {
+			return fmt.Errorf("%d. proof: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Claims returns the disclosed claims, if all checks passed.
+func (result *CombinedVerificationResult) Claims() []Claim {
+	if !result.Verified() {
+		return nil
+	}
+	claims := make([]Claim, len(result.Results))
+	for i, r := range result.Results {
+		claims[i] = r.Claim
+	}
+	return claims
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationResult(t *testing.T) {
+	result := &VerificationResult{}
+	assert.False(t, result.Verified())
+	assert.NoError(t, result.Err())
+
+	result.Checks = addCheck(result.Checks, CheckProof, nil)
+	result.Checks = addCheck(result.Checks, CheckDisclosure, nil)
+	assert.True(t, result.Verified())
+	assert.NoError(t, result.Err())
+
+	stale := newError(ErrAccumulatorStale, "stale")
+	result.Checks = addCheck(result.Checks, CheckAccumulatorFreshness, stale)
+	result.Checks = addCheck(result.Checks, CheckAccumulatorSignature, errors.New("invalid"))
+	assert.False(t, result.Verified())
+	assert.Equal(t, stale, result.Err())
+	assert.Equal(t, &Check{Name: CheckAccumulatorFreshness, Error: "stale", err: stale}, result.Checks[2])
+}
+
+func TestCombinedVerificationResult(t *testing.T) {
+	result := &CombinedVerificationResult{
+		Checks: addCheck(nil, CheckProof, nil),
+		Results: []*VerificationResult{
+			{Checks: addCheck(nil, CheckDisclosure, nil), Claim: Claim{"a": 1.}},
+			{Checks: addCheck(nil, CheckDisclosure, nil), Claim: Claim{"b": 2.}},
+		},
+	}
+	assert.True(t, result.Verified())
+	assert.Equal(t, []Claim{{"a": 1.}, {"b": 2.}}, result.Claims())
+
+	result.Results[1].Checks = addCheck(nil, CheckNonRevocationProof, newError(ErrProofInvalid, "missing"))
+	assert.False(t, result.Verified())
+	assert.Nil(t, result.Claims())
+	assert.EqualError(t, result.Err(), "2. proof: missing")
+	assert.True(t, errors.Is(result.Err(), ErrProofInvalid))
+
+	result.Checks = addCheck(nil, CheckProof, newError(ErrProofInvalid, "invalid"))
+	assert.EqualError(t, result.Err(), "invalid")
+}
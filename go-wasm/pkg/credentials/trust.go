@@ -0,0 +1,269 @@
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/privacybydesign/gabi/signed"
+)
+
+// maxTrustListClockSkew is the time a trust list may be issued ahead of the
+// clock of the verifier.
+const maxTrustListClockSkew = time.Minute
+
+type (
+	// TrustedAttester describes an attester which is known to a verifier. The
+	// keyring contains all key versions of the attester, CTypes lists the
+	// ctypes for which attestations of the attester are accepted. A revoked
+	// attester is not trusted at all, regardless of its keys.
+	TrustedAttester struct {
+		ID      string         `json:"id"`
+		Keyring *PublicKeyring `json:"keyring"`
+		CTypes  []string       `json:"ctypes"`
+		Revoked bool           `json:"revoked,omitempty"`
+	}
+
+	// TrustRegistry contains the attesters which are trusted by a verifier.
+	TrustRegistry struct {
+		Attesters []*TrustedAttester `json:"attesters"`
+	}
+
+	// TrustListInfo describes a version of a signed trust list. The sequence
+	// number is increased by the maintainer whenever a new version of the
+	// list is signed, so that verifiers can reject older versions.
+	TrustListInfo struct {
+		Sequence  uint64    `json:"sequence"`
+		IssuedAt  time.Time `json:"issuedAt"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+
+	// SignedTrustList is the signed JSON encoding of a TrustRegistry together
+	// with its TrustListInfo. The signature is an ECDSA signature (see
+	// signed.Sign) over the bytes of List, so that the list is verified
+	// exactly as it was signed.
+	SignedTrustList struct {
+		List      json.RawMessage `json:"list"`
+		Signature []byte          `json:"signature"`
+	}
+
+	// trustListContent is the signed payload of a SignedTrustList.
+	trustListContent struct {
+		TrustListInfo
+		Attesters []*TrustedAttester `json:"attesters"`
+	}
+)
+
+// NewTrustRegistry creates a registry containing the given attesters. The
+// identities of the attesters must be unique.
+func NewTrustRegistry(attesters ...*TrustedAttester) (*TrustRegistry, error) {
+	registry := &TrustRegistry{}
+	for _, attester := range attesters {
+		if err := registry.Add(attester); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Add adds an attester to the registry.
+func (registry *TrustRegistry) Add(attester *TrustedAttester) error {
+	if attester == nil || attester.ID == "" {
+		return errors.New("missing attester id")
+	}
+	if attester.Keyring == nil || len(attester.Keyring.Keys) < 1 {
+		return fmt.Errorf("attester %q has no keys", attester.ID)
+	}
+	if _, err := registry.Attester(attester.ID); err == nil {
+		return fmt.Errorf("attester %q already exists", attester.ID)
+	}
+	registry.Attesters = append(registry.Attesters, attester)
+	return nil
+}
+
+// Attester returns the attester with the given identity.
+func (registry *TrustRegistry) Attester(id string) (*TrustedAttester, error) {
+	for _, attester := range registry.Attesters {
+		if attester.ID == id {
+			return attester, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown attester %q", id)
+}
+
+// Revoke marks the attester with the given identity as revoked.
+func (registry *TrustRegistry) Revoke(id string) error {
+	attester, err := registry.Attester(id)
+	if err != nil {
+		return err
+	}
+	attester.Revoked = true
+	return nil
+}
+
+// Lookup returns the attester owning the public key with the given identifier
+// (see PublicKeyID) and the public key itself. Revoked attesters and expired
+// keys are not returned.
+func (registry *TrustRegistry) Lookup(keyID *big.Int) (*TrustedAttester, *gabi.PublicKey, error) {
+	if keyID == nil {
+		return nil, nil, errors.New("missing key id")
+	}
+	for _, attester := range registry.Attesters {
+		for _, key := range attester.Keyring.Keys {
+			id, err := PublicKeyID(key)
+			if err != nil {
+				return nil, nil, err
+			}
+			if id.Cmp(keyID) != 0 {
+				continue
+			}
+			if attester.Revoked {
+				return nil, nil, newError(ErrUntrusted, "attester %q is revoked", attester.ID)
+			}
+			pubK, err := attester.Keyring.Lookup(keyID)
+			if err != nil {
+				return nil, nil, err
+			}
+			return attester, pubK, nil
+		}
+	}
+	return nil, nil, newError(ErrUntrusted, "key does not belong to a trusted attester")
+}
+
+// Trusts reports whether the attester is trusted for the given ctype.
+func (attester *TrustedAttester) Trusts(cType string) bool {
+	if attester.Revoked || cType == "" {
+		return false
+	}
+	for _, c := range attester.CTypes {
+		if c == cType {
+			return true
+		}
+	}
+	return false
+}
+
+// AttestersFor returns the identities of all attesters which are trusted for
+// the given ctype.
+func (registry *TrustRegistry) AttestersFor(cType string) []string {
+	var ids []string
+	for _, attester := range registry.Attesters {
+		if attester.Trusts(cType) {
+			ids = append(ids, attester.ID)
+		}
+	}
+	return ids
+}
+
+// Sign signs the JSON encoding of the registry using the ECDSA key of the
+// maintainer of the trust list. The list is valid for the given duration and
+// sequence must be greater than the sequence of the previously signed list.
+func (registry *TrustRegistry) Sign(sk *ecdsa.PrivateKey, sequence uint64, validity time.Duration) (*SignedTrustList, error) {
+	if validity <= 0 {
+		return nil, errors.New("validity of the trust list must be positive")
+	}
+	now := time.Now()
+	list, err := json.Marshal(&trustListContent{
+		TrustListInfo: TrustListInfo{
+			Sequence:  sequence,
+			IssuedAt:  now,
+			ExpiresAt: now.Add(validity),
+		},
+		Attesters: registry.Attesters,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signed.Sign(sk, list)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedTrustList{
+		List:      list,
+		Signature: signature,
+	}, nil
+}
+
+// NewTrustRegistryFromSignedList verifies the signature of the trust list
+// using the ECDSA key of the maintainer of the list and returns the contained
+// registry together with the version of the list. Expired lists, lists which
+// are issued in the future and lists with a sequence number smaller than
+// minSequence are rejected, callers should pass the sequence of the last
+// accepted list to prevent the replay of older lists. The keys of the
+// attesters are not verified, Verify should be called for lists of untrusted
+// origin.
+func NewTrustRegistryFromSignedList(pk *ecdsa.PublicKey, trustList []byte, minSequence uint64) (*TrustRegistry, *TrustListInfo, error) {
+	list := &SignedTrustList{}
+	if err := json.Unmarshal(trustList, list); err != nil {
+		return nil, nil, err
+	}
+	if err := signed.Verify(pk, list.List, list.Signature); err != nil {
+		return nil, nil, newError(ErrProofInvalid, "invalid signature of the trust list: %v", err)
+	}
+	content := &trustListContent{}
+	if err := json.Unmarshal(list.List, content); err != nil {
+		return nil, nil, err
+	}
+	if err := content.TrustListInfo.check(time.Now(), minSequence); err != nil {
+		return nil, nil, err
+	}
+	registry, err := NewTrustRegistry(content.Attesters...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return registry, &content.TrustListInfo, nil
+}
+
+// check verifies that the list is valid at the given time and not older than
+// the list with the sequence number minSequence.
+func (info *TrustListInfo) check(now time.Time, minSequence uint64) error {
+	if info.IssuedAt.IsZero() || info.ExpiresAt.IsZero() {
+		return newError(ErrUntrusted, "trust list has no period of validity")
+	}
+	if now.Add(maxTrustListClockSkew).Before(info.IssuedAt) {
+		return newError(ErrUntrusted, "trust list is issued in the future (%v)", info.IssuedAt)
+	}
+	if !now.Before(info.ExpiresAt) {
+		return newError(ErrUntrusted, "trust list expired at %v", info.ExpiresAt)
+	}
+	if info.Sequence < minSequence {
+		return newError(ErrUntrusted, "trust list %d was replaced by list %d", info.Sequence, minSequence)
+	}
+	return nil
+}
+
+// Verify verifies the public key proofs of all attesters in the registry.
+func (registry *TrustRegistry) Verify() error {
+	for _, attester := range registry.Attesters {
+		if err := attester.Keyring.Verify(); err != nil {
+			return fmt.Errorf("attester %q: %v", attester.ID, err)
+		}
+	}
+	return nil
+}
+
+// VerifyTrustedPresentation verifies the response of a claimer using the key
+// of a trusted attester. The key is resolved using the key id of the response
+// and the attested claim must disclose a ctype for which the attester is
+// trusted. If the presentation is valid, the identity of the matched attester
+// is returned together with the disclosed claim.
+func VerifyTrustedPresentation(registry *TrustRegistry, latestAccs []*revocation.SignedAccumulator,
+	response *PresentationResponse, session *VerifierSession) (bool, Claim, string, error) {
+	attester, _, err := registry.Lookup(response.KeyID)
+	if err != nil {
+		return false, nil, "", err
+	}
+	verified, claim, err := VerifyKeyringPresentation(attester.Keyring, latestAccs, response, session)
+	if err != nil || !verified {
+		return false, nil, "", err
+	}
+	if cType := claimCType(claim); !attester.Trusts(cType) {
+		return false, nil, "", newError(ErrUntrusted, "attester %q is not trusted for ctype %q", attester.ID, cType)
+	}
+	return true, claim, attester.ID, nil
+}
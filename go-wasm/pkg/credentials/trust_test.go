@@ -0,0 +1,189 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/privacybydesign/gabi/signed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTrustRegistry(t *testing.T) (*TrustRegistry, *Attester) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	registry, err := NewTrustRegistry(&TrustedAttester{
+		ID:      "did:kilt:attester",
+		Keyring: &PublicKeyring{Keys: []*gabi.PublicKey{attester.PublicKey}},
+		CTypes:  []string{"0xDEADBEEFCOFEE"},
+	})
+	require.NoError(t, err)
+	return registry, attester
+}
+
+func TestTrustRegistry(t *testing.T) {
+	registry, attester := newTestTrustRegistry(t)
+	keyID, err := PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+
+	trusted, pubK, err := registry.Lookup(keyID)
+	require.NoError(t, err)
+	assert.Equal(t, "did:kilt:attester", trusted.ID)
+	assert.Equal(t, attester.PublicKey, pubK)
+	assert.True(t, trusted.Trusts("0xDEADBEEFCOFEE"))
+	assert.False(t, trusted.Trusts("0xC0FFEE"))
+	assert.False(t, trusted.Trusts(""))
+	assert.Equal(t, []string{"did:kilt:attester"}, registry.AttestersFor("0xDEADBEEFCOFEE"))
+	assert.Empty(t, registry.AttestersFor("0xC0FFEE"))
+
+	// identities must be unique and attesters need keys
+	assert.Error(t, registry.Add(&TrustedAttester{ID: "did:kilt:attester", Keyring: trusted.Keyring}))
+	assert.Error(t, registry.Add(&TrustedAttester{ID: "did:kilt:other"}))
+
+	_, _, err = registry.Lookup(nil)
+	assert.Error(t, err)
+
+	require.NoError(t, registry.Revoke("did:kilt:attester"))
+	_, _, err = registry.Lookup(keyID)
+	assert.True(t, errors.Is(err, ErrUntrusted))
+	assert.False(t, trusted.Trusts("0xDEADBEEFCOFEE"))
+	assert.Error(t, registry.Revoke("did:kilt:other"))
+}
+
+func TestSignedTrustList(t *testing.T) {
+	registry, _ := newTestTrustRegistry(t)
+	sk, err := signed.GenerateKey()
+	require.NoError(t, err)
+	otherSK, err := signed.GenerateKey()
+	require.NoError(t, err)
+
+	list, err := registry.Sign(sk, 2, time.Hour)
+	require.NoError(t, err)
+	bts, err := json.Marshal(list)
+	require.NoError(t, err)
+
+	loaded, info, err := NewTrustRegistryFromSignedList(&sk.PublicKey, bts, 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), info.Sequence)
+	assert.True(t, info.ExpiresAt.After(info.IssuedAt))
+	expected, err := json.Marshal(registry)
+	require.NoError(t, err)
+	actual, err := json.Marshal(loaded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+
+	_, _, err = NewTrustRegistryFromSignedList(&otherSK.PublicKey, bts, 0)
+	assert.Error(t, err)
+
+	// lists which were replaced by a newer list are rejected
+	_, _, err = NewTrustRegistryFromSignedList(&sk.PublicKey, bts, 3)
+	assert.True(t, errors.Is(err, ErrUntrusted))
+
+	_, err = registry.Sign(sk, 3, 0)
+	assert.Error(t, err, "list without period of validity")
+
+	// the list must not be modified after signing
+	list.List = json.RawMessage(`{"sequence":3,"attesters":[]}`)
+	bts, err = json.Marshal(list)
+	require.NoError(t, err)
+	_, _, err = NewTrustRegistryFromSignedList(&sk.PublicKey, bts, 0)
+	assert.Error(t, err)
+
+	// signed lists with duplicate attesters are rejected
+	registry.Attesters = append(registry.Attesters, registry.Attesters[0])
+	list, err = registry.Sign(sk, 3, time.Hour)
+	require.NoError(t, err)
+	bts, err = json.Marshal(list)
+	require.NoError(t, err)
+	_, _, err = NewTrustRegistryFromSignedList(&sk.PublicKey, bts, 0)
+	assert.Error(t, err)
+}
+
+func TestTrustListInfo(t *testing.T) {
+	now := time.Now()
+	info := &TrustListInfo{Sequence: 2, IssuedAt: now, ExpiresAt: now.Add(time.Hour)}
+	assert.NoError(t, info.check(now, 2))
+	assert.NoError(t, info.check(now.Add(-time.Second), 0), "small clock skew")
+
+	for name, err := range map[string]error{
+		"expired":         info.check(now.Add(time.Hour), 0),
+		"issued later":    info.check(now.Add(-time.Hour), 0),
+		"replaced":        info.check(now, 3),
+		"without expiry":  (&TrustListInfo{IssuedAt: now}).check(now, 0),
+		"without issuing": (&TrustListInfo{ExpiresAt: now.Add(time.Hour)}).check(now, 0),
+	} {
+		assert.True(t, errors.Is(err, ErrUntrusted), name)
+	}
+}
+
+func TestVerifyTrustedPresentation(t *testing.T) {
+	registry, attester := newTestTrustRegistry(t)
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+
+	claimer, cred, update := issueTestClaim(t, attester, Claim{
+		"ctype":    "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{"name": "Anna"},
+	})
+	session, request, err := RequestPresentation(sysParams, []string{"ctype", "contents.name"}, true, future, false)
+	require.NoError(t, err)
+	response, err := claimer.BuildPresentation(attester.PublicKey, cred, request)
+	require.NoError(t, err)
+	accs := []*revocation.SignedAccumulator{update.SignedAccumulator}
+
+	verified, claim, id, err := VerifyTrustedPresentation(registry, accs, response, session)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	assert.Equal(t, "did:kilt:attester", id)
+	assert.Equal(t, "0xDEADBEEFCOFEE", claim["ctype"])
+	assert.Equal(t, "Anna", claim["contents"].(map[string]interface{})["name"])
+
+	// the keys of all attesters start with counter 0, the accumulator of
+	// another attester is skipped
+	other := newTestAttester(t, smallSysParams(t), 3, 0)
+	require.Equal(t, attester.PublicKey.Counter, other.PublicKey.Counter)
+	otherUpdate, err := other.CreateAccumulator()
+	require.NoError(t, err)
+	require.NoError(t, registry.Add(&TrustedAttester{
+		ID:      "did:kilt:other",
+		Keyring: &PublicKeyring{Keys: []*gabi.PublicKey{other.PublicKey}},
+		CTypes:  []string{"0xDEADBEEFCOFEE"},
+	}))
+	verified, _, id, err = VerifyTrustedPresentation(registry,
+		[]*revocation.SignedAccumulator{otherUpdate.SignedAccumulator, update.SignedAccumulator}, response, session)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	assert.Equal(t, "did:kilt:attester", id)
+	_, _, _, err = VerifyTrustedPresentation(registry,
+		[]*revocation.SignedAccumulator{otherUpdate.SignedAccumulator}, response, session)
+	assert.True(t, errors.Is(err, ErrKeyUnknown))
+
+	// the attester is not trusted for other ctypes
+	registry.Attesters[0].CTypes = []string{"0xC0FFEE"}
+	verified, claim, id, err = VerifyTrustedPresentation(registry, accs, response, session)
+	assert.True(t, errors.Is(err, ErrUntrusted))
+	assert.False(t, verified)
+	assert.Nil(t, claim)
+	assert.Empty(t, id)
+
+	response = &PresentationResponse{}
+	require.NoError(t, json.Unmarshal(bytePresentationResponse, response))
+	verifierSession := &VerifierSession{}
+	require.NoError(t, json.Unmarshal(byteVerifierSession, verifierSession))
+	fixtureUpdate := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, fixtureUpdate))
+	accs = []*revocation.SignedAccumulator{fixtureUpdate.SignedAccumulator}
+
+	_, _, _, err = VerifyTrustedPresentation(registry, accs, response, verifierSession)
+	assert.Error(t, err, "presentation without key id")
+
+	response.KeyID, err = PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, registry.Revoke("did:kilt:attester"))
+	_, _, _, err = VerifyTrustedPresentation(registry, accs, response, verifierSession)
+	assert.Error(t, err, "revoked attester")
+}
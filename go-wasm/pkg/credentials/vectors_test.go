@@ -0,0 +1,358 @@
+package credentials
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The test vectors are regenerated by running the tests with -update-vectors.
+// Any change of the generated files changes the format and requires a new
+// version of the vectors.
+var updateVectors = flag.Bool("update-vectors", false, "regenerate the test vectors inside testdata/vectors")
+
+const (
+	vectorsVersion   = "v1"
+	vectorsKeyLength = 1024
+	vectorsSeed      = "portablegabi/test-vectors/v1"
+)
+
+type (
+	// vectorAttribute is an Attribute whose value is hex encoded.
+	vectorAttribute struct {
+		Name     string `json:"name"`
+		Typename string `json:"typename"`
+		Value    string `json:"value"`
+	}
+
+	// encodingVector describes the transformation of a claim into attributes
+	// and big ints, which are signed by the attester.
+	encodingVector struct {
+		Description string            `json:"description"`
+		Encoding    ClaimEncoding     `json:"encoding"`
+		Claim       Claim             `json:"claim"`
+		Attributes  []vectorAttribute `json:"attributes"`
+		Preimages   []vectorAttribute `json:"preimages,omitempty"`
+		BigInts     []string          `json:"bigInts"`
+	}
+
+	encodingVectors struct {
+		Version          string           `json:"version"`
+		KeyLength        int              `json:"keyLength"`
+		MaxAttributeSize int              `json:"maxAttributeSize"`
+		MagicByte        string           `json:"magicByte"`
+		Separator        string           `json:"separator"`
+		Vectors          []encodingVector `json:"vectors"`
+	}
+
+	// protocolVectors contains the values of the protocol which are derived
+	// from the fixed randomness (see vectorRand).
+	protocolVectors struct {
+		Version           string          `json:"version"`
+		KeyLength         int             `json:"keyLength"`
+		Seed              string          `json:"seed"`
+		AttesterPublicKey json.RawMessage `json:"attesterPublicKey"`
+		KeyID             string          `json:"keyId"`
+		CTypeHash         string          `json:"cTypeHash"`
+		Attestation       struct {
+			SessionID string `json:"sessionId"`
+			Context   string `json:"context"`
+			Nonce     string `json:"nonce"`
+		} `json:"attestation"`
+		ClaimerSecret string `json:"claimerSecret"`
+		Presentation  struct {
+			Context string `json:"context"`
+			Nonce   string `json:"nonce"`
+		} `json:"presentation"`
+	}
+
+	// transcriptVectors contains recorded messages of an attestation and a
+	// presentation. The proofs inside the messages are randomized by gabi,
+	// they can be verified but not reproduced.
+	transcriptVectors struct {
+		Version           string          `json:"version"`
+		AttesterPublicKey json.RawMessage `json:"attesterPublicKey"`
+		Accumulator       json.RawMessage `json:"accumulator"`
+		Issuance          struct {
+			AttesterSession json.RawMessage `json:"attesterSession"`
+			Request         json.RawMessage `json:"request"`
+			ClaimerSession  json.RawMessage `json:"claimerSession"`
+			Signature       json.RawMessage `json:"signature"`
+		} `json:"issuance"`
+		Presentation struct {
+			Credential      json.RawMessage `json:"credential"`
+			Request         json.RawMessage `json:"request"`
+			VerifierSession json.RawMessage `json:"verifierSession"`
+			Response        json.RawMessage `json:"response"`
+			Claim           json.RawMessage `json:"claim"`
+		} `json:"presentation"`
+	}
+
+	// vectorRand is the fixed randomness of the test vectors. The stream of
+	// a label consists of the blocks SHA-256(seed | label | counter), where
+	// counter is a big-endian uint64 starting at 0. An integer of n bits is
+	// read as the first n/8 bytes of the stream.
+	vectorRand struct {
+		prefix  []byte
+		counter uint64
+		buf     []byte
+	}
+)
+
+// encodingClaims are the claims of the encoding vectors.
+var encodingClaims = []struct {
+	description string
+	encoding    ClaimEncoding
+	claim       string
+}{
+	{"simple values", DefaultEncoding,
+		`{"ctype":"0xDEADBEEFCOFEE","contents":{"name":"Alice","age":34,"special":true,"weight":61.5,"nothing":null}}`},
+	{"nested objects", DefaultEncoding,
+		`{"contents":{"address":{"city":"Berlin","zip":"10115"}}}`},
+	{"arrays", DefaultEncoding,
+		`{"contents":{"likedNumbers":[1,2,3],"friends":[{"name":"Bob"}],"empty":[]}}`},
+	{"arrays using the element encoding", ElementEncoding,
+		`{"contents":{"likedNumbers":[1,2,3],"friends":[{"name":"Bob"}],"empty":[]}}`},
+	{"escaped keys", DefaultEncoding,
+		`{"contents":{"dotted.key":"a","back\\slash":"b","both\\.":"c"}}`},
+	{"unicode", DefaultEncoding,
+		`{"contents":{"name":"Zoë 🦀"}}`},
+	{"numbers", DefaultEncoding,
+		`{"contents":{"negative":-1.5,"zero":0,"maxSafeInteger":9007199254740991,"small":1e-7}}`},
+	{"hashed values", DefaultEncoding,
+		`{"contents":{"bio":"This value is longer than the maximum size of an attribute value.","short":"x"}}`},
+}
+
+func newVectorRand(label string) *vectorRand {
+	return &vectorRand{prefix: append([]byte(vectorsSeed), label...)}
+}
+
+func (r *vectorRand) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) {
+		block := make([]byte, len(r.prefix)+8)
+		copy(block, r.prefix)
+		binary.BigEndian.PutUint64(block[len(r.prefix):], r.counter)
+		r.counter++
+		hash := sha256.Sum256(block)
+		r.buf = append(r.buf, hash[:]...)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func toVectorAttributes(attributes []*Attribute) []vectorAttribute {
+	if len(attributes) == 0 {
+		return nil
+	}
+	vectors := make([]vectorAttribute, len(attributes))
+	for i, attr := range attributes {
+		vectors[i] = vectorAttribute{
+			Name:     attr.Name,
+			Typename: attr.Typename,
+			Value:    hex.EncodeToString(attr.Value),
+		}
+	}
+	return vectors
+}
+
+func hexToBigInts(t *testing.T, values []string) []*big.Int {
+	ints := make([]*big.Int, len(values))
+	for i, value := range values {
+		b, err := hex.DecodeString(value)
+		require.NoError(t, err)
+		ints[i] = new(big.Int).SetBytes(b)
+	}
+	return ints
+}
+
+// checkVectors compares the generated vectors with the file or writes them
+// if -update-vectors is set.
+func checkVectors(t *testing.T, name string, vectors interface{}) {
+	generated, err := json.MarshalIndent(vectors, "", "  ")
+	require.NoError(t, err)
+	generated = append(generated, '\n')
+	path := filepath.Join("testdata", "vectors", vectorsVersion, name)
+	if *updateVectors {
+		require.NoError(t, ioutil.WriteFile(path, generated, 0644))
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(generated), "%s changed, the format must not drift", path)
+}
+
+func generateEncodingVectors(t *testing.T) *encodingVectors {
+	sysParams, ok := gabi.DefaultSystemParameters[vectorsKeyLength]
+	require.True(t, ok)
+	vectors := &encodingVectors{
+		Version:          vectorsVersion,
+		KeyLength:        vectorsKeyLength,
+		MaxAttributeSize: MaxAttributeSize(sysParams),
+		MagicByte:        hex.EncodeToString([]byte{MagicByte}),
+		Separator:        Separator,
+	}
+	for _, c := range encodingClaims {
+		claim := Claim{}
+		require.NoError(t, json.Unmarshal([]byte(c.claim), &claim), c.description)
+		attributes, preimages, err := claim.ToAttributesWithParams(sysParams, c.encoding)
+		require.NoError(t, err, c.description)
+		bigInts, err := attributesToBigInts(attributes)
+		require.NoError(t, err, c.description)
+		hexInts := make([]string, len(bigInts))
+		for i, b := range bigInts {
+			hexInts[i] = hex.EncodeToString(b.Bytes())
+		}
+		vectors.Vectors = append(vectors.Vectors, encodingVector{
+			Description: c.description,
+			Encoding:    c.encoding,
+			Claim:       claim,
+			Attributes:  toVectorAttributes(attributes),
+			Preimages:   toVectorAttributes(preimages),
+			BigInts:     hexInts,
+		})
+	}
+	return vectors
+}
+
+func TestEncodingVectors(t *testing.T) {
+	vectors := generateEncodingVectors(t)
+	checkVectors(t, "encoding.json", vectors)
+
+	// the big ints decode to the claim
+	for _, vector := range vectors.Vectors {
+		attributes, err := BigIntsToAttributes(hexToBigInts(t, vector.BigInts))
+		require.NoError(t, err, vector.Description)
+		preimages := make([]*Attribute, len(vector.Preimages))
+		for i, p := range vector.Preimages {
+			value, err := hex.DecodeString(p.Value)
+			require.NoError(t, err)
+			preimages[i] = &Attribute{Name: p.Name, Typename: p.Typename, Value: value}
+		}
+		attributes, err = applyPreimages(attributes, preimages)
+		require.NoError(t, err, vector.Description)
+		claim, err := decodeClaim(attributes, true)
+		require.NoError(t, err, vector.Description)
+		equal, err := claim.Equal(vector.Claim)
+		require.NoError(t, err)
+		assert.True(t, equal, vector.Description)
+	}
+}
+
+func generateProtocolVectors(t *testing.T) *protocolVectors {
+	sysParams, ok := gabi.DefaultSystemParameters[vectorsKeyLength]
+	require.True(t, ok)
+	keys := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(byteAttester, &keys))
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+
+	vectors := &protocolVectors{
+		Version:           vectorsVersion,
+		KeyLength:         vectorsKeyLength,
+		Seed:              vectorsSeed,
+		AttesterPublicKey: keys["PublicKey"],
+		CTypeHash:         "0xDEADBEEFCOFEE",
+	}
+	keyID, err := PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+	vectors.KeyID = hex.EncodeToString(keyID.Bytes())
+
+	attester.Rand = newVectorRand("attestation")
+	session, _, err := attester.InitiateCTypeAttestation(vectors.CTypeHash)
+	require.NoError(t, err)
+	vectors.Attestation.SessionID = hex.EncodeToString(session.SessionID.Bytes())
+	vectors.Attestation.Context = hex.EncodeToString(session.Context.Bytes())
+	vectors.Attestation.Nonce = hex.EncodeToString(session.Nonce.Bytes())
+
+	claimer, err := NewClaimerWithRand(sysParams, newVectorRand("claimer"))
+	require.NoError(t, err)
+	vectors.ClaimerSecret = hex.EncodeToString(claimer.MasterSecret.Bytes())
+
+	verifierSession, _, err := RequestPresentationWithRand(newVectorRand("presentation"), sysParams,
+		[]string{"contents"}, false, future, false)
+	require.NoError(t, err)
+	vectors.Presentation.Context = hex.EncodeToString(verifierSession.Context.Bytes())
+	vectors.Presentation.Nonce = hex.EncodeToString(verifierSession.Nonce.Bytes())
+	return vectors
+}
+
+func TestProtocolVectors(t *testing.T) {
+	checkVectors(t, "protocol.json", generateProtocolVectors(t))
+}
+
+// generateTranscriptVectors copies the recorded messages of the test fixtures.
+// gabi reads the randomness of its proofs and signatures from crypto/rand, so
+// the transcript can not be derived from fixed randomness like the protocol
+// vectors.
+func generateTranscriptVectors(t *testing.T) *transcriptVectors {
+	keys := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(byteAttester, &keys))
+	vectors := &transcriptVectors{
+		Version:           vectorsVersion,
+		AttesterPublicKey: keys["PublicKey"],
+		Accumulator:       byteUpdate,
+	}
+	vectors.Issuance.AttesterSession = byteAttesterSession
+	vectors.Issuance.Request = byteAttestationRequest
+	vectors.Issuance.ClaimerSession = byteAttestClaimerSession
+	vectors.Issuance.Signature = byteAttestationResponse
+	vectors.Presentation.Credential = byteCredential
+	vectors.Presentation.Request = bytePresentationRequest
+	vectors.Presentation.VerifierSession = byteVerifierSession
+	vectors.Presentation.Response = bytePresentationResponse
+	vectors.Presentation.Claim = bytePresentation
+	return vectors
+}
+
+func TestTranscriptVectors(t *testing.T) {
+	vectors := generateTranscriptVectors(t)
+	checkVectors(t, "transcript.json", vectors)
+
+	pubK := &gabi.PublicKey{}
+	require.NoError(t, json.Unmarshal(vectors.AttesterPublicKey, pubK))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(vectors.Accumulator, update))
+
+	// issuance
+	attesterSession := &AttesterSession{}
+	require.NoError(t, json.Unmarshal(vectors.Issuance.AttesterSession, attesterSession))
+	request := &AttestedClaimRequest{}
+	require.NoError(t, json.Unmarshal(vectors.Issuance.Request, request))
+	assert.True(t, request.CommitMsg.Proofs.Verify([]*gabi.PublicKey{pubK},
+		attesterSession.Context, attesterSession.Nonce, false, nil))
+	claimerSession := &UserIssuanceSession{}
+	require.NoError(t, json.Unmarshal(vectors.Issuance.ClaimerSession, claimerSession))
+	signature := &gabi.IssueSignatureMessage{}
+	require.NoError(t, json.Unmarshal(vectors.Issuance.Signature, signature))
+	_, err := (&Claimer{}).BuildCredential(signature, claimerSession)
+	assert.NoError(t, err)
+
+	// presentation
+	cred := &AttestedClaim{}
+	require.NoError(t, json.Unmarshal(vectors.Presentation.Credential, cred))
+	assert.NoError(t, cred.Validate(pubK, nil))
+	verifierSession := &VerifierSession{}
+	require.NoError(t, json.Unmarshal(vectors.Presentation.VerifierSession, verifierSession))
+	response := &PresentationResponse{}
+	require.NoError(t, json.Unmarshal(vectors.Presentation.Response, response))
+	verified, claim, err := VerifyPresentation(pubK, update.SignedAccumulator, response, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	expected := Claim{}
+	require.NoError(t, json.Unmarshal(vectors.Presentation.Claim, &expected))
+	equal, err := claim.Equal(expected)
+	require.NoError(t, err)
+	assert.True(t, equal)
+}
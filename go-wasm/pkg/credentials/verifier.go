@@ -2,44 +2,82 @@ package credentials
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"sort"
 	"time"
 
 	"github.com/privacybydesign/gabi"
 	"github.com/privacybydesign/gabi/big"
-	"github.com/privacybydesign/gabi/pkg/common"
 	"github.com/privacybydesign/gabi/revocation"
 )
 
 type (
-	// VerifierSession stores information which is needed to verify the response of the claimer
+	// VerifierSession stores information which is needed to verify the response of the claimer.
+	// If Strict is set, disclosed attributes of unknown types or with
+	// malformed values are rejected instead of being decoded as hex strings.
 	VerifierSession struct {
 		Context               *big.Int  `json:"context"`
 		Nonce                 *big.Int  `json:"nonce"`
 		ReqNonRevocationProof bool      `json:"reqNonRevocationProof"`
 		ReqUpdatedAfter       time.Time `json:"reqUpdatedAfter"`
+		Strict                bool      `json:"strict,omitempty"`
+	}
+
+	// KeyLookup resolves the public keys and the latest accumulators of
+	// attesters. It is used to verify combined presentations, which contain
+	// credentials of different attesters.
+	KeyLookup interface {
+		// PublicKey returns the public key with the given identifier (see
+		// PublicKeyID).
+		PublicKey(keyID *big.Int) (*gabi.PublicKey, error)
+		// Accumulator returns the latest accumulator of the public key.
+		Accumulator(pubK *gabi.PublicKey) (*revocation.SignedAccumulator, error)
+	}
+
+	// KeyStore is a KeyLookup, which contains a list of public keys and a
+	// list of accumulators. The order of both lists is irrelevant.
+	KeyStore struct {
+		Keys         []*gabi.PublicKey               `json:"keys"`
+		Accumulators []*revocation.SignedAccumulator `json:"accumulators"`
 	}
 
 	// CombinedVerifierSession stores the information for a combined presentation session.
+	// Strict has the same meaning as for the VerifierSession.
 	CombinedVerifierSession struct {
 		Context         *big.Int                     `json:"context"`
 		Nonce           *big.Int                     `json:"nonce"`
 		PartialRequests []PartialPresentationRequest `json:"partialRequests"`
+		Strict          bool                         `json:"strict,omitempty"`
 	}
 )
 
 // RequestPresentation builds a message which request the specified attributes from a claimer.
 // It returns a VerifierSession which is used to check the claimers response and RequestDiscloseAttributes
-// which represents the message which should be sent to the claimer
+// which represents the message which should be sent to the claimer. If strict is set, the
+// disclosed attributes are decoded strictly (see VerifierSession).
 func RequestPresentation(sysParams *gabi.SystemParameters, discloseAttributes []string,
-	requestNonRevProof bool, updateAfter time.Time) (*VerifierSession, *PresentationRequest) {
-	context, _ := common.RandomBigInt(sysParams.Lh)
-	nonce, _ := common.RandomBigInt(sysParams.Lh)
+	requestNonRevProof bool, updateAfter time.Time, strict bool) (*VerifierSession, *PresentationRequest, error) {
+	return RequestPresentationWithRand(nil, sysParams, discloseAttributes, requestNonRevProof, updateAfter, strict)
+}
+
+// RequestPresentationWithRand builds a message like RequestPresentation. The
+// context and the nonce are read from rnd, if it is nil crypto/rand is used.
+// rnd only determines the request, the proof of the claimer is randomized by
+// gabi using crypto/rand.
+func RequestPresentationWithRand(rnd io.Reader, sysParams *gabi.SystemParameters, discloseAttributes []string,
+	requestNonRevProof bool, updateAfter time.Time, strict bool) (*VerifierSession, *PresentationRequest, error) {
+	context, nonce, err := randomContextAndNonce(rnd, sysParams)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	return &VerifierSession{
 			Context:               context,
 			Nonce:                 nonce,
 			ReqNonRevocationProof: requestNonRevProof,
 			ReqUpdatedAfter:       updateAfter,
+			Strict:                strict,
 		}, &PresentationRequest{
 			Context: context,
 			PartialPresentationRequest: &PartialPresentationRequest{
@@ -48,47 +86,118 @@ func RequestPresentation(sysParams *gabi.SystemParameters, discloseAttributes []
 				ReqUpdatedAfter:       updateAfter,
 			},
 			Nonce: nonce,
-		}
+		}, nil
 }
 
 // RequestCombinedPresentation request the disclosure of multiple different credentials from a user.
+// If strict is set, the disclosed attributes are decoded strictly (see VerifierSession).
 func RequestCombinedPresentation(sysParams *gabi.SystemParameters,
-	partialRequests []PartialPresentationRequest) (*CombinedVerifierSession, *CombinedPresentationRequest) {
-	context, _ := common.RandomBigInt(sysParams.Lh)
-	nonce, _ := common.RandomBigInt(sysParams.Lh)
+	partialRequests []PartialPresentationRequest, strict bool) (*CombinedVerifierSession, *CombinedPresentationRequest, error) {
+	return RequestCombinedPresentationWithRand(nil, sysParams, partialRequests, strict)
+}
+
+// RequestCombinedPresentationWithRand requests the disclosure of multiple
+// credentials like RequestCombinedPresentation. The context and the nonce are
+// read from rnd, if it is nil crypto/rand is used. Like for
+// RequestPresentationWithRand, rnd only determines the request.
+func RequestCombinedPresentationWithRand(rnd io.Reader, sysParams *gabi.SystemParameters,
+	partialRequests []PartialPresentationRequest, strict bool) (*CombinedVerifierSession, *CombinedPresentationRequest, error) {
+	context, nonce, err := randomContextAndNonce(rnd, sysParams)
+	if err != nil {
+		return nil, nil, err
+	}
 	return &CombinedVerifierSession{
 			Context:         context,
 			Nonce:           nonce,
 			PartialRequests: partialRequests,
+			Strict:          strict,
 		}, &CombinedPresentationRequest{
 			Context:         context,
 			PartialRequests: partialRequests,
 			Nonce:           nonce,
-		}
+		}, nil
 }
 
-func verifyAccumulatorInProof(issuerPubK *gabi.PublicKey, latestSignAcc *revocation.SignedAccumulator,
-	reqUpdatedAfter time.Time,
-	proof *gabi.ProofD) (bool, error) {
-	if proof.HasNonRevocationProof() {
-		revPubKey, err := issuerPubK.RevocationKey()
-		if err != nil {
-			return false, nil
-		}
-		acc, err := proof.NonRevocationProof.SignedAccumulator.UnmarshalVerify(revPubKey)
-		if err != nil {
-			return false, nil
-		}
-		if acc.Time.Before(reqUpdatedAfter) {
-			latestAcc, err := latestSignAcc.UnmarshalVerify(revPubKey)
-			if err != nil {
-				return false, err
-			}
-			return latestAcc.Index == acc.Index, nil
-		}
-		return true, nil
+// randomContextAndNonce creates the random context and nonce of a
+// presentation session.
+func randomContextAndNonce(rnd io.Reader, sysParams *gabi.SystemParameters) (*big.Int, *big.Int, error) {
+	context, err := randomBigInt(rnd, sysParams.Lh)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := randomBigInt(rnd, sysParams.Lh)
+	if err != nil {
+		return nil, nil, err
 	}
-	return false, nil
+	return context, nonce, nil
+}
+
+// checkNonRevocation adds the checks of the non revocation proof to the
+// result. If the accumulator of the proof is older than reqUpdatedAfter, it
+// must be the latest accumulator.
+func checkNonRevocation(result *VerificationResult, issuerPubK *gabi.PublicKey,
+	latestSignAcc *revocation.SignedAccumulator, reqUpdatedAfter time.Time, proof *gabi.ProofD) {
+	if !proof.HasNonRevocationProof() {
+		result.Checks = addCheck(result.Checks, CheckNonRevocationProof,
+			newError(ErrProofInvalid, "missing non revocation proof"))
+		return
+	}
+	result.Checks = addCheck(result.Checks, CheckNonRevocationProof, nil)
+
+	revPubKey, err := issuerPubK.RevocationKey()
+	if err != nil {
+		result.Checks = addCheck(result.Checks, CheckAccumulatorSignature, err)
+		return
+	}
+	acc, err := proof.NonRevocationProof.SignedAccumulator.UnmarshalVerify(revPubKey)
+	if err != nil {
+		result.Checks = addCheck(result.Checks, CheckAccumulatorSignature,
+			newError(ErrProofInvalid, "invalid accumulator in non revocation proof: %v", err))
+		return
+	}
+	result.Checks = addCheck(result.Checks, CheckAccumulatorSignature, nil)
+	result.Accumulator = &AccumulatorInfo{Index: acc.Index, Time: acc.Time}
+
+	if !acc.Time.Before(reqUpdatedAfter) {
+		result.Checks = addCheck(result.Checks, CheckAccumulatorFreshness, nil)
+		return
+	}
+	if latestSignAcc == nil {
+		result.Checks = addCheck(result.Checks, CheckAccumulatorFreshness,
+			newError(ErrAccumulatorStale, "missing latest accumulator"))
+		return
+	}
+	latestAcc, err := latestSignAcc.UnmarshalVerify(revPubKey)
+	if err == nil && latestAcc.Index != acc.Index {
+		err = newError(ErrAccumulatorStale, "accumulator of the non revocation proof is not the latest accumulator")
+	}
+	result.Checks = addCheck(result.Checks, CheckAccumulatorFreshness, err)
+}
+
+// checkDisclosure performs the checks of a single credential, which are not
+// part of the zero knowledge proof, and returns them together with the
+// disclosed claim.
+func checkDisclosure(checks []*Check, issuerPubK *gabi.PublicKey, latestAcc *revocation.SignedAccumulator,
+	proof *gabi.ProofD, preimages []*Attribute, partialReq *PartialPresentationRequest, strict bool) (*VerificationResult, error) {
+	keyID, err := PublicKeyID(issuerPubK)
+	if err != nil {
+		return nil, err
+	}
+	result := &VerificationResult{
+		Checks:     checks,
+		KeyID:      keyID,
+		KeyCounter: issuerPubK.Counter,
+	}
+	if partialReq.ReqNonRevocationProof {
+		checkNonRevocation(result, issuerPubK, latestAcc, partialReq.ReqUpdatedAfter, proof)
+	}
+	claim, hashed, err := disclosedClaim(proof, preimages, strict)
+	result.Checks = addCheck(result.Checks, CheckDisclosure, err)
+	if err == nil && result.Verified() {
+		result.Claim = claim
+		result.HashedAttributes = hashed
+	}
+	return result, nil
 }
 
 func getValues(m map[int]*big.Int) []*big.Int {
@@ -102,58 +211,171 @@ func getValues(m map[int]*big.Int) []*big.Int {
 }
 
 // VerifyPresentation verifies the response of a claimer and returns the disclosed attributes.
+// If the proof is invalid, the error wraps ErrProofInvalid. If the non revocation
+// proof does not use the latest accumulator, the error wraps ErrAccumulatorStale.
+// Use VerifyPresentationWithResult to get the outcome of every check.
 func VerifyPresentation(issuerPubK *gabi.PublicKey, latestAcc *revocation.SignedAccumulator,
 	signedAttributes *PresentationResponse, session *VerifierSession) (bool, Claim, error) {
+	result, err := VerifyPresentationWithResult(issuerPubK, latestAcc, signedAttributes, session)
+	if err != nil {
+		return false, nil, err
+	}
+	if err := result.Err(); err != nil {
+		return false, nil, err
+	}
+	return true, result.Claim, nil
+}
+
+// VerifyPresentationWithResult verifies the response of a claimer like
+// VerifyPresentation and returns the outcome of every check. A failed check
+// is not returned as error, it is reported by the result (see
+// VerificationResult.Err).
+func VerifyPresentationWithResult(issuerPubK *gabi.PublicKey, latestAcc *revocation.SignedAccumulator,
+	signedAttributes *PresentationResponse, session *VerifierSession) (*VerificationResult, error) {
+	var proofErr error
 	if !signedAttributes.Proof.Verify(issuerPubK, session.Context, session.Nonce, false) {
-		return false, nil, nil
+		proofErr = newError(ErrProofInvalid, "presentation could not be verified")
 	}
-	if session.ReqNonRevocationProof {
-		verified, err := verifyAccumulatorInProof(issuerPubK, latestAcc, session.ReqUpdatedAfter, &signedAttributes.Proof)
-		if err != nil || !verified {
-			return false, nil, err
-		}
+	return checkDisclosure(addCheck(nil, CheckProof, proofErr), issuerPubK, latestAcc,
+		&signedAttributes.Proof, signedAttributes.Preimages, &PartialPresentationRequest{
+			ReqNonRevocationProof: session.ReqNonRevocationProof,
+			ReqUpdatedAfter:       session.ReqUpdatedAfter,
+		}, session.Strict)
+}
+
+// disclosedClaim returns the claim containing the disclosed attributes of the
+// proof. Hashed attributes are replaced by their preimages, which must match
+// the disclosed hashes. The names of the hashed attributes without a preimage
+// are returned sorted, the claim contains their hex encoded hash. In strict
+// mode malformed attributes are rejected (see decodeClaim).
+func disclosedClaim(proof *gabi.ProofD, preimages []*Attribute, strict bool) (Claim, []string, error) {
+	attributes, err := BigIntsToAttributes(getValues(proof.ADisclosed))
+	if err != nil {
+		return nil, nil, err
 	}
-	attributes, err := BigIntsToAttributes(getValues(signedAttributes.Proof.ADisclosed))
+	attributes, err = applyPreimages(attributes, preimages)
 	if err != nil {
-		return false, nil, err
+		return nil, nil, err
+	}
+	var hashed []string
+	for _, attr := range attributes {
+		if attr.Typename == hashType {
+			hashed = append(hashed, attr.Name)
+		}
 	}
-	claim, err := newClaimFromAttribute(attributes)
+	sort.Strings(hashed)
+	claim, err := decodeClaim(attributes, strict)
 	if err != nil {
-		return false, nil, err
+		return nil, nil, err
 	}
-	return true, claim, nil
+	return claim, hashed, nil
 }
 
 // VerifyCombinedPresentation verifies the response of a claimer and returns the presentations provided by the user.
+// Failed verifications are reported like by VerifyPresentation.
 func VerifyCombinedPresentation(attesterPubKeys []*gabi.PublicKey,
 	latestAccs []*revocation.SignedAccumulator, combinedPresentation *CombinedPresentationResponse,
 	session *CombinedVerifierSession) (bool, []Claim, error) {
+	result, err := VerifyCombinedPresentationWithResult(attesterPubKeys, latestAccs, combinedPresentation, session)
+	if err != nil {
+		return false, nil, err
+	}
+	if err := result.Err(); err != nil {
+		return false, nil, err
+	}
+	return true, result.Claims(), nil
+}
+
+// VerifyCombinedPresentationWithResult verifies the response of a claimer
+// like VerifyCombinedPresentation and returns the outcome of every check.
+// Failed checks are reported by the result.
+func VerifyCombinedPresentationWithResult(attesterPubKeys []*gabi.PublicKey,
+	latestAccs []*revocation.SignedAccumulator, combinedPresentation *CombinedPresentationResponse,
+	session *CombinedVerifierSession) (*CombinedVerificationResult, error) {
+	if len(combinedPresentation.Proof) != len(session.PartialRequests) {
+		return nil, fmt.Errorf("expected %d proofs, got %d", len(session.PartialRequests), len(combinedPresentation.Proof))
+	}
+	if len(attesterPubKeys) != len(session.PartialRequests) {
+		return nil, fmt.Errorf("expected %d public keys, got %d", len(session.PartialRequests), len(attesterPubKeys))
+	}
+	if len(latestAccs) != len(session.PartialRequests) {
+		return nil, fmt.Errorf("expected %d accumulators, got %d", len(session.PartialRequests), len(latestAccs))
+	}
+	if len(combinedPresentation.Preimages) > len(combinedPresentation.Proof) {
+		return nil, fmt.Errorf("expected preimages for at most %d proofs, got %d",
+			len(combinedPresentation.Proof), len(combinedPresentation.Preimages))
+	}
+	var proofErr error
 	if !combinedPresentation.Proof.Verify(attesterPubKeys, session.Context, session.Nonce, false, nil) {
-		return false, nil, nil
+		proofErr = newError(ErrProofInvalid, "combined presentation could not be verified")
+	}
+	result := &CombinedVerificationResult{
+		Checks:  addCheck(nil, CheckProof, proofErr),
+		Results: make([]*VerificationResult, len(combinedPresentation.Proof)),
 	}
-	claims := make([]Claim, len(combinedPresentation.Proof))
 	for i, genericP := range combinedPresentation.Proof {
 		// check each proof: revocation has to be ok and accumulator fresh enough
-		if proofD, ok := genericP.(*gabi.ProofD); ok {
-			partialReq := session.PartialRequests[i]
-			attributes, err := BigIntsToAttributes(getValues(proofD.ADisclosed))
-			if err != nil {
-				return false, nil, err
-			}
-			claims[i], err = newClaimFromAttribute(attributes)
-			if err != nil {
-				return false, nil, err
-			}
-			if partialReq.ReqNonRevocationProof {
-				verified, err := verifyAccumulatorInProof(attesterPubKeys[i], latestAccs[i],
-					partialReq.ReqUpdatedAfter, proofD)
-				if err != nil || !verified {
-					return false, nil, err
-				}
+		proofD, ok := genericP.(*gabi.ProofD)
+		if !ok {
+			return nil, errors.New("unsupported proof in prooflist")
+		}
+		var preimages []*Attribute
+		if i < len(combinedPresentation.Preimages) {
+			preimages = combinedPresentation.Preimages[i]
+		}
+		// the combined proof covers every credential, its outcome is
+		// therefore part of every result
+		var err error
+		result.Results[i], err = checkDisclosure(addCheck(nil, CheckProof, proofErr), attesterPubKeys[i],
+			latestAccs[i], proofD, preimages, &session.PartialRequests[i], session.Strict)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !result.Verified() {
+		for _, r := range result.Results {
+			r.Claim = nil
+		}
+	}
+	return result, nil
+}
+
+// PublicKey returns the public key with the given identifier. Expired keys are
+// not returned.
+func (store *KeyStore) PublicKey(keyID *big.Int) (*gabi.PublicKey, error) {
+	keyring := &PublicKeyring{Keys: store.Keys}
+	return keyring.Lookup(keyID)
+}
+
+// Accumulator returns the latest accumulator, i.e. the one with the highest
+// index, which was signed using the revocation key of the public key. The
+// accumulators of the store are not modified.
+func (store *KeyStore) Accumulator(pubK *gabi.PublicKey) (*revocation.SignedAccumulator, error) {
+	return latestAccumulator(store.Accumulators, pubK)
+}
+
+// VerifyCombinedPresentationWithLookup verifies the response of a claimer
+// using VerifyCombinedPresentation. The public keys and accumulators are
+// resolved using the key identifiers of the response. Accumulators are only
+// resolved for partial requests which require a non revocation proof.
+func VerifyCombinedPresentationWithLookup(lookup KeyLookup, combinedPresentation *CombinedPresentationResponse,
+	session *CombinedVerifierSession) (bool, []Claim, error) {
+	if len(combinedPresentation.KeyIDs) != len(session.PartialRequests) {
+		return false, nil, fmt.Errorf("expected %d key ids, got %d", len(session.PartialRequests), len(combinedPresentation.KeyIDs))
+	}
+	attesterPubKeys := make([]*gabi.PublicKey, len(session.PartialRequests))
+	latestAccs := make([]*revocation.SignedAccumulator, len(session.PartialRequests))
+	for i, keyID := range combinedPresentation.KeyIDs {
+		pubK, err := lookup.PublicKey(keyID)
+		if err != nil {
+			return false, nil, fmt.Errorf("key of the %d. proof: %w", i+1, err)
+		}
+		attesterPubKeys[i] = pubK
+		if session.PartialRequests[i].ReqNonRevocationProof {
+			if latestAccs[i], err = lookup.Accumulator(pubK); err != nil {
+				return false, nil, fmt.Errorf("accumulator of the %d. proof: %w", i+1, err)
 			}
-		} else {
-			return false, nil, errors.New("unsupported proof in prooflist")
 		}
 	}
-	return true, claims, nil
+	return VerifyCombinedPresentation(attesterPubKeys, latestAccs, combinedPresentation, session)
 }
@@ -1,10 +1,14 @@
 package credentials
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
 	"github.com/privacybydesign/gabi/revocation"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -14,16 +18,21 @@ func TestRequestPresentation(t *testing.T) {
 	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
 	assert.True(t, success, "Error in sysparams")
 	disclosedAttr := []string{"contents.special", "ctype"}
-	session1, msg1 := RequestPresentation(sysParams, disclosedAttr, true, future)
+	session1, msg1, err := RequestPresentation(sysParams, disclosedAttr, true, future, false)
+	require.NoError(t, err)
 	require.NotNil(t, msg1)
 	require.NotNil(t, session1)
 	require.Equal(t, msg1.Nonce, session1.Nonce)
 	require.Equal(t, msg1.Context, session1.Context)
 	require.Equal(t, msg1.PartialPresentationRequest.RequestedAttributes, disclosedAttr)
 
+	assert.False(t, session1.Strict)
+
 	// nonce should be random
-	_, msg2 := RequestPresentation(sysParams, disclosedAttr, true, future)
+	session2, msg2, err := RequestPresentation(sysParams, disclosedAttr, true, future, true)
+	require.NoError(t, err)
 	require.NotEqual(t, msg1.Nonce, msg2.Nonce)
+	assert.True(t, session2.Strict)
 }
 
 func TestRequestCombinedPresentation(t *testing.T) {
@@ -41,15 +50,20 @@ func TestRequestCombinedPresentation(t *testing.T) {
 			ReqUpdatedAfter:       future,
 		},
 	}
-	session1, msg1 := RequestCombinedPresentation(sysParams, disclosedAttrs)
+	session1, msg1, err := RequestCombinedPresentation(sysParams, disclosedAttrs, false)
+	require.NoError(t, err)
 	require.NotNil(t, msg1)
 	require.NotNil(t, session1)
 	require.Equal(t, msg1.Nonce, session1.Nonce)
 	require.Equal(t, msg1.Context, session1.Context)
 
+	assert.False(t, session1.Strict)
+
 	// nonce should be random
-	_, msg2 := RequestCombinedPresentation(sysParams, disclosedAttrs)
+	session2, msg2, err := RequestCombinedPresentation(sysParams, disclosedAttrs, true)
+	require.NoError(t, err)
 	require.NotEqual(t, msg1.Nonce, msg2.Nonce)
+	assert.True(t, session2.Strict)
 }
 
 func TestVerifyPresentation(t *testing.T) {
@@ -112,6 +126,169 @@ func TestVerifyCombinedPresentation(t *testing.T) {
 	require.NotNil(t, claims)
 }
 
+func TestVerifyPresentationWithResult(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	update := &revocation.Update{}
+	err = json.Unmarshal(byteUpdate, update)
+	require.NoError(t, err)
+
+	presentationResponse := &PresentationResponse{}
+	err = json.Unmarshal(bytePresentationResponse, presentationResponse)
+	require.NoError(t, err)
+
+	verifierSession := &VerifierSession{}
+	err = json.Unmarshal(byteVerifierSession, verifierSession)
+	require.NoError(t, err)
+
+	result, err := VerifyPresentationWithResult(attester.PublicKey, update.SignedAccumulator,
+		presentationResponse, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, result.Verified())
+	assert.NoError(t, result.Err())
+	assert.Equal(t, attester.PublicKey.Counter, result.KeyCounter)
+	keyID, err := PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, keyID, result.KeyID)
+	require.NotNil(t, result.Claim)
+	names := make([]string, len(result.Checks))
+	for i, check := range result.Checks {
+		names[i] = check.Name
+	}
+	if verifierSession.ReqNonRevocationProof {
+		require.NotNil(t, result.Accumulator)
+		assert.Equal(t, []string{CheckProof, CheckNonRevocationProof, CheckAccumulatorSignature,
+			CheckAccumulatorFreshness, CheckDisclosure}, names)
+	} else {
+		assert.Equal(t, []string{CheckProof, CheckDisclosure}, names)
+	}
+
+	// the latest accumulator is required, if the accumulator of the proof is
+	// too old
+	if verifierSession.ReqNonRevocationProof {
+		staleSession := *verifierSession
+		staleSession.ReqUpdatedAfter = future
+		result, err = VerifyPresentationWithResult(attester.PublicKey, nil, presentationResponse, &staleSession)
+		require.NoError(t, err)
+		assert.False(t, result.Verified())
+		freshness := result.Checks[len(result.Checks)-2]
+		assert.Equal(t, CheckAccumulatorFreshness, freshness.Name)
+		assert.True(t, errors.Is(freshness.err, ErrAccumulatorStale))
+	}
+
+	// a failed check is reported by the result
+	verifierSession.Nonce = big.NewInt(42)
+	result, err = VerifyPresentationWithResult(attester.PublicKey, update.SignedAccumulator,
+		presentationResponse, verifierSession)
+	require.NoError(t, err)
+	assert.False(t, result.Verified())
+	assert.True(t, errors.Is(result.Err(), ErrProofInvalid))
+	assert.False(t, result.Checks[0].Passed)
+	assert.NotEmpty(t, result.Checks[0].Error)
+	assert.Nil(t, result.Claim)
+}
+
+func TestVerifyPresentationHashedAttributes(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	sysParams, success := gabi.DefaultSystemParameters[KeyLength]
+	require.True(t, success)
+	picture := strings.Repeat("ab", 100)
+	claimer, cred, update := issueTestClaim(t, attester, Claim{
+		"ctype": "0xDEADBEEFCOFEE",
+		"contents": map[string]interface{}{
+			"picture": picture,
+			"name":    "Anna",
+		},
+	})
+	require.Len(t, cred.Preimages, 1)
+
+	// the preimage is disclosed by default
+	session, request, err := RequestPresentation(sysParams, []string{"contents.picture", "contents.name"}, false, future, false)
+	require.NoError(t, err)
+	response, err := claimer.BuildPresentation(attester.PublicKey, cred, request)
+	require.NoError(t, err)
+	result, err := VerifyPresentationWithResult(attester.PublicKey, update.SignedAccumulator, response, session)
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	assert.Equal(t, picture, result.Claim["contents"].(map[string]interface{})["picture"])
+	assert.Empty(t, result.HashedAttributes)
+
+	// the verifier can request the hash only
+	session, request, err = RequestPresentation(sysParams, []string{"contents.picture", "contents.name"}, false, future, false)
+	require.NoError(t, err)
+	request.PartialPresentationRequest.HashedAttributes = []string{"contents.picture"}
+	response, err = claimer.BuildPresentation(attester.PublicKey, cred, request)
+	require.NoError(t, err)
+	assert.Empty(t, response.Preimages)
+	result, err = VerifyPresentationWithResult(attester.PublicKey, update.SignedAccumulator, response, session)
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	hash, err := hashAttribute(cred.Preimages[0])
+	require.NoError(t, err)
+	contents := result.Claim["contents"].(map[string]interface{})
+	assert.Equal(t, hex.EncodeToString(hash.Value), contents["picture"])
+	assert.Equal(t, "Anna", contents["name"])
+	assert.Equal(t, []string{"contents.picture"}, result.HashedAttributes)
+}
+
+func TestVerifyCombinedPresentationWithResult(t *testing.T) {
+	attester := &Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+
+	update := &revocation.Update{}
+	err = json.Unmarshal(byteUpdate, update)
+	require.NoError(t, err)
+
+	presentationResponse := &CombinedPresentationResponse{}
+	err = json.Unmarshal(byteCombPresentationResponse, presentationResponse)
+	require.NoError(t, err)
+
+	verifierSession := &CombinedVerifierSession{}
+	err = json.Unmarshal(byteCombVerifierSession, verifierSession)
+	require.NoError(t, err)
+
+	result, err := VerifyCombinedPresentationWithResult([]*gabi.PublicKey{
+		attester.PublicKey,
+		attester.PublicKey,
+	}, []*revocation.SignedAccumulator{
+		update.SignedAccumulator,
+		update.SignedAccumulator,
+	}, presentationResponse, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, result.Verified())
+	require.Len(t, result.Results, 2)
+	assert.Len(t, result.Claims(), 2)
+	for i, r := range result.Results {
+		assert.Equal(t, verifierSession.PartialRequests[i].ReqNonRevocationProof, r.Accumulator != nil)
+		assert.Equal(t, CheckProof, r.Checks[0].Name)
+	}
+
+	// a tampered combined proof invalidates every credential
+	proofD := presentationResponse.Proof[1].(*gabi.ProofD)
+	proofD.A = new(big.Int).Add(proofD.A, big.NewInt(1))
+	result, err = VerifyCombinedPresentationWithResult([]*gabi.PublicKey{
+		attester.PublicKey,
+		attester.PublicKey,
+	}, []*revocation.SignedAccumulator{
+		update.SignedAccumulator,
+		update.SignedAccumulator,
+	}, presentationResponse, verifierSession)
+	require.NoError(t, err)
+	assert.False(t, result.Verified())
+	assert.True(t, errors.Is(result.Err(), ErrProofInvalid))
+	assert.Nil(t, result.Claims())
+	require.Len(t, result.Results, 2)
+	for _, r := range result.Results {
+		assert.False(t, r.Verified())
+		assert.True(t, errors.Is(r.Err(), ErrProofInvalid))
+		assert.Nil(t, r.Claim)
+	}
+}
+
 func TestVerifyCombinedPresentationLessKeys(t *testing.T) {
 	attester := &Attester{}
 	err := json.Unmarshal(byteAttester, attester)
@@ -134,6 +311,108 @@ func TestVerifyCombinedPresentationLessKeys(t *testing.T) {
 			update.SignedAccumulator,
 		}, presentationResponse, verifierSession)
 	assert.False(t, ok)
-	require.NoError(t, err)
+	assert.Error(t, err)
+	require.Nil(t, claims)
+
+	ok, claims, err = VerifyCombinedPresentation([]*gabi.PublicKey{attester.PublicKey, attester.PublicKey},
+		[]*revocation.SignedAccumulator{
+			update.SignedAccumulator,
+		}, presentationResponse, verifierSession)
+	assert.False(t, ok)
+	assert.Error(t, err)
 	require.Nil(t, claims)
+
+	presentationResponse.Proof = presentationResponse.Proof[:1]
+	ok, claims, err = VerifyCombinedPresentation([]*gabi.PublicKey{attester.PublicKey, attester.PublicKey},
+		[]*revocation.SignedAccumulator{
+			update.SignedAccumulator,
+			update.SignedAccumulator,
+		}, presentationResponse, verifierSession)
+	assert.False(t, ok)
+	assert.Error(t, err)
+	require.Nil(t, claims)
+}
+
+func TestKeyStore(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+	keyID, err := PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+
+	store := &KeyStore{
+		Keys:         []*gabi.PublicKey{attester.PublicKey},
+		Accumulators: []*revocation.SignedAccumulator{nil, update.SignedAccumulator},
+	}
+	pubK, err := store.PublicKey(keyID)
+	require.NoError(t, err)
+	assert.Equal(t, attester.PublicKey, pubK)
+	_, err = store.PublicKey(big.NewInt(42))
+	assert.Error(t, err)
+
+	acc, err := store.Accumulator(pubK)
+	require.NoError(t, err)
+	assert.Equal(t, update.SignedAccumulator, acc)
+
+	// the accumulator with the highest index is returned regardless of the
+	// order and the accumulators of the store are not modified
+	stale := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, stale))
+	fresh := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdateRevocation, fresh))
+	revPubKey, err := attester.PublicKey.RevocationKey()
+	require.NoError(t, err)
+	for _, accs := range [][]*revocation.SignedAccumulator{
+		{stale.SignedAccumulator, fresh.SignedAccumulator},
+		{fresh.SignedAccumulator, stale.SignedAccumulator},
+	} {
+		store := &KeyStore{Keys: []*gabi.PublicKey{attester.PublicKey}, Accumulators: accs}
+		acc, err := store.Accumulator(pubK)
+		require.NoError(t, err)
+		assert.Same(t, fresh.SignedAccumulator, acc)
+		assert.Nil(t, stale.SignedAccumulator.Accumulator)
+		assert.Nil(t, fresh.SignedAccumulator.Accumulator)
+	}
+	staleAcc, err := verifyAccumulator(stale.SignedAccumulator, revPubKey)
+	require.NoError(t, err)
+	freshAcc, err := verifyAccumulator(fresh.SignedAccumulator, revPubKey)
+	require.NoError(t, err)
+	assert.Less(t, staleAcc.Index, freshAcc.Index)
+
+	// accumulators of other keys are not returned
+	otherKey := *attester.PublicKey
+	otherKey.Counter = 1
+	_, err = store.Accumulator(&otherKey)
+	assert.Error(t, err)
+}
+
+func TestVerifyCombinedPresentationWithLookup(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+	presentationResponse := &CombinedPresentationResponse{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationResponse, presentationResponse))
+	verifierSession := &CombinedVerifierSession{}
+	require.NoError(t, json.Unmarshal(byteCombVerifierSession, verifierSession))
+	store := &KeyStore{
+		Keys:         []*gabi.PublicKey{attester.PublicKey},
+		Accumulators: []*revocation.SignedAccumulator{update.SignedAccumulator},
+	}
+
+	_, _, err := VerifyCombinedPresentationWithLookup(store, presentationResponse, verifierSession)
+	assert.Error(t, err, "presentation without key ids")
+
+	keyID, err := PublicKeyID(attester.PublicKey)
+	require.NoError(t, err)
+	presentationResponse.KeyIDs = []*big.Int{keyID, big.NewInt(42)}
+	_, _, err = VerifyCombinedPresentationWithLookup(store, presentationResponse, verifierSession)
+	assert.Error(t, err, "unknown key")
+
+	presentationResponse.KeyIDs = []*big.Int{keyID, keyID}
+	ok, claims, err := VerifyCombinedPresentationWithLookup(store, presentationResponse, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, claims, 2)
 }
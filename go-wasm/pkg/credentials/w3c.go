@@ -0,0 +1,434 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+)
+
+// The documents in this file follow the W3C Verifiable Credentials Data Model
+// (https://www.w3.org/TR/vc-data-model/).
+//
+// The credentialSubject of a document is the claim itself. An attribute name
+// is the path of a value inside the claim, its escaped keys are joined by the
+// Separator. The value of the attribute "contents.age" is therefore stored at
+// credentialSubject.contents.age and the key "a.b" of the claim is the
+// attribute "a\.b" and the key "a.b" of the credentialSubject (see
+// CredentialSubjectPath). Arrays are a single attribute and are copied as a
+// whole. A derived credential inside a presentation only contains the
+// disclosed attributes in its credentialSubject. Hashed attributes (see
+// MaxAttributeSize) are stored as their preimage, which is part of the proof.
+//
+// The proofs are not defined by the W3C credentials context, every document
+// therefore also contains the context returned by CLContext. A
+// VerifiableCredential never contains the master secret of the claimer, it is
+// added again when the credential is imported.
+const (
+	// W3CCredentialsContext is the JSON-LD context of the W3C credentials.
+	W3CCredentialsContext = "https://www.w3.org/2018/credentials/v1"
+	// CLVocabulary is the IRI prefix of the terms defined by CLContext.
+	CLVocabulary = "https://github.com/KILTprotocol/portablegabi#"
+	// CLSignatureProofType is the type of the proof of a VerifiableCredential.
+	CLSignatureProofType = "CLSignature2020"
+	// CLDerivedProofType is the type of the proof of a derived credential.
+	CLDerivedProofType = "CLSignatureDerivedProof2020"
+	// CLPresentationProofType is the type of the proof of a presentation which
+	// was created from a PresentationResponse.
+	CLPresentationProofType = "CLPresentation2020"
+	// CLCombinedPresentationProofType is the type of the proof of a presentation
+	// which was created from a CombinedPresentationResponse.
+	CLCombinedPresentationProofType = "CLCombinedPresentation2020"
+
+	typeVerifiableCredential   = "VerifiableCredential"
+	typeVerifiablePresentation = "VerifiablePresentation"
+)
+
+type (
+	// VerifiableCredential is an AttestedClaim in the format of a W3C verifiable
+	// credential.
+	VerifiableCredential struct {
+		Context           []interface{}     `json:"@context"`
+		Type              []string          `json:"type"`
+		Issuer            string            `json:"issuer"`
+		IssuanceDate      time.Time         `json:"issuanceDate"`
+		CredentialSubject Claim             `json:"credentialSubject"`
+		Proof             *CLSignatureProof `json:"proof"`
+	}
+
+	// CLSignatureProof contains the signature of a VerifiableCredential, the
+	// signed attributes except for the master secret and the preimages of the
+	// hashed attributes.
+	CLSignatureProof struct {
+		Type                 string              `json:"type"`
+		Signature            *gabi.CLSignature   `json:"signature"`
+		Attributes           []*big.Int          `json:"attributes"`
+		NonRevocationWitness *revocation.Witness `json:"nonrevWitness,omitempty"`
+		UpdateCounter        uint64              `json:"updateCounter"`
+		Preimages            []*Attribute        `json:"preimages,omitempty"`
+	}
+
+	// VerifiablePresentation is a PresentationResponse or a
+	// CombinedPresentationResponse in the format of a W3C verifiable
+	// presentation. Each disclosed credential is a DerivedCredential.
+	VerifiablePresentation struct {
+		Context              []interface{}        `json:"@context"`
+		Type                 []string             `json:"type"`
+		VerifiableCredential []*DerivedCredential `json:"verifiableCredential"`
+		Proof                *PresentationProof   `json:"proof"`
+	}
+
+	// PresentationProof binds the derived credentials of a presentation to the
+	// presentation request. The challenge is the nonce and the domain is the
+	// context of the request.
+	PresentationProof struct {
+		Type      string   `json:"type"`
+		Challenge *big.Int `json:"challenge"`
+		Domain    *big.Int `json:"domain"`
+	}
+
+	// DerivedCredential contains the disclosed attributes of a credential
+	// together with the proof of their possession.
+	DerivedCredential struct {
+		Context           []interface{} `json:"@context"`
+		Type              []string      `json:"type"`
+		Issuer            string        `json:"issuer"`
+		CredentialSubject Claim         `json:"credentialSubject"`
+		Proof             *DerivedProof `json:"proof"`
+	}
+
+	// DerivedProof contains the gabi.ProofD of a DerivedCredential and the
+	// preimages of its disclosed hashed attributes.
+	DerivedProof struct {
+		Type       string       `json:"type"`
+		ProofValue *gabi.ProofD `json:"proofValue"`
+		Preimages  []*Attribute `json:"preimages,omitempty"`
+	}
+)
+
+// CLContext returns the JSON-LD context which defines the proof types and the
+// terms of the proofs. The cryptographic values are JSON literals.
+func CLContext() map[string]interface{} {
+	jsonTerm := func(name string) map[string]interface{} {
+		return map[string]interface{}{"@id": CLVocabulary + name, "@type": "@json"}
+	}
+	return map[string]interface{}{
+		"@version":                      1.1,
+		CLSignatureProofType:            CLVocabulary + CLSignatureProofType,
+		CLDerivedProofType:              CLVocabulary + CLDerivedProofType,
+		CLPresentationProofType:         CLVocabulary + CLPresentationProofType,
+		CLCombinedPresentationProofType: CLVocabulary + CLCombinedPresentationProofType,
+		"signature":                     jsonTerm("signature"),
+		"attributes":                    jsonTerm("attributes"),
+		"nonrevWitness":                 jsonTerm("nonrevWitness"),
+		"updateCounter":                 CLVocabulary + "updateCounter",
+		"preimages":                     jsonTerm("preimages"),
+		"proofValue":                    jsonTerm("proofValue"),
+	}
+}
+
+// documentContext returns the @context of the documents in this file.
+func documentContext() []interface{} {
+	return []interface{}{W3CCredentialsContext, CLContext()}
+}
+
+// CredentialSubjectPath splits the name of an attribute into the keys of the
+// credentialSubject under which the value of the attribute is stored.
+func CredentialSubjectPath(attributeName string) ([]string, error) {
+	sep := []rune(Separator)[0]
+	parts, err := escapedSplit(attributeName, sep)
+	if err != nil {
+		return nil, err
+	}
+	path := make([]string, len(parts))
+	for i, part := range parts {
+		path[i] = unescape(part, sep)
+	}
+	return path, nil
+}
+
+// NewVerifiableCredential exports the AttestedClaim as a VerifiableCredential.
+// The issuer identifies the attester and the issuance date is the time of the
+// attestation, both are not part of the AttestedClaim. The master secret,
+// which is the first attribute of the credential, is left out.
+func NewVerifiableCredential(attestedClaim *AttestedClaim, issuer string, issuanceDate time.Time) (*VerifiableCredential, error) {
+	cred := attestedClaim.Credential
+	if cred == nil || cred.Signature == nil || len(cred.Attributes) < 1 {
+		return nil, errors.New("incomplete credential")
+	}
+	return &VerifiableCredential{
+		Context:           documentContext(),
+		Type:              []string{typeVerifiableCredential},
+		Issuer:            issuer,
+		IssuanceDate:      issuanceDate,
+		CredentialSubject: attestedClaim.Claim,
+		Proof: &CLSignatureProof{
+			Type:                 CLSignatureProofType,
+			Signature:            cred.Signature,
+			Attributes:           cred.Attributes[1:],
+			NonRevocationWitness: cred.NonRevocationWitness,
+			UpdateCounter:        attestedClaim.UpdateCounter,
+			Preimages:            attestedClaim.Preimages,
+		},
+	}, nil
+}
+
+// AttestedClaim imports the VerifiableCredential into the wallet of the
+// claimer, whose master secret becomes the first attribute of the credential.
+// It checks that the credentialSubject matches the attributes of the
+// credential, the signature has to be checked using AttestedClaim.Validate.
+// The signature only matches, if the credential was issued to the claimer.
+func (vc *VerifiableCredential) AttestedClaim(claimer *Claimer) (*AttestedClaim, error) {
+	if err := checkDocumentType(vc.Context, vc.Type, typeVerifiableCredential); err != nil {
+		return nil, err
+	}
+	if vc.Proof == nil || vc.Proof.Type != CLSignatureProofType {
+		return nil, fmt.Errorf("expected proof of type %q", CLSignatureProofType)
+	}
+	if vc.Proof.Signature == nil || len(vc.Proof.Attributes) < 1 {
+		return nil, errors.New("incomplete credential")
+	}
+	if claimer == nil || claimer.MasterSecret == nil {
+		return nil, errors.New("missing master secret")
+	}
+	cred := &gabi.Credential{
+		Signature:            vc.Proof.Signature,
+		Attributes:           append([]*big.Int{claimer.MasterSecret}, vc.Proof.Attributes...),
+		NonRevocationWitness: vc.Proof.NonRevocationWitness,
+	}
+	attestedClaim := &AttestedClaim{
+		Credential:    cred,
+		UpdateCounter: vc.Proof.UpdateCounter,
+		Claim:         vc.CredentialSubject,
+		Preimages:     vc.Proof.Preimages,
+	}
+	attributes, err := attestedClaim.getClaimAttributes()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCredentialSubject(attributes, vc.CredentialSubject); err != nil {
+		return nil, err
+	}
+	return attestedClaim, nil
+}
+
+// NewVerifiablePresentation exports the PresentationResponse, which was created
+// for the request, as a VerifiablePresentation. The issuer identifies the
+// attester of the disclosed credential.
+func NewVerifiablePresentation(response *PresentationResponse, request *PresentationRequest, issuer string) (*VerifiablePresentation, error) {
+	derived, err := newDerivedCredential(&response.Proof, response.Preimages, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return newVerifiablePresentation(CLPresentationProofType, []*DerivedCredential{derived},
+		request.Context, request.Nonce), nil
+}
+
+// NewCombinedVerifiablePresentation exports the CombinedPresentationResponse,
+// which was created for the request, as a VerifiablePresentation. The issuers
+// identify the attesters of the disclosed credentials, in the same order as the
+// proofs of the response.
+func NewCombinedVerifiablePresentation(response *CombinedPresentationResponse, request *CombinedPresentationRequest,
+	issuers []string) (*VerifiablePresentation, error) {
+	if len(issuers) != len(response.Proof) {
+		return nil, fmt.Errorf("expected %d issuers, got %d", len(response.Proof), len(issuers))
+	}
+	derived := make([]*DerivedCredential, len(response.Proof))
+	for i, genericP := range response.Proof {
+		proofD, ok := genericP.(*gabi.ProofD)
+		if !ok {
+			return nil, errors.New("unsupported proof in prooflist")
+		}
+		var preimages []*Attribute
+		if i < len(response.Preimages) {
+			preimages = response.Preimages[i]
+		}
+		var err error
+		if derived[i], err = newDerivedCredential(proofD, preimages, issuers[i]); err != nil {
+			return nil, err
+		}
+	}
+	return newVerifiablePresentation(CLCombinedPresentationProofType, derived, request.Context, request.Nonce), nil
+}
+
+// PresentationResponse imports a VerifiablePresentation which was created using
+// NewVerifiablePresentation.
+func (vp *VerifiablePresentation) PresentationResponse() (*PresentationResponse, error) {
+	proofs, err := vp.proofs(CLPresentationProofType)
+	if err != nil {
+		return nil, err
+	}
+	if len(proofs) != 1 {
+		return nil, fmt.Errorf("expected a single credential, got %d", len(proofs))
+	}
+	return &PresentationResponse{Proof: *proofs[0], Preimages: vp.VerifiableCredential[0].Proof.Preimages}, nil
+}
+
+// CombinedPresentationResponse imports a VerifiablePresentation which was
+// created using NewCombinedVerifiablePresentation.
+func (vp *VerifiablePresentation) CombinedPresentationResponse() (*CombinedPresentationResponse, error) {
+	proofs, err := vp.proofs(CLCombinedPresentationProofType)
+	if err != nil {
+		return nil, err
+	}
+	proofList := make(gabi.ProofList, len(proofs))
+	var preimages [][]*Attribute
+	for i, proof := range proofs {
+		proofList[i] = proof
+		if derivedPreimages := vp.VerifiableCredential[i].Proof.Preimages; len(derivedPreimages) > 0 {
+			if preimages == nil {
+				preimages = make([][]*Attribute, len(proofs))
+			}
+			preimages[i] = derivedPreimages
+		}
+	}
+	return &CombinedPresentationResponse{Proof: proofList, Preimages: preimages}, nil
+}
+
+// VerifyVerifiablePresentation imports the VerifiablePresentation and verifies
+// it using VerifyPresentation. Additionally the presentation must belong to the
+// session and the credentialSubject must contain exactly the disclosed claim.
+func VerifyVerifiablePresentation(issuerPubK *gabi.PublicKey, latestAcc *revocation.SignedAccumulator,
+	vp *VerifiablePresentation, session *VerifierSession) (bool, Claim, error) {
+	response, err := vp.PresentationResponse()
+	if err != nil {
+		return false, nil, err
+	}
+	if !vp.belongsTo(session.Context, session.Nonce) {
+		return false, nil, newError(ErrSessionMismatch, "presentation does not belong to the session")
+	}
+	verified, claim, err := VerifyPresentation(issuerPubK, latestAcc, response, session)
+	if err != nil || !verified {
+		return false, nil, err
+	}
+	if equal, err := claim.Equal(vp.VerifiableCredential[0].CredentialSubject); err != nil {
+		return false, nil, err
+	} else if !equal {
+		return false, nil, newError(ErrProofInvalid, "credentialSubject does not match the disclosed claim")
+	}
+	return true, claim, nil
+}
+
+// VerifyCombinedVerifiablePresentation imports the VerifiablePresentation and
+// verifies it using VerifyCombinedPresentation. Additionally the presentation
+// must belong to the session and the credentialSubjects must contain exactly
+// the disclosed claims.
+func VerifyCombinedVerifiablePresentation(attesterPubKeys []*gabi.PublicKey, latestAccs []*revocation.SignedAccumulator,
+	vp *VerifiablePresentation, session *CombinedVerifierSession) (bool, []Claim, error) {
+	response, err := vp.CombinedPresentationResponse()
+	if err != nil {
+		return false, nil, err
+	}
+	if !vp.belongsTo(session.Context, session.Nonce) {
+		return false, nil, newError(ErrSessionMismatch, "presentation does not belong to the session")
+	}
+	verified, claims, err := VerifyCombinedPresentation(attesterPubKeys, latestAccs, response, session)
+	if err != nil || !verified {
+		return false, nil, err
+	}
+	for i, claim := range claims {
+		if equal, err := claim.Equal(vp.VerifiableCredential[i].CredentialSubject); err != nil {
+			return false, nil, err
+		} else if !equal {
+			return false, nil, newError(ErrProofInvalid, "credentialSubject of the %d. credential does not match the disclosed claim", i+1)
+		}
+	}
+	return true, claims, nil
+}
+
+func newVerifiablePresentation(proofType string, derived []*DerivedCredential, context, nonce *big.Int) *VerifiablePresentation {
+	return &VerifiablePresentation{
+		Context:              documentContext(),
+		Type:                 []string{typeVerifiablePresentation},
+		VerifiableCredential: derived,
+		Proof: &PresentationProof{
+			Type:      proofType,
+			Challenge: nonce,
+			Domain:    context,
+		},
+	}
+}
+
+// newDerivedCredential creates a DerivedCredential containing the disclosed
+// attributes of the proof.
+func newDerivedCredential(proof *gabi.ProofD, preimages []*Attribute, issuer string) (*DerivedCredential, error) {
+	claim, _, err := disclosedClaim(proof, preimages, false)
+	if err != nil {
+		return nil, err
+	}
+	return &DerivedCredential{
+		Context:           documentContext(),
+		Type:              []string{typeVerifiableCredential},
+		Issuer:            issuer,
+		CredentialSubject: claim,
+		Proof: &DerivedProof{
+			Type:       CLDerivedProofType,
+			ProofValue: proof,
+			Preimages:  preimages,
+		},
+	}, nil
+}
+
+// proofs returns the proofs of the derived credentials after checking the
+// structure of the presentation.
+func (vp *VerifiablePresentation) proofs(proofType string) ([]*gabi.ProofD, error) {
+	if err := checkDocumentType(vp.Context, vp.Type, typeVerifiablePresentation); err != nil {
+		return nil, err
+	}
+	if vp.Proof == nil || vp.Proof.Type != proofType {
+		return nil, fmt.Errorf("expected proof of type %q", proofType)
+	}
+	if len(vp.VerifiableCredential) < 1 {
+		return nil, errors.New("presentation contains no credentials")
+	}
+	proofs := make([]*gabi.ProofD, len(vp.VerifiableCredential))
+	for i, derived := range vp.VerifiableCredential {
+		if err := checkDocumentType(derived.Context, derived.Type, typeVerifiableCredential); err != nil {
+			return nil, err
+		}
+		if derived.Proof == nil || derived.Proof.Type != CLDerivedProofType || derived.Proof.ProofValue == nil {
+			return nil, fmt.Errorf("expected proof of type %q for %d. credential", CLDerivedProofType, i+1)
+		}
+		proofs[i] = derived.Proof.ProofValue
+	}
+	return proofs, nil
+}
+
+// belongsTo reports whether the challenge and the domain of the presentation
+// match the nonce and the context.
+func (vp *VerifiablePresentation) belongsTo(context, nonce *big.Int) bool {
+	return vp.Proof.Challenge != nil && vp.Proof.Domain != nil &&
+		vp.Proof.Challenge.Cmp(nonce) == 0 && vp.Proof.Domain.Cmp(context) == 0
+}
+
+// checkDocumentType checks that the document uses the W3C credentials context
+// and has the expected type.
+func checkDocumentType(context []interface{}, types []string, expectedType string) error {
+	if len(context) < 1 || context[0] != W3CCredentialsContext {
+		return fmt.Errorf("expected %q as first context", W3CCredentialsContext)
+	}
+	for _, t := range types {
+		if t == expectedType {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected document of type %q", expectedType)
+}
+
+// checkCredentialSubject checks that the credentialSubject contains exactly the
+// values of the attributes.
+func checkCredentialSubject(attributes []*Attribute, subject Claim) error {
+	claim, err := newClaimFromAttribute(attributes)
+	if err != nil {
+		return err
+	}
+	if equal, err := claim.Equal(subject); err != nil {
+		return err
+	} else if !equal {
+		return newError(ErrProofInvalid, "credentialSubject does not match the attributes inside the credential")
+	}
+	return nil
+}
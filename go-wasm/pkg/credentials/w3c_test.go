@@ -0,0 +1,196 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/big"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const issuer = "did:example:attester"
+
+func TestCredentialSubjectPath(t *testing.T) {
+	claim := Claim{
+		"contents": map[string]interface{}{
+			"age": 34.,
+			"a.b": "escaped",
+		},
+		"ctype": "0xDEADBEEF",
+	}
+	attributes, err := claim.ToAttributes()
+	require.NoError(t, err)
+	for _, attr := range attributes {
+		// every attribute can be found inside the claim using its path
+		var value interface{} = map[string]interface{}(claim)
+		path, err := CredentialSubjectPath(attr.Name)
+		require.NoError(t, err)
+		for _, key := range path {
+			m, ok := value.(map[string]interface{})
+			require.True(t, ok, attr.Name)
+			value, ok = m[key]
+			require.True(t, ok, attr.Name)
+		}
+		assert.NotNil(t, value)
+	}
+	path, err := CredentialSubjectPath("contents.age")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"contents", "age"}, path)
+	path, err = CredentialSubjectPath(`contents.a\.b`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"contents", "a.b"}, path)
+}
+
+func TestVerifiableCredential(t *testing.T) {
+	cred := &AttestedClaim{}
+	require.NoError(t, json.Unmarshal(byteCredential, cred))
+	issuanceDate := time.Date(2020, 2, 17, 13, 0, 0, 0, time.UTC)
+
+	vc, err := NewVerifiableCredential(cred, issuer, issuanceDate)
+	require.NoError(t, err)
+	bts, err := json.Marshal(vc)
+	require.NoError(t, err)
+
+	document := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(bts, &document))
+	context := document["@context"].([]interface{})
+	require.Len(t, context, 2)
+	assert.Equal(t, W3CCredentialsContext, context[0])
+	clContext := context[1].(map[string]interface{})
+	for _, term := range []string{CLSignatureProofType, "signature", "attributes", "nonrevWitness", "updateCounter"} {
+		assert.Contains(t, clContext, term)
+	}
+	assert.Equal(t, issuer, document["issuer"])
+	assert.Equal(t, "2020-02-17T13:00:00Z", document["issuanceDate"])
+	subject := document["credentialSubject"].(map[string]interface{})
+	assert.Equal(t, 34., subject["contents"].(map[string]interface{})["age"])
+
+	// the master secret is not exported
+	claimer := &Claimer{}
+	require.NoError(t, json.Unmarshal(byteClaimer, claimer))
+	secret, err := json.Marshal(claimer.MasterSecret)
+	require.NoError(t, err)
+	assert.NotContains(t, string(bts), string(secret))
+	assert.NotContains(t, string(bts), claimer.MasterSecret.String())
+	assert.Len(t, vc.Proof.Attributes, len(cred.Credential.Attributes)-1)
+
+	decoded := &VerifiableCredential{}
+	require.NoError(t, json.Unmarshal(bts, decoded))
+	imported, err := decoded.AttestedClaim(claimer)
+	require.NoError(t, err)
+	assert.Equal(t, cred.UpdateCounter, imported.UpdateCounter)
+	assert.Equal(t, cred.Credential.Attributes, imported.Credential.Attributes)
+	equal, err := cred.Claim.Equal(imported.Claim)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	_, err = decoded.AttestedClaim(&Claimer{})
+	assert.Error(t, err)
+
+	// the credentialSubject must match the signed attributes
+	decoded.CredentialSubject["contents"].(map[string]interface{})["age"] = 18.
+	_, err = decoded.AttestedClaim(claimer)
+	assert.Error(t, err)
+
+	decoded.Proof.Type = CLDerivedProofType
+	_, err = decoded.AttestedClaim(claimer)
+	assert.Error(t, err)
+}
+
+func TestVerifiablePresentation(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+	request := &PresentationRequest{}
+	require.NoError(t, json.Unmarshal(bytePresentationRequest, request))
+	response := &PresentationResponse{}
+	require.NoError(t, json.Unmarshal(bytePresentationResponse, response))
+	verifierSession := &VerifierSession{}
+	require.NoError(t, json.Unmarshal(byteVerifierSession, verifierSession))
+
+	vp, err := NewVerifiablePresentation(response, request, issuer)
+	require.NoError(t, err)
+	require.Len(t, vp.VerifiableCredential, 1)
+	expected := Claim{}
+	require.NoError(t, json.Unmarshal(bytePresentation, &expected))
+	equal, err := expected.Equal(vp.VerifiableCredential[0].CredentialSubject)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	bts, err := json.Marshal(vp)
+	require.NoError(t, err)
+	decoded := &VerifiablePresentation{}
+	require.NoError(t, json.Unmarshal(bts, decoded))
+
+	ok, claim, err := VerifyVerifiablePresentation(attester.PublicKey, update.SignedAccumulator, decoded, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	equal, err = expected.Equal(claim)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	// a combined presentation can not be imported as single presentation
+	_, err = decoded.CombinedPresentationResponse()
+	assert.Error(t, err)
+
+	// the presentation must belong to the session
+	decoded.Proof.Challenge = big.NewInt(42)
+	ok, _, err = VerifyVerifiablePresentation(attester.PublicKey, update.SignedAccumulator, decoded, verifierSession)
+	assert.True(t, errors.Is(err, ErrSessionMismatch))
+	assert.False(t, ok)
+	decoded.Proof.Challenge = request.Nonce
+
+	// the credentialSubject must match the disclosed attributes
+	decoded.VerifiableCredential[0].CredentialSubject["contents"].(map[string]interface{})["age"] = 18.
+	ok, _, err = VerifyVerifiablePresentation(attester.PublicKey, update.SignedAccumulator, decoded, verifierSession)
+	assert.True(t, errors.Is(err, ErrProofInvalid))
+	assert.False(t, ok)
+}
+
+func TestCombinedVerifiablePresentation(t *testing.T) {
+	attester := &Attester{}
+	require.NoError(t, json.Unmarshal(byteAttester, attester))
+	update := &revocation.Update{}
+	require.NoError(t, json.Unmarshal(byteUpdate, update))
+	request := &CombinedPresentationRequest{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationRequest, request))
+	response := &CombinedPresentationResponse{}
+	require.NoError(t, json.Unmarshal(byteCombPresentationResponse, response))
+	verifierSession := &CombinedVerifierSession{}
+	require.NoError(t, json.Unmarshal(byteCombVerifierSession, verifierSession))
+
+	_, err := NewCombinedVerifiablePresentation(response, request, []string{issuer})
+	assert.Error(t, err)
+	vp, err := NewCombinedVerifiablePresentation(response, request, []string{issuer, issuer})
+	require.NoError(t, err)
+
+	var expected []Claim
+	require.NoError(t, json.Unmarshal(byteCombPresentation, &expected))
+	require.Len(t, vp.VerifiableCredential, len(expected))
+	for i, derived := range vp.VerifiableCredential {
+		equal, err := expected[i].Equal(derived.CredentialSubject)
+		require.NoError(t, err)
+		assert.True(t, equal)
+	}
+
+	bts, err := json.Marshal(vp)
+	require.NoError(t, err)
+	decoded := &VerifiablePresentation{}
+	require.NoError(t, json.Unmarshal(bts, decoded))
+	_, err = decoded.PresentationResponse()
+	assert.Error(t, err)
+
+	ok, claims, err := VerifyCombinedVerifiablePresentation(
+		[]*gabi.PublicKey{attester.PublicKey, attester.PublicKey},
+		[]*revocation.SignedAccumulator{update.SignedAccumulator, update.SignedAccumulator},
+		decoded, verifierSession)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, claims, 2)
+}
@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the version of the wallet file format written by Export.
+const Version = 1
+
+// scrypt parameters used for new wallet files.
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	keyLength     = 32
+	saltLength    = 16
+	maxScryptCost = 1 << 20
+	maxScryptR    = 32
+	maxScryptP    = 16
+)
+
+type (
+	// file is the json structure of an encrypted wallet.
+	file struct {
+		header
+		Nonce      []byte `json:"nonce"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+
+	// header contains the unencrypted parameters of a wallet file. The header is
+	// authenticated as additional data of the encryption.
+	header struct {
+		Version int       `json:"version"`
+		KDF     kdfParams `json:"kdf"`
+	}
+
+	// kdfParams describe how the encryption key is derived from the passphrase.
+	kdfParams struct {
+		Name string `json:"name"`
+		Salt []byte `json:"salt"`
+		N    int    `json:"n"`
+		R    int    `json:"r"`
+		P    int    `json:"p"`
+	}
+)
+
+// deriveKey derives the encryption key from the passphrase. The parameters are
+// read from the wallet file, they are bounded so that a crafted file can not
+// force an arbitrarily expensive key derivation.
+func (params *kdfParams) deriveKey(passphrase []byte) ([]byte, error) {
+	if params.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation function %q", params.Name)
+	}
+	if params.N <= 1 || params.N > maxScryptCost || params.N&(params.N-1) != 0 {
+		return nil, fmt.Errorf("scrypt cost parameter must be a power of two between 2 and %d", maxScryptCost)
+	}
+	if params.R < 1 || params.R > maxScryptR {
+		return nil, fmt.Errorf("scrypt block size must be between 1 and %d", maxScryptR)
+	}
+	if params.P < 1 || params.P > maxScryptP {
+		return nil, fmt.Errorf("scrypt parallelization must be between 1 and %d", maxScryptP)
+	}
+	if len(params.Salt) < saltLength {
+		return nil, errors.New("scrypt salt too short")
+	}
+	return scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, keyLength)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts the plaintext using AES-GCM with a key derived from the
+// passphrase and returns a json encoded wallet file.
+func encrypt(plaintext []byte, passphrase []byte) ([]byte, error) {
+	f := &file{
+		header: header{
+			Version: Version,
+			KDF: kdfParams{
+				Name: "scrypt",
+				Salt: make([]byte, saltLength),
+				N:    scryptN,
+				R:    scryptR,
+				P:    scryptP,
+			},
+		},
+	}
+	if _, err := rand.Read(f.KDF.Salt); err != nil {
+		return nil, err
+	}
+	key, err := f.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return nil, err
+	}
+	additionalData, err := json.Marshal(&f.header)
+	if err != nil {
+		return nil, err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, plaintext, additionalData)
+	return json.Marshal(f)
+}
+
+// decrypt parses a json encoded wallet file and decrypts its contents using the
+// passphrase.
+func decrypt(data []byte, passphrase []byte) ([]byte, error) {
+	f := &file{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("unsupported wallet version %d", f.Version)
+	}
+	key, err := f.KDF.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	additionalData, err := json.Marshal(&f.header)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted wallet")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,188 @@
+// Package wallet stores the master secret of a claimer together with the
+// credentials of the claimer. The wallet is encrypted under a key derived from
+// a passphrase.
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/revocation"
+)
+
+type (
+	// Wallet contains the master secret of a claimer and the credentials of the
+	// claimer.
+	Wallet struct {
+		Claimer *credentials.Claimer
+		entries map[string]*Entry
+	}
+
+	// Entry is a credential stored inside the wallet together with the public
+	// key of the attester and the update state of the witness.
+	Entry struct {
+		ID           string                     `json:"id"`
+		AttesterPubK *gabi.PublicKey            `json:"attesterPubKey"`
+		Credential   *credentials.AttestedClaim `json:"credential"`
+		UpdateState  UpdateState                `json:"updateState"`
+	}
+
+	// UpdateState describes the accumulator the witness of a credential belongs
+	// to and when the witness was updated the last time.
+	UpdateState struct {
+		AccumulatorIndex uint64    `json:"accumulatorIndex"`
+		AccumulatorTime  time.Time `json:"accumulatorTime"`
+		LastUpdate       time.Time `json:"lastUpdate"`
+	}
+
+	// contents is the plaintext which is encrypted inside a wallet file.
+	contents struct {
+		Claimer *credentials.Claimer `json:"claimer"`
+		Entries []*Entry             `json:"entries"`
+	}
+)
+
+// New creates an empty wallet for the given claimer.
+func New(claimer *credentials.Claimer) *Wallet {
+	return &Wallet{
+		Claimer: claimer,
+		entries: make(map[string]*Entry),
+	}
+}
+
+// Open decrypts a wallet file using the passphrase.
+func Open(data []byte, passphrase []byte) (*Wallet, error) {
+	plaintext, err := decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	c := &contents{}
+	if err := json.Unmarshal(plaintext, c); err != nil {
+		return nil, err
+	}
+	if c.Claimer == nil || c.Claimer.MasterSecret == nil {
+		return nil, errors.New("wallet does not contain a master secret")
+	}
+	wallet := New(c.Claimer)
+	for _, e := range c.Entries {
+		if _, ok := wallet.entries[e.ID]; ok {
+			return nil, fmt.Errorf("duplicate credential %s", e.ID)
+		}
+		wallet.entries[e.ID] = e
+	}
+	return wallet, nil
+}
+
+// Export encrypts the wallet using the passphrase and returns the wallet file.
+func (wallet *Wallet) Export(passphrase []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(&contents{
+		Claimer: wallet.Claimer,
+		Entries: wallet.List(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(plaintext, passphrase)
+}
+
+// Add validates the credential and stores it inside the wallet. It returns the
+// id of the stored credential.
+func (wallet *Wallet) Add(attesterPubK *gabi.PublicKey, cred *credentials.AttestedClaim) (string, error) {
+	if err := cred.Validate(attesterPubK, nil); err != nil {
+		return "", err
+	}
+	if cred.Credential.Attributes[0].Cmp(wallet.Claimer.MasterSecret) != 0 {
+		return "", errors.New("credential belongs to a different master secret")
+	}
+	id := credentialID(cred)
+	if _, ok := wallet.entries[id]; ok {
+		return "", fmt.Errorf("credential %s already stored", id)
+	}
+	entry := &Entry{
+		ID:           id,
+		AttesterPubK: attesterPubK,
+		Credential:   cred,
+	}
+	entry.refreshUpdateState()
+	wallet.entries[id] = entry
+	return id, nil
+}
+
+// List returns all entries sorted by their id.
+func (wallet *Wallet) List() []*Entry {
+	entries := make([]*Entry, 0, len(wallet.entries))
+	for _, e := range wallet.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// Get returns the entry with the given id.
+func (wallet *Wallet) Get(id string) (*Entry, error) {
+	e, ok := wallet.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find credential %s", id)
+	}
+	return e, nil
+}
+
+// Remove deletes the entry with the given id from the wallet.
+func (wallet *Wallet) Remove(id string) error {
+	if _, ok := wallet.entries[id]; !ok {
+		return fmt.Errorf("could not find credential %s", id)
+	}
+	delete(wallet.entries, id)
+	return nil
+}
+
+// ExportCredential returns the json encoded credential with the given id.
+func (wallet *Wallet) ExportCredential(id string) ([]byte, error) {
+	e, err := wallet.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e.Credential)
+}
+
+// Update updates the witness of the credential with the given id using all the
+// provided updates.
+func (wallet *Wallet) Update(id string, updates []*revocation.Update) error {
+	e, err := wallet.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := e.Credential.UpdateAll(e.AttesterPubK, updates); err != nil {
+		return err
+	}
+	e.refreshUpdateState()
+	return nil
+}
+
+// refreshUpdateState sets the update state to the accumulator of the witness.
+func (e *Entry) refreshUpdateState() {
+	e.UpdateState.LastUpdate = time.Now()
+	witness := e.Credential.Credential.NonRevocationWitness
+	if witness == nil || witness.SignedAccumulator == nil || witness.SignedAccumulator.Accumulator == nil {
+		return
+	}
+	e.UpdateState.AccumulatorIndex = witness.SignedAccumulator.Accumulator.Index
+	e.UpdateState.AccumulatorTime = witness.SignedAccumulator.Accumulator.Time
+}
+
+// credentialID derives the id of a credential from its signature.
+func credentialID(cred *credentials.AttestedClaim) string {
+	h := sha256.New()
+	_, _ = h.Write(cred.Credential.Signature.A.Bytes())
+	_, _ = h.Write(cred.Credential.Signature.E.Bytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package wallet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/revocation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	passphrase  = []byte("correct horse battery staple")
+	byteClaimer = []byte(`{"MasterSecret":"onIfyirb1JJTKOvFc4qMov6os2UdxyQwdRMkQl0Va5c="}`)
+)
+
+func newTestWallet(t *testing.T) *Wallet {
+	claimer := &credentials.Claimer{}
+	err := json.Unmarshal(byteClaimer, claimer)
+	require.NoError(t, err)
+	return New(claimer)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	plaintext := []byte("secret")
+	ciphertext, err := encrypt(plaintext, passphrase)
+	require.NoError(t, err)
+	assert.NotContains(t, string(ciphertext), "secret")
+
+	decrypted, err := decrypt(ciphertext, passphrase)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = decrypt(ciphertext, []byte("wrong"))
+	assert.Error(t, err)
+}
+
+func TestDecryptTamperedHeader(t *testing.T) {
+	ciphertext, err := encrypt([]byte("secret"), passphrase)
+	require.NoError(t, err)
+
+	f := &file{}
+	err = json.Unmarshal(ciphertext, f)
+	require.NoError(t, err)
+
+	f.Version = Version + 1
+	bts, err := json.Marshal(f)
+	require.NoError(t, err)
+	_, err = decrypt(bts, passphrase)
+	assert.Error(t, err)
+
+	f.Version = Version
+	f.KDF.Name = "md5"
+	bts, err = json.Marshal(f)
+	require.NoError(t, err)
+	_, err = decrypt(bts, passphrase)
+	assert.Error(t, err)
+}
+
+func TestDecryptInvalidKDF(t *testing.T) {
+	ciphertext, err := encrypt([]byte("secret"), passphrase)
+	require.NoError(t, err)
+
+	for _, change := range []func(*kdfParams){
+		func(p *kdfParams) { p.N = maxScryptCost * 2 },
+		func(p *kdfParams) { p.N = scryptN + 1 },
+		func(p *kdfParams) { p.N = 0 },
+		func(p *kdfParams) { p.R = maxScryptR + 1 },
+		func(p *kdfParams) { p.R = 0 },
+		func(p *kdfParams) { p.P = maxScryptP + 1 },
+		func(p *kdfParams) { p.P = -1 },
+		func(p *kdfParams) { p.Salt = nil },
+	} {
+		f := &file{}
+		err = json.Unmarshal(ciphertext, f)
+		require.NoError(t, err)
+		change(&f.KDF)
+		bts, err := json.Marshal(f)
+		require.NoError(t, err)
+		_, err = decrypt(bts, passphrase)
+		assert.Error(t, err)
+	}
+}
+
+func TestExportOpen(t *testing.T) {
+	wallet := newTestWallet(t)
+	wallet.entries["a"] = &Entry{ID: "a", UpdateState: UpdateState{AccumulatorIndex: 3}}
+	wallet.entries["b"] = &Entry{ID: "b"}
+
+	data, err := wallet.Export(passphrase)
+	require.NoError(t, err)
+
+	opened, err := Open(data, passphrase)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.Claimer.MasterSecret, opened.Claimer.MasterSecret)
+	entries := opened.List()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "a", entries[0].ID)
+	assert.Equal(t, uint64(3), entries[0].UpdateState.AccumulatorIndex)
+	assert.Equal(t, "b", entries[1].ID)
+
+	_, err = Open(data, []byte("wrong"))
+	assert.Error(t, err)
+}
+
+func TestGetRemove(t *testing.T) {
+	wallet := newTestWallet(t)
+	wallet.entries["a"] = &Entry{ID: "a"}
+
+	e, err := wallet.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", e.ID)
+
+	require.NoError(t, wallet.Remove("a"))
+	assert.Error(t, wallet.Remove("a"))
+	_, err = wallet.Get("a")
+	assert.Error(t, err)
+	assert.Empty(t, wallet.List())
+}
+
+func TestAddInvalidCredential(t *testing.T) {
+	wallet := newTestWallet(t)
+	_, err := wallet.Add(&gabi.PublicKey{}, &credentials.AttestedClaim{})
+	assert.Error(t, err)
+	assert.Empty(t, wallet.List())
+}
+
+func TestAddUpdateOpen(t *testing.T) {
+	attester := &credentials.Attester{}
+	err := json.Unmarshal(byteAttester, attester)
+	require.NoError(t, err)
+	cred := &credentials.AttestedClaim{}
+	err = json.Unmarshal(byteCredential, cred)
+	require.NoError(t, err)
+	update := &revocation.Update{}
+	err = json.Unmarshal(byteUpdateRevocation, update)
+	require.NoError(t, err)
+
+	wallet := newTestWallet(t)
+	id, err := wallet.Add(attester.PublicKey, cred)
+	require.NoError(t, err)
+	_, err = wallet.Add(attester.PublicKey, cred)
+	assert.Error(t, err)
+
+	data, err := wallet.Export(passphrase)
+	require.NoError(t, err)
+	opened, err := Open(data, passphrase)
+	require.NoError(t, err)
+	e, err := opened.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), e.UpdateState.AccumulatorIndex)
+	assert.NoError(t, e.Credential.Validate(e.AttesterPubK, nil))
+	exported, err := opened.ExportCredential(id)
+	require.NoError(t, err)
+	expected, err := json.Marshal(cred)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(exported))
+
+	// update the opened wallet and open it again
+	require.NoError(t, opened.Update(id, []*revocation.Update{update}))
+	data, err = opened.Export(passphrase)
+	require.NoError(t, err)
+	reopened, err := Open(data, passphrase)
+	require.NoError(t, err)
+	e, err = reopened.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), e.UpdateState.AccumulatorIndex)
+	assert.Equal(t, uint64(1), e.Credential.UpdateCounter)
+	assert.NoError(t, e.Credential.Validate(e.AttesterPubK, nil))
+	require.Len(t, reopened.List(), 1)
+}
@@ -3,22 +3,36 @@
 package wasm
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"syscall/js"
+	"time"
 
 	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
 	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/keyproof"
 	"github.com/privacybydesign/gabi/revocation"
 )
 
-// GenKeypair generates a keypair for the attester. It takes no inputs and
-// returns a list containing the private key as the fist element and the public
-// key as the second element. If the key generation fails, an error is returned.
+// GenKeypair generates a keypair for the attester. It takes the maximal number
+// of attributes, the validity duration in nano seconds, an optional key
+// length and optional options as inputs. It returns the private key and the
+// public key. If the key generation fails, an error is returned.
+//
+// The options may contain a function onProgress, which is called with the
+// progress of the key generation, and an AbortSignal signal. If the signal is
+// aborted, the promise is rejected with the code ERR_ABORTED. The key
+// generation regularly yields to the event loop, so that the signal and other
+// events are handled while the key is generated. Running it inside a Web
+// Worker keeps the page responsive. The proof that the public key is well
+// formed (see VerifyPublicKey) is created together with the key and returned
+// as publicKeyProof. Creating the proof takes several minutes, it is skipped
+// if the option withProof is false.
 func GenKeypair(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 2 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 	keyLength := DefaultKeyLength
 	if len(inputs) > 2 && !inputs[2].IsUndefined() {
@@ -28,24 +42,108 @@ func GenKeypair(this js.Value, inputs []js.Value) (interface{}, error) {
 	if !success {
 		return nil, errors.New("invalid key length")
 	}
+	options := js.Undefined()
+	if len(inputs) > 3 {
+		options = inputs[3]
+	}
+	ctx, cancel, progress := keyGenContext(options)
+	defer cancel()
 
-	attester, err := credentials.NewAttester(sysParams, inputs[0].Int(), int64(inputs[1].Int()))
+	attributeCount, periodOfValidity := inputs[0].Int(), int64(inputs[1].Int())
+	if options.Type() == js.TypeObject && options.Get("withProof").Type() == js.TypeBoolean &&
+		!options.Get("withProof").Bool() {
+		expiryDate := time.Now().Add(time.Duration(periodOfValidity))
+		privK, pubK, err := credentials.GenerateKeyPairContext(ctx, sysParams, attributeCount, 0, expiryDate, progress)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"privateKey": privK,
+			"publicKey":  pubK,
+		}, nil
+	}
+	attester, err := credentials.NewAttesterContext(ctx, sysParams, attributeCount, periodOfValidity, 0, progress)
 	if err != nil {
 		return nil, err
 	}
 	return map[string]interface{}{
-		"privateKey": attester.PrivateKey,
-		"publicKey":  attester.PublicKey,
+		"privateKey":     attester.PrivateKey,
+		"publicKey":      attester.PublicKey,
+		"publicKeyProof": attester.PublicKeyProof,
 	}, nil
 }
 
+// yieldInterval is the minimal time between two yields to the event loop during
+// the key generation.
+const yieldInterval = 50 * time.Millisecond
+
+// keyGenContext returns the context and the progress function of the key
+// generation for the options of GenKeypair. The progress function yields to
+// the event loop at most every yieldInterval, reports the progress to
+// onProgress and cancels the context once the signal is aborted.
+func keyGenContext(options js.Value) (context.Context, context.CancelFunc, credentials.ProgressFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	onProgress, signal := js.Undefined(), js.Undefined()
+	if options.Type() == js.TypeObject {
+		onProgress, signal = options.Get("onProgress"), options.Get("signal")
+	}
+	lastYield := time.Now()
+	return ctx, cancel, func(progress credentials.KeyGenProgress) {
+		// go does not return to the event loop while it computes, sleeping
+		// lets js run pending events like the abort of the signal. Each sleep
+		// takes at least one tick of the event loop, so it is not done on
+		// every call.
+		if time.Since(lastYield) >= yieldInterval {
+			time.Sleep(time.Millisecond)
+			lastYield = time.Now()
+		}
+		if signal.Type() == js.TypeObject && signal.Get("aborted").Truthy() {
+			cancel()
+			return
+		}
+		if onProgress.Type() == js.TypeFunction {
+			onProgress.Invoke(map[string]interface{}{
+				"stage":    progress.Stage,
+				"attempts": progress.Attempts,
+				"primes":   progress.Primes,
+				"step":     progress.Step,
+				"done":     progress.Done,
+				"total":    progress.Total,
+			})
+		}
+	}
+}
+
+// VerifyPublicKey verifies that the public key of an attester is well formed.
+// It takes the public key as first input and the public key proof, which was
+// returned by genKeypair, as second input. It returns true if the proof is
+// valid, otherwise an error is returned.
+func VerifyPublicKey(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 2 {
+		return nil, errMissingInputs
+	}
+	pubK := &gabi.PublicKey{}
+	if err := unmarshalInput(inputs[0], pubK); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+	proof := &keyproof.ValidKeyProof{}
+	if err := json.Unmarshal([]byte(inputs[1].String()), proof); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key proof: %v", err))
+	}
+	if err := credentials.VerifyPublicKey(pubK, proof); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
 // StartAttestationSession starts the attestation process. It takes the private
-// key of the attester as first input and the public key as second input. This
-// method returns a session object, which must be used as an argument for
-// issueAttestation and a message for the claimer
+// key of the attester as first input and the public key as second input. The
+// ctype hash of the claim which should be attested can be provided as optional
+// third input. This method returns a session object, which must be used as an
+// argument for issueAttestation and a message for the claimer
 func StartAttestationSession(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 2 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 
 	attester := &credentials.Attester{
@@ -53,19 +151,27 @@ func StartAttestationSession(this js.Value, inputs []js.Value) (interface{}, err
 		PublicKey:  &gabi.PublicKey{},
 	}
 	if err := json.Unmarshal([]byte(inputs[0].String()), attester.PrivateKey); err != nil {
-		return nil, fmt.Errorf("Error in private key: %v", err)
+		return nil, invalidArgument(fmt.Errorf("error in private key: %v", err))
+	}
+	if err := unmarshalInput(inputs[1], attester.PublicKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), attester.PublicKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	cTypeHash := ""
+	if len(inputs) > 2 && !inputs[2].IsUndefined() {
+		cTypeHash = inputs[2].String()
 	}
 
-	session, msg, err := attester.InitiateAttestation()
+	session, msg, err := attester.InitiateCTypeAttestation(cTypeHash)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := credentials.NewEnvelope(msg, msg.SessionID)
 	if err != nil {
 		return nil, err
 	}
 	return map[string]interface{}{
 		"session": session,
-		"message": msg,
+		"message": envelope,
 	}, nil
 }
 
@@ -75,7 +181,7 @@ func StartAttestationSession(this js.Value, inputs []js.Value) (interface{}, err
 // request for attestion which is was send to the attester by the claimer.
 func IssueAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 5 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 
 	attester := &credentials.Attester{
@@ -86,26 +192,30 @@ func IssueAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 	request := &credentials.AttestedClaimRequest{}
 	update := &revocation.Update{}
 	if err := json.Unmarshal([]byte(inputs[0].String()), attester.PrivateKey); err != nil {
-		return nil, fmt.Errorf("Error in private key: %v", err)
+		return nil, invalidArgument(fmt.Errorf("error in private key: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), attester.PublicKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[1], attester.PublicKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
 	}
 	if err := json.Unmarshal([]byte(inputs[2].String()), session); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[3].String()), request); err != nil {
+	if err := unmarshalMessage(inputs[3], request); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[4].String()), update); err != nil {
+	if err := unmarshalInput(inputs[4], update); err != nil {
 		return nil, err
 	}
 	sig, witness, err := attester.AttestClaim(request, session, update)
 	if err != nil {
 		return nil, err
 	}
+	envelope, err := credentials.NewEnvelope(sig, session.SessionID)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
-		"attestation": sig,
+		"attestation": envelope,
 		"witness":     witness,
 	}, nil
 }
@@ -114,7 +224,7 @@ func IssueAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 // attestations
 func CreateAccumulator(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 2 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 
 	attester := &credentials.Attester{
@@ -122,18 +232,20 @@ func CreateAccumulator(this js.Value, inputs []js.Value) (interface{}, error) {
 		PublicKey:  &gabi.PublicKey{},
 	}
 	if err := json.Unmarshal([]byte(inputs[0].String()), attester.PrivateKey); err != nil {
-		return nil, fmt.Errorf("Error in private key: %v", err)
+		return nil, invalidArgument(fmt.Errorf("error in private key: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), attester.PublicKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[1], attester.PublicKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
 	}
 	return attester.CreateAccumulator()
 }
 
 // RevokeAttestation removes the attestation witness from the given accumulator.
+// If the update is binary encoded, the new update is returned binary encoded as
+// well.
 func RevokeAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 4 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 
 	attester := &credentials.Attester{
@@ -144,34 +256,38 @@ func RevokeAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 	witnesses := []*revocation.Witness{}
 
 	if err := json.Unmarshal([]byte(inputs[0].String()), attester.PrivateKey); err != nil {
-		return nil, fmt.Errorf("Error in private key: %v", err)
+		return nil, invalidArgument(fmt.Errorf("error in private key: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), attester.PublicKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[1], attester.PublicKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), update); err != nil {
-		return nil, fmt.Errorf("Error in update: %v", err)
+	if err := unmarshalInput(inputs[2], update); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in update: %v", err))
 	}
 	if err := json.Unmarshal([]byte(inputs[3].String()), &witnesses); err != nil {
-		return nil, fmt.Errorf("Error in witness: %v", err)
+		return nil, invalidArgument(fmt.Errorf("error in witness: %v", err))
+	}
+	newUpdate, err := attester.RevokeAttestation(update, witnesses)
+	if err != nil {
+		return nil, err
 	}
-	return attester.RevokeAttestation(update, witnesses)
+	return marshalOutput(newUpdate, isBinary(inputs[2]))
 }
 
 // GetAccumulatorIndex verifies the update and returns the current accumulator index.
 func GetAccumulatorIndex(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 2 {
-		return 0, errors.New("missing inputs")
+		return 0, errMissingInputs
 	}
 
 	pubKey := gabi.PublicKey{}
 	update := revocation.Update{}
 
-	if err := json.Unmarshal([]byte(inputs[0].String()), &pubKey); err != nil {
-		return 0, fmt.Errorf("Error in witness: %v", err)
+	if err := unmarshalInput(inputs[0], &pubKey); err != nil {
+		return 0, invalidArgument(fmt.Errorf("error in witness: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), &update); err != nil {
-		return 0, fmt.Errorf("Error in update: %v", err)
+	if err := unmarshalInput(inputs[1], &update); err != nil {
+		return 0, invalidArgument(fmt.Errorf("error in update: %v", err))
 	}
 
 	revPubKey, err := pubKey.RevocationKey()
@@ -180,7 +296,7 @@ func GetAccumulatorIndex(this js.Value, inputs []js.Value) (interface{}, error)
 	}
 	acc, err := update.Verify(revPubKey)
 	if err != nil {
-		return 0, fmt.Errorf("Could not verify update: ", err)
+		return 0, fmt.Errorf("could not verify update: %v", err)
 	}
 
 	return acc.Index, nil
@@ -189,17 +305,17 @@ func GetAccumulatorIndex(this js.Value, inputs []js.Value) (interface{}, error)
 // GetAccumulatorTimestamp verifies the update and returns the current accumulator Timestamp.
 func GetAccumulatorTimestamp(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 2 {
-		return 0, errors.New("missing inputs")
+		return 0, errMissingInputs
 	}
 
 	pubKey := gabi.PublicKey{}
 	update := revocation.Update{}
 
-	if err := json.Unmarshal([]byte(inputs[0].String()), &pubKey); err != nil {
-		return 0, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[0], &pubKey); err != nil {
+		return 0, invalidArgument(fmt.Errorf("error in public key: %v", err))
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), &update); err != nil {
-		return 0, fmt.Errorf("Error in update key: %v", err)
+	if err := unmarshalInput(inputs[1], &update); err != nil {
+		return 0, invalidArgument(fmt.Errorf("error in update key: %v", err))
 	}
 
 	revPubKey, err := pubKey.RevocationKey()
@@ -0,0 +1,131 @@
+// +build wasm
+
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"syscall/js"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/revocation"
+)
+
+// newBinaryValue returns a pointer to a new value of the given type which can
+// be encoded using the binary encoding.
+func newBinaryValue(name string) (interface{}, error) {
+	switch name {
+	case "publicKey":
+		return &gabi.PublicKey{}, nil
+	case "credential":
+		return &credentials.AttestedClaim{}, nil
+	case "update":
+		return &revocation.Update{}, nil
+	case "presentation":
+		return &credentials.PresentationResponse{}, nil
+	case "combinedPresentation":
+		return &credentials.CombinedPresentationResponse{}, nil
+	case "presentationRequest":
+		return &credentials.PresentationRequest{}, nil
+	case "combinedPresentationRequest":
+		return &credentials.CombinedPresentationRequest{}, nil
+	default:
+		return nil, fmt.Errorf("unknown binary type %q", name)
+	}
+}
+
+// isBinary reports whether the js value is an Uint8Array.
+func isBinary(v js.Value) bool {
+	return v.InstanceOf(js.Global().Get("Uint8Array"))
+}
+
+// isBinaryList reports whether the js value is an Array of Uint8Arrays.
+func isBinaryList(v js.Value) bool {
+	return v.InstanceOf(js.Global().Get("Array")) && v.Length() > 0 && isBinary(v.Index(0))
+}
+
+// unmarshalInput decodes the input into dst. The input is either a json encoded
+// string or an Uint8Array containing the binary encoding of the value.
+func unmarshalInput(input js.Value, dst interface{}) error {
+	if !isBinary(input) {
+		return json.Unmarshal([]byte(input.String()), dst)
+	}
+	bts, err := bytesFromJS(input)
+	if err != nil {
+		return err
+	}
+	return credentials.UnmarshalBinary(bts, dst)
+}
+
+// unmarshalListInput decodes the input into dst, which must be a pointer to a
+// slice of pointers. The input is either a json encoded array or a js Array
+// whose elements can be decoded using unmarshalInput.
+func unmarshalListInput(input js.Value, dst interface{}) error {
+	if !input.InstanceOf(js.Global().Get("Array")) {
+		return json.Unmarshal([]byte(input.String()), dst)
+	}
+	slice := reflect.ValueOf(dst).Elem()
+	elemType := slice.Type().Elem().Elem()
+	list := reflect.MakeSlice(slice.Type(), input.Length(), input.Length())
+	for i := 0; i < input.Length(); i++ {
+		elem := reflect.New(elemType)
+		if err := unmarshalInput(input.Index(i), elem.Interface()); err != nil {
+			return fmt.Errorf("invalid %d. element: %v", i+1, err)
+		}
+		list.Index(i).Set(elem)
+	}
+	slice.Set(list)
+	return nil
+}
+
+// marshalOutput returns the binary encoding of v if binary is true. Otherwise
+// v is returned unchanged and json encoded by the caller.
+func marshalOutput(v interface{}, binary bool) (interface{}, error) {
+	if !binary {
+		return v, nil
+	}
+	return credentials.MarshalBinary(v)
+}
+
+// EncodeBinary converts a json encoded value into the binary encoding. As input
+// this method takes the type of the value ("publicKey", "credential",
+// "update", "presentation", "combinedPresentation", "presentationRequest" or
+// "combinedPresentationRequest") and the json encoded value. It returns an
+// Uint8Array.
+func EncodeBinary(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 2 {
+		return nil, errors.New("missing inputs to encode value")
+	}
+	v, err := newBinaryValue(inputs[0].String())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(inputs[1].String()), v); err != nil {
+		return nil, err
+	}
+	return credentials.MarshalBinary(v)
+}
+
+// DecodeBinary converts a binary encoded value into its json encoding. As
+// input this method takes the type of the value (see EncodeBinary) and the
+// Uint8Array containing the binary encoded value.
+func DecodeBinary(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 2 {
+		return nil, errors.New("missing inputs to decode value")
+	}
+	v, err := newBinaryValue(inputs[0].String())
+	if err != nil {
+		return nil, err
+	}
+	bts, err := bytesFromJS(inputs[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := credentials.UnmarshalBinary(bts, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"syscall/js"
 
 	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
@@ -14,7 +15,10 @@ import (
 	"github.com/privacybydesign/gabi/revocation"
 )
 
-// GenKey creates the private key for the claimer
+// GenKey creates the private key for the claimer. The key is derived from a
+// new BIP39 mnemonic, which is returned together with the claimer. The
+// mnemonic can be used to restore the key using KeyFromSeed. This method
+// accepts the key length and a password for the mnemonic as optional inputs.
 func GenKey(this js.Value, inputs []js.Value) (interface{}, error) {
 	keyLength := DefaultKeyLength
 	if len(inputs) > 0 && !inputs[0].IsUndefined() {
@@ -24,36 +28,56 @@ func GenKey(this js.Value, inputs []js.Value) (interface{}, error) {
 	if !success {
 		return nil, errors.New("invalid key length")
 	}
+	password := ""
+	if len(inputs) > 1 && !inputs[1].IsUndefined() {
+		password = inputs[1].String()
+	}
 
-	claimer, err := credentials.NewClaimer(sysParams)
+	claimer, mnemonic, err := credentials.NewClaimerWithMnemonic(sysParams, password)
 	if err != nil {
 		return nil, err
 	}
-	return claimer, nil
+	return map[string]interface{}{
+		"claimer":  claimer,
+		"mnemonic": mnemonic,
+	}, nil
 }
 
-// KeyFromSeed derives a key from a given seed
+// KeyFromSeed derives a key from a given seed. The seed is either a
+// hexadecimal string starting with '0x' or a BIP39 mnemonic. This method
+// accepts the key length and a password for the mnemonic as optional inputs.
 func KeyFromSeed(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 1 {
 		return nil, errors.New("missing seed to generate claimer keys")
 	}
-	// get seed
-	hexString := inputs[0].String()
-	if len(hexString) < 4 || hexString[:2] != "0x" {
-		return nil, errors.New("seed should be a hexadecimal string starting with '0x' followed by at least two hexadecimal digits")
-	}
-	seed, err := hex.DecodeString(hexString[2:])
-
 	// get optional key length
 	keyLength := DefaultKeyLength
-	if len(inputs) > 2 && !inputs[2].IsUndefined() {
-		keyLength = inputs[2].Int()
+	if len(inputs) > 1 && !inputs[1].IsUndefined() {
+		keyLength = inputs[1].Int()
 	}
 	sysParams, success := gabi.DefaultSystemParameters[keyLength]
 	if !success {
 		return nil, errors.New("invalid key length")
 	}
 
+	seedString := inputs[0].String()
+	if !strings.HasPrefix(seedString, "0x") {
+		password := ""
+		if len(inputs) > 2 && !inputs[2].IsUndefined() {
+			password = inputs[2].String()
+		}
+		return credentials.NewClaimerFromMnemonic(sysParams, seedString, password)
+	}
+
+	// get seed
+	if len(seedString) < 4 {
+		return nil, errors.New("seed should be a hexadecimal string starting with '0x' followed by at least two hexadecimal digits")
+	}
+	seed, err := hex.DecodeString(seedString[2:])
+	if err != nil {
+		return nil, err
+	}
+
 	// create claimer
 	claimer, err := credentials.NewClaimerFromSecret(sysParams, seed)
 	if err != nil {
@@ -66,7 +90,9 @@ func KeyFromSeed(this js.Value, inputs []js.Value) (interface{}, error) {
 // attestation of specific attributes. The second object should be sent to an
 // attester. This method expects as inputs the private key of the claimer, a
 // json encoded string containing the claim which should be attested, the
-// handshake message from the attester and the public key of the attester.
+// handshake message from the attester and the public key of the attester. The
+// encoding of the claim ("elements" to disclose array elements individually)
+// can be provided as optional fifth input.
 func RequestAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 4 {
 		return nil, errors.New("missing inputs to request attestation")
@@ -82,20 +108,29 @@ func RequestAttestation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if err := json.Unmarshal([]byte(inputs[1].String()), &claim); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), handshakeMsg); err != nil {
+	if err := unmarshalMessage(inputs[2], handshakeMsg); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[3].String()), issuerPubKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[3], issuerPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+
+	encoding := credentials.DefaultEncoding
+	if len(inputs) > 4 && !inputs[4].IsUndefined() {
+		encoding = credentials.ClaimEncoding(inputs[4].String())
 	}
 
-	session, msg, err := claimer.RequestAttestationForClaim(issuerPubKey, handshakeMsg, claim)
+	session, msg, err := claimer.RequestAttestationForClaimWithEncoding(issuerPubKey, handshakeMsg, claim, encoding)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := credentials.NewEnvelope(msg, handshakeMsg.SessionID)
 	if err != nil {
 		return nil, err
 	}
 	return map[string]interface{}{
 		"session": session,
-		"message": msg,
+		"message": envelope,
 	}, nil
 }
 
@@ -117,7 +152,7 @@ func BuildCredential(this js.Value, inputs []js.Value) (interface{}, error) {
 	if err := json.Unmarshal([]byte(inputs[1].String()), session); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), signature); err != nil {
+	if err := unmarshalMessage(inputs[2], signature); err != nil {
 		return nil, err
 	}
 
@@ -132,7 +167,8 @@ func BuildCredential(this js.Value, inputs []js.Value) (interface{}, error) {
 // attributes. This method takes as input the private key of the claimer, the
 // credential which contains the requested attributes, a json encoded list
 // containing the requested attributes and the public key of the attester.
-// It returns a proof containing the values of the requested attributes.
+// It returns a proof containing the values of the requested attributes. If the
+// credential is binary encoded, the proof is returned binary encoded as well.
 func BuildPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 4 {
 		return nil, errors.New("missing inputs to build presentation")
@@ -145,21 +181,24 @@ func BuildPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if err := json.Unmarshal([]byte(inputs[0].String()), claimer); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), credential); err != nil {
+	if err := unmarshalInput(inputs[1], credential); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), request); err != nil {
+	if err := unmarshalMessage(inputs[2], request); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[3].String()), issuerPubKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[3], issuerPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+	if err := credential.Validate(issuerPubKey, nil); err != nil {
+		return nil, err
 	}
 
 	disclosedAttr, err := claimer.BuildPresentation(issuerPubKey, credential, request)
 	if err != nil {
 		return nil, err
 	}
-	return disclosedAttr, nil
+	return marshalMessage(disclosedAttr, isBinary(inputs[1]))
 }
 
 func BuildCombinedPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
@@ -174,24 +213,35 @@ func BuildCombinedPresentation(this js.Value, inputs []js.Value) (interface{}, e
 	if err := json.Unmarshal([]byte(inputs[0].String()), claimer); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), &creds); err != nil {
+	if err := unmarshalListInput(inputs[1], &creds); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), request); err != nil {
+	if err := unmarshalMessage(inputs[2], request); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[3].String()), &attesterPubKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalListInput(inputs[3], &attesterPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+	if len(creds) != len(attesterPubKey) {
+		return nil, invalidArgument(fmt.Errorf("got %d credentials but %d public keys",
+			len(creds), len(attesterPubKey)))
+	}
+	for i, cred := range creds {
+		if err := cred.Validate(attesterPubKey[i], nil); err != nil {
+			return nil, fmt.Errorf("invalid %d. credential: %v", i+1, err)
+		}
 	}
 
 	disclosedAttr, err := claimer.BuildCombinedPresentation(attesterPubKey, creds, request)
 	if err != nil {
 		return nil, err
 	}
-	return disclosedAttr, nil
+	return marshalMessage(disclosedAttr, isBinaryList(inputs[1]))
 }
 
 // UpdateCredential updates the non revocation witness using the provided update.
+// If the credential is binary encoded, the updated credential is returned
+// binary encoded as well.
 func UpdateCredential(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 3 {
 		return nil, errors.New("missing inputs to update credential")
@@ -200,23 +250,28 @@ func UpdateCredential(this js.Value, inputs []js.Value) (interface{}, error) {
 	update := &revocation.Update{}
 	issuerPubKey := &gabi.PublicKey{}
 
-	if err := json.Unmarshal([]byte(inputs[0].String()), credential); err != nil {
+	if err := unmarshalInput(inputs[0], credential); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), update); err != nil {
+	if err := unmarshalInput(inputs[1], update); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), issuerPubKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[2], issuerPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+	if err := credential.Validate(issuerPubKey, nil); err != nil {
+		return nil, err
 	}
 
 	if err := credential.Update(issuerPubKey, update); err != nil {
 		return nil, err
 	}
-	return credential, nil
+	return marshalOutput(credential, isBinary(inputs[0]))
 }
 
 // UpdateAllCredential updates the non revocation witness using all the provided updates.
+// If the credential is binary encoded, the updated credential is returned
+// binary encoded as well.
 func UpdateAllCredential(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 3 {
 		return nil, errors.New("missing inputs to update credential")
@@ -225,18 +280,21 @@ func UpdateAllCredential(this js.Value, inputs []js.Value) (interface{}, error)
 	updates := []*revocation.Update{}
 	issuerPubKey := &gabi.PublicKey{}
 
-	if err := json.Unmarshal([]byte(inputs[0].String()), credential); err != nil {
+	if err := unmarshalInput(inputs[0], credential); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[1].String()), &updates); err != nil {
+	if err := unmarshalListInput(inputs[1], &updates); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), issuerPubKey); err != nil {
-		return nil, fmt.Errorf("Error in public key: %v", err)
+	if err := unmarshalInput(inputs[2], issuerPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+	if err := credential.Validate(issuerPubKey, nil); err != nil {
+		return nil, err
 	}
 
 	if err := credential.UpdateAll(issuerPubKey, updates); err != nil {
 		return nil, err
 	}
-	return credential, nil
+	return marshalOutput(credential, isBinary(inputs[0]))
 }
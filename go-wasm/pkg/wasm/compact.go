@@ -0,0 +1,90 @@
+// +build wasm
+
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/compact"
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+)
+
+// frameOptions are the json encoded options of EncodeFrames.
+type frameOptions struct {
+	Encoding       string `json:"encoding"`
+	Deflate        bool   `json:"deflate"`
+	MaxFrameLength int    `json:"maxFrameLength"`
+}
+
+// EncodeFrames encodes a presentation request or a presentation into frames
+// which can be displayed as QR codes. As input this method takes the message
+// (a json encoded envelope or an Uint8Array containing the binary encoding)
+// and optionally the json encoded options ({"encoding": "base45"|"base64url",
+// "deflate": bool, "maxFrameLength": number}). It returns a list of frames.
+func EncodeFrames(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 1 {
+		return nil, errors.New("missing message to encode")
+	}
+	var msg interface{}
+	if isBinary(inputs[0]) {
+		bts, err := bytesFromJS(inputs[0])
+		if err != nil {
+			return nil, err
+		}
+		if msg, err = credentials.DecodeBinary(bts); err != nil {
+			return nil, err
+		}
+	} else {
+		_, decoded, err := credentials.DecodeMessage([]byte(inputs[0].String()))
+		if err != nil {
+			return nil, err
+		}
+		msg = decoded
+	}
+
+	jsOpts := &frameOptions{}
+	if len(inputs) > 1 && inputs[1].Type() == js.TypeString {
+		if err := json.Unmarshal([]byte(inputs[1].String()), jsOpts); err != nil {
+			return nil, err
+		}
+	}
+	opts := &compact.Options{
+		Deflate:        jsOpts.Deflate,
+		MaxFrameLength: jsOpts.MaxFrameLength,
+	}
+	switch jsOpts.Encoding {
+	case "", "base45":
+		opts.Encoding = compact.Base45
+	case "base64url":
+		opts.Encoding = compact.Base64URL
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", jsOpts.Encoding)
+	}
+	return compact.Encode(msg, opts)
+}
+
+// DecodeFrames decodes a message from its frames. As input this method takes
+// the frames as json encoded list or js Array of strings. It returns the
+// message wrapped into an envelope.
+func DecodeFrames(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 1 {
+		return nil, errors.New("missing frames to decode")
+	}
+	var frames []string
+	if inputs[0].InstanceOf(js.Global().Get("Array")) {
+		frames = make([]string, inputs[0].Length())
+		for i := range frames {
+			frames[i] = inputs[0].Index(i).String()
+		}
+	} else if err := json.Unmarshal([]byte(inputs[0].String()), &frames); err != nil {
+		return nil, err
+	}
+	msg, err := compact.Decode(frames)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewEnvelope(msg, nil)
+}
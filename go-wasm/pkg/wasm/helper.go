@@ -3,9 +3,14 @@
 package wasm
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"syscall/js"
 	"time"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
 )
 
 // KeyLength sets the length of the used keys. Possible values are 1024, 2048, 4096
@@ -20,54 +25,219 @@ type GoFunction func(js.Value, []js.Value) (interface{}, error)
 // JSFunction is a function which can be used from JS code.
 type JSFunction func(js.Value, []js.Value) interface{}
 
-// Callbacker takes a go function and wraps it, so that a callback is
-// called. This makes is usable as a js interface function
-func Callbacker(function GoFunction) JSFunction {
+// Error codes which are set on the JS errors returned by Promiser.
+const (
+	// ErrCodeFailed is used if the go function returned an error.
+	ErrCodeFailed = "ERR_FAILED"
+	// ErrCodeInvalidArgument is used if an argument could not be converted.
+	ErrCodeInvalidArgument = "ERR_INVALID_ARGUMENT"
+	// ErrCodeInternal is used if the go function panicked.
+	ErrCodeInternal = "ERR_INTERNAL"
+	// ErrCodeProofInvalid is used for credentials.ErrProofInvalid.
+	ErrCodeProofInvalid = "ERR_PROOF_INVALID"
+	// ErrCodeAccumulatorStale is used for credentials.ErrAccumulatorStale.
+	ErrCodeAccumulatorStale = "ERR_ACCUMULATOR_STALE"
+	// ErrCodeAttributeMissing is used for credentials.ErrAttributeMissing.
+	ErrCodeAttributeMissing = "ERR_ATTRIBUTE_MISSING"
+	// ErrCodeKeyExpired is used for credentials.ErrKeyExpired.
+	ErrCodeKeyExpired = "ERR_KEY_EXPIRED"
+	// ErrCodeKeyUnknown is used for credentials.ErrKeyUnknown.
+	ErrCodeKeyUnknown = "ERR_KEY_UNKNOWN"
+	// ErrCodeSessionMismatch is used for credentials.ErrSessionMismatch.
+	ErrCodeSessionMismatch = "ERR_SESSION_MISMATCH"
+	// ErrCodeUntrusted is used for credentials.ErrUntrusted.
+	ErrCodeUntrusted = "ERR_UNTRUSTED"
+	// ErrCodeAborted is used if the function was aborted using an AbortSignal.
+	ErrCodeAborted = "ERR_ABORTED"
+)
+
+// errorCodes maps the errors of the credentials package and the cancellation
+// of a context to their codes.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{credentials.ErrProofInvalid, ErrCodeProofInvalid},
+	{credentials.ErrAccumulatorStale, ErrCodeAccumulatorStale},
+	{credentials.ErrAttributeMissing, ErrCodeAttributeMissing},
+	{credentials.ErrKeyExpired, ErrCodeKeyExpired},
+	{credentials.ErrKeyUnknown, ErrCodeKeyUnknown},
+	{credentials.ErrSessionMismatch, ErrCodeSessionMismatch},
+	{credentials.ErrUntrusted, ErrCodeUntrusted},
+	{context.Canceled, ErrCodeAborted},
+}
+
+// errMissingInputs is returned if a function is called with too few inputs.
+var errMissingInputs = &codedError{code: ErrCodeInvalidArgument, err: errors.New("missing inputs")}
+
+// Promiser takes a go function and wraps it, so that it returns a js Promise.
+// The go function is executed inside a goroutine. If the function returns an
+// error or panics, the promise is rejected with a js Error which contains an
+// error code.
+func Promiser(function GoFunction) JSFunction {
 	return func(this js.Value, inputs []js.Value) interface{} {
-		// the callback is the last argument, check that it's there and retrieve it
-		if len(inputs) == 0 {
-			panic("Callback argument is missing")
+		// the inputs are only valid during the call, copy them for the goroutine
+		args := append([]js.Value{}, inputs...)
+		executor := js.FuncOf(func(_ js.Value, handlers []js.Value) interface{} {
+			resolve, reject := handlers[0], handlers[1]
+			go func() {
+				value, err := callSafe(function, this, args)
+				if err != nil {
+					reject.Invoke(newJSError(err))
+					return
+				}
+				resolve.Invoke(value)
+			}()
+			return nil
+		})
+		defer executor.Release()
+		return js.Global().Get("Promise").New(executor)
+	}
+}
+
+// callSafe calls the go function, converts the output and recovers from
+// panics.
+func callSafe(function GoFunction, this js.Value, inputs []js.Value) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if jsErr, ok := r.(*js.ValueError); ok {
+				err = &codedError{code: ErrCodeInvalidArgument, err: jsErr}
+				return
+			}
+			err = &codedError{code: ErrCodeInternal, err: fmt.Errorf("%v", r)}
 		}
-		callback := inputs[len(inputs)-1]
+	}()
+	output, err := function(this, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return toJSValue(output)
+}
 
-		// call the actual function without the callback
-		output, err := function(this, inputs[:len(inputs)-1])
-		if err != nil {
-			callback.Invoke(err.Error(), js.Null())
-			return nil
+// codedError is an error with an error code which is exposed to js.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// invalidArgument marks the error as caused by an invalid input.
+func invalidArgument(err error) error {
+	return &codedError{code: ErrCodeInvalidArgument, err: err}
+}
+
+// errorCode returns the code which is exposed to js for the error.
+func errorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
 		}
-		// if there was no error try to convert some of the return values to js.Values
-		switch x := output.(type) {
-		case []interface{}:
-			retValues := make([]interface{}, len(x))
-			for i, e := range x {
-				marshaledV, err := json.Marshal(e)
-				if err != nil {
-					callback.Invoke(err.Error(), js.Null())
-					return nil
-				}
-				retValues[i] = string(marshaledV)
+	}
+	return ErrCodeFailed
+}
+
+// verificationFailure reports whether the error was returned because a
+// presentation could not be verified. In this case the verify functions do
+// not reject, they return verified false together with the error code.
+func verificationFailure(err error) bool {
+	return errors.Is(err, credentials.ErrProofInvalid) ||
+		errors.Is(err, credentials.ErrAccumulatorStale) ||
+		errors.Is(err, credentials.ErrSessionMismatch)
+}
+
+// newJSError creates a js Error containing the message and the code of the
+// error.
+func newJSError(err error) js.Value {
+	jsErr := js.Global().Get("Error").New(err.Error())
+	jsErr.Set("code", errorCode(err))
+	return jsErr
+}
+
+// toJSValue converts the output of a go function into a value which can be
+// passed to js. Byte slices are converted to Uint8Arrays, all other values are
+// json encoded. The elements of slices and maps are converted individually.
+func toJSValue(output interface{}) (interface{}, error) {
+	switch x := output.(type) {
+	case []byte:
+		return bytesToJS(x), nil
+	case []interface{}:
+		retValues := make([]interface{}, len(x))
+		for i, e := range x {
+			marshaledV, err := json.Marshal(e)
+			if err != nil {
+				return nil, err
 			}
-			callback.Invoke(js.Null(), js.ValueOf(retValues))
-		case map[string]interface{}:
-			retValues := make(map[string]interface{})
-			for k, v := range x {
-				marshaledV, err := json.Marshal(v)
-				if err != nil {
-					callback.Invoke(err.Error(), js.Null())
-					return nil
-				}
-				retValues[k] = string(marshaledV)
+			retValues[i] = string(marshaledV)
+		}
+		return js.ValueOf(retValues), nil
+	case map[string]interface{}:
+		retValues := make(map[string]interface{})
+		for k, v := range x {
+			if b, ok := v.([]byte); ok {
+				retValues[k] = bytesToJS(b)
+				continue
 			}
-			callback.Invoke(js.Null(), js.ValueOf(retValues))
-		default:
-			marshaledV, err := json.Marshal(output)
+			marshaledV, err := json.Marshal(v)
 			if err != nil {
-				callback.Invoke(err.Error(), js.Null())
-				return nil
+				return nil, err
 			}
-			callback.Invoke(js.Null(), string(marshaledV))
+			retValues[k] = string(marshaledV)
 		}
-		return nil
+		return js.ValueOf(retValues), nil
+	default:
+		marshaledV, err := json.Marshal(output)
+		if err != nil {
+			return nil, err
+		}
+		return string(marshaledV), nil
+	}
+}
+
+// bytesToJS copies the bytes into a new Uint8Array.
+func bytesToJS(b []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(array, b)
+	return array
+}
+
+// bytesFromJS copies the content of an Uint8Array into a byte slice.
+func bytesFromJS(v js.Value) ([]byte, error) {
+	if !v.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, errors.New("expected Uint8Array")
+	}
+	b := make([]byte, v.Length())
+	js.CopyBytesToGo(b, v)
+	return b, nil
+}
+
+// unmarshalMessage decodes a protocol message into msg. The input is either a
+// json encoded envelope, a json encoded message without envelope (see
+// credentials.DecodeMessageAs) or an Uint8Array containing the binary encoding
+// of a presentation.
+func unmarshalMessage(input js.Value, msg interface{}) error {
+	if isBinary(input) {
+		return unmarshalInput(input, msg)
+	}
+	_, err := credentials.DecodeMessageAs([]byte(input.String()), msg)
+	return err
+}
+
+// marshalMessage returns the binary encoding of the message if binary is true.
+// Otherwise the message is wrapped into an envelope.
+func marshalMessage(msg interface{}, binary bool) (interface{}, error) {
+	if binary {
+		return credentials.MarshalBinary(msg)
 	}
+	return credentials.NewEnvelope(msg, nil)
 }
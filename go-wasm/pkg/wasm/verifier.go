@@ -12,20 +12,24 @@ import (
 	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
 	"github.com/privacybydesign/gabi"
 	"github.com/privacybydesign/gabi/revocation"
+	"github.com/privacybydesign/gabi/signed"
 )
 
 // RequestPresentation creates a message which request the discloser of
 // specific attributes. As input this method takes the names of the requested
-// attributes. This message takes a variable number of inputs. If no error
-// occurs a session object and a message for the claimer is returned.
+// attributes. This message takes a variable number of inputs. The optional
+// fifth input enables the strict decoding of the disclosed attributes (see
+// credentials.VerifierSession). If no error occurs a session object and a
+// message for the claimer is returned.
 func RequestPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 3 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 	keyLength := DefaultKeyLength
-	if len(inputs) > 4 && !inputs[3].IsUndefined() {
+	if len(inputs) > 3 && !inputs[3].IsUndefined() {
 		keyLength = inputs[3].Int()
 	}
+	strict := len(inputs) > 4 && inputs[4].Truthy()
 	sysParams, success := gabi.DefaultSystemParameters[keyLength]
 	if !success {
 		return nil, errors.New("invalid key length")
@@ -39,22 +43,35 @@ func RequestPresentation(this js.Value, inputs []js.Value) (interface{}, error)
 	if err != nil {
 		return nil, err
 	}
-	session, msg := credentials.RequestPresentation(sysParams, requestedAttributes, inputs[0].Bool(), updateAfter)
+	session, msg, err := credentials.RequestPresentation(sysParams, requestedAttributes, inputs[0].Bool(), updateAfter, strict)
+	if err != nil {
+		return nil, err
+	}
 
+	envelope, err := credentials.NewEnvelope(msg, nil)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
 		"session": session,
-		"message": msg,
+		"message": envelope,
 	}, nil
 }
 
+// RequestCombinedPresentation creates a message which requests the disclosure
+// of multiple credentials. As input this method takes the list of partial
+// requests, an optional key length and an optional flag, which enables the
+// strict decoding of the disclosed attributes. If no error occurs a session
+// object and a message for the claimer is returned.
 func RequestCombinedPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 1 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 	keyLength := DefaultKeyLength
 	if len(inputs) > 1 && !inputs[1].IsUndefined() {
 		keyLength = inputs[1].Int()
 	}
+	strict := len(inputs) > 2 && inputs[2].Truthy()
 	sysParams, success := gabi.DefaultSystemParameters[keyLength]
 	if !success {
 		return nil, errors.New("invalid key length")
@@ -66,72 +83,95 @@ func RequestCombinedPresentation(this js.Value, inputs []js.Value) (interface{},
 		return nil, err
 	}
 
-	session, msg := credentials.RequestCombinedPresentation(sysParams, sessionArgs)
+	session, msg, err := credentials.RequestCombinedPresentation(sysParams, sessionArgs, strict)
+	if err != nil {
+		return nil, err
+	}
 
+	envelope, err := credentials.NewEnvelope(msg, nil)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
 		"session": session,
-		"message": msg,
+		"message": envelope,
 	}, nil
 }
 
 // VerifyPresentation verifies that the proof of the claimer is valid. As input
 // this method takes the proof, a session object (created using
 // startVerificationSession) and the public key of the attester which attested
-// the claim
+// the claim. If the proof is invalid or stale, verified is false and errorCode
+// contains the reason. The outcome of every check is returned as result (see
+// credentials.VerificationResult).
 func VerifyPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 4 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 
 	proof := &credentials.PresentationResponse{}
 	session := &credentials.VerifierSession{}
 	attesterPubKey := &gabi.PublicKey{}
 	update := &revocation.Update{}
-	if err := json.Unmarshal([]byte(inputs[0].String()), proof); err != nil {
-		return nil, fmt.Errorf("could not parse string: '%s' into credentials.PresentationResponse", inputs[0].String())
+	if err := unmarshalMessage(inputs[0], proof); err != nil {
+		return nil, invalidArgument(fmt.Errorf("could not parse string: '%s' into credentials.PresentationResponse", inputs[0].String()))
 	}
 	if err := json.Unmarshal([]byte(inputs[1].String()), session); err != nil {
-		return nil, fmt.Errorf("could not parse string: '%s' into credentials.VerifierSession", inputs[1].String())
+		return nil, invalidArgument(fmt.Errorf("could not parse string: '%s' into credentials.VerifierSession", inputs[1].String()))
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), attesterPubKey); err != nil {
-		return nil, fmt.Errorf("could not parse string: '%s' into gabi.PublicKey", inputs[2].String())
+	if err := unmarshalInput(inputs[2], attesterPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("could not parse string: '%s' into gabi.PublicKey", inputs[2].String()))
 	}
-	if err := json.Unmarshal([]byte(inputs[3].String()), update); err != nil {
-		return nil, fmt.Errorf("could not parse string: '%s' into revocation.Update", inputs[2].String())
+	if err := unmarshalInput(inputs[3], update); err != nil {
+		return nil, invalidArgument(fmt.Errorf("could not parse string: '%s' into revocation.Update", inputs[3].String()))
 	}
-	verified, rebuildClaim, err := credentials.VerifyPresentation(attesterPubKey, update.SignedAccumulator, proof, session)
+	result, err := credentials.VerifyPresentationWithResult(attesterPubKey, update.SignedAccumulator, proof, session)
 	if err != nil {
 		return nil, err
 	}
+	if err := result.Err(); err != nil {
+		if !verificationFailure(err) {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"claim":     nil,
+			"verified":  false,
+			"errorCode": errorCode(err),
+			"result":    result,
+		}, nil
+	}
 	return map[string]interface{}{
-		"claim":    rebuildClaim,
-		"verified": verified,
+		"claim":    result.Claim,
+		"verified": true,
+		"result":   result,
 	}, nil
 }
 
 // VerifyCombinedPresentation verifies that the proof of the claimer is valid. As input
 // this method takes the proof, a session object (created using
-// startVerificationSession) and the public key of the attester which attested
-// the claim
+// startVerificationSession), the public keys of the attesters which attested
+// the claims and their latest accumulators. If the proof contains key ids, the
+// keys and accumulators can be passed in any order.
+// Failed verifications are reported like by VerifyPresentation.
 func VerifyCombinedPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
 	if len(inputs) < 4 {
-		return nil, errors.New("missing inputs")
+		return nil, errMissingInputs
 	}
 
 	proof := &credentials.CombinedPresentationResponse{}
 	session := &credentials.CombinedVerifierSession{}
 	attesterPubKeys := []*gabi.PublicKey{}
 	updates := []*revocation.Update{}
-	if err := json.Unmarshal([]byte(inputs[0].String()), proof); err != nil {
+	if err := unmarshalMessage(inputs[0], proof); err != nil {
 		return nil, err
 	}
 	if err := json.Unmarshal([]byte(inputs[1].String()), session); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[2].String()), &attesterPubKeys); err != nil {
+	if err := unmarshalListInput(inputs[2], &attesterPubKeys); err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(inputs[3].String()), &updates); err != nil {
+	if err := unmarshalListInput(inputs[3], &updates); err != nil {
 		return nil, err
 	}
 	signedAccs := make([]*revocation.SignedAccumulator, len(updates))
@@ -139,8 +179,29 @@ func VerifyCombinedPresentation(this js.Value, inputs []js.Value) (interface{},
 		signedAccs[i] = u.SignedAccumulator
 	}
 
-	verified, rebuildClaims, err := credentials.VerifyCombinedPresentation(attesterPubKeys,
-		signedAccs, proof, session)
+	var (
+		verified      bool
+		rebuildClaims []credentials.Claim
+		err           error
+	)
+	if len(proof.KeyIDs) > 0 {
+		// the keys are resolved using the key ids of the proof, the order of
+		// the keys and accumulators is irrelevant
+		verified, rebuildClaims, err = credentials.VerifyCombinedPresentationWithLookup(&credentials.KeyStore{
+			Keys:         attesterPubKeys,
+			Accumulators: signedAccs,
+		}, proof, session)
+	} else {
+		verified, rebuildClaims, err = credentials.VerifyCombinedPresentation(attesterPubKeys,
+			signedAccs, proof, session)
+	}
+	if verificationFailure(err) {
+		return map[string]interface{}{
+			"claims":    nil,
+			"verified":  false,
+			"errorCode": errorCode(err),
+		}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -149,3 +210,67 @@ func VerifyCombinedPresentation(this js.Value, inputs []js.Value) (interface{},
 		"verified": verified,
 	}, nil
 }
+
+// VerifyTrustedPresentation verifies that the proof of the claimer is valid
+// and was created using the key of a trusted attester. As input this method
+// takes the proof, a session object (created using startVerificationSession),
+// a signed trust list, the PEM encoded ECDSA public key of the maintainer of
+// the trust list and a list of the latest accumulators of the attesters.
+// Optionally the sequence number of the last accepted trust list can be passed
+// to reject older lists. The identity of the matched attester is returned
+// together with the claim and the sequence number of the trust list. Failed
+// verifications are reported like by VerifyPresentation.
+func VerifyTrustedPresentation(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 5 {
+		return nil, errMissingInputs
+	}
+
+	proof := &credentials.PresentationResponse{}
+	session := &credentials.VerifierSession{}
+	updates := []*revocation.Update{}
+	if err := unmarshalMessage(inputs[0], proof); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(inputs[1].String()), session); err != nil {
+		return nil, err
+	}
+	listPubKey, err := signed.UnmarshalPemPublicKey([]byte(inputs[3].String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust list key: %v", err)
+	}
+	minSequence := uint64(0)
+	if len(inputs) > 5 && !inputs[5].IsUndefined() {
+		minSequence = uint64(inputs[5].Int())
+	}
+	registry, listInfo, err := credentials.NewTrustRegistryFromSignedList(listPubKey, []byte(inputs[2].String()), minSequence)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalListInput(inputs[4], &updates); err != nil {
+		return nil, err
+	}
+	signedAccs := make([]*revocation.SignedAccumulator, len(updates))
+	for i, u := range updates {
+		signedAccs[i] = u.SignedAccumulator
+	}
+
+	verified, rebuildClaim, attester, err := credentials.VerifyTrustedPresentation(registry, signedAccs, proof, session)
+	if verificationFailure(err) {
+		return map[string]interface{}{
+			"claim":     nil,
+			"verified":  false,
+			"attester":  "",
+			"sequence":  listInfo.Sequence,
+			"errorCode": errorCode(err),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"claim":    rebuildClaim,
+		"verified": verified,
+		"attester": attester,
+		"sequence": listInfo.Sequence,
+	}, nil
+}
@@ -0,0 +1,130 @@
+// +build wasm
+
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/credentials"
+	"github.com/KILTprotocol/portablegabi/go-wasm/pkg/wallet"
+	"github.com/privacybydesign/gabi"
+	"github.com/privacybydesign/gabi/revocation"
+)
+
+// openWallet decrypts the wallet stored inside the Uint8Array using the
+// passphrase.
+func openWallet(buffer js.Value, passphrase js.Value) (*wallet.Wallet, error) {
+	data, err := bytesFromJS(buffer)
+	if err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in wallet: %v", err))
+	}
+	return wallet.Open(data, []byte(passphrase.String()))
+}
+
+// CreateWallet creates a new encrypted wallet. It takes the claimer and the
+// passphrase as inputs and returns the wallet as Uint8Array.
+func CreateWallet(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 2 {
+		return nil, errMissingInputs
+	}
+	claimer := &credentials.Claimer{}
+	if err := json.Unmarshal([]byte(inputs[0].String()), claimer); err != nil {
+		return nil, err
+	}
+	if claimer.MasterSecret == nil {
+		return nil, errors.New("missing master secret")
+	}
+	return wallet.New(claimer).Export([]byte(inputs[1].String()))
+}
+
+// OpenWallet decrypts a wallet. It takes the wallet as Uint8Array and the
+// passphrase as inputs and returns the claimer and a list of all stored
+// credentials.
+func OpenWallet(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 2 {
+		return nil, errMissingInputs
+	}
+	w, err := openWallet(inputs[0], inputs[1])
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"claimer":     w.Claimer,
+		"credentials": w.List(),
+	}, nil
+}
+
+// AddWalletCredential stores a credential inside the wallet. It takes the
+// wallet as Uint8Array, the passphrase, the public key of the attester and the
+// credential as inputs. It returns the id of the credential and the updated
+// wallet.
+func AddWalletCredential(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 4 {
+		return nil, errMissingInputs
+	}
+	w, err := openWallet(inputs[0], inputs[1])
+	if err != nil {
+		return nil, err
+	}
+	attesterPubKey := &gabi.PublicKey{}
+	credential := &credentials.AttestedClaim{}
+	if err := unmarshalInput(inputs[2], attesterPubKey); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in public key: %v", err))
+	}
+	if err := unmarshalInput(inputs[3], credential); err != nil {
+		return nil, err
+	}
+	id, err := w.Add(attesterPubKey, credential)
+	if err != nil {
+		return nil, err
+	}
+	data, err := w.Export([]byte(inputs[1].String()))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":     id,
+		"wallet": data,
+	}, nil
+}
+
+// RemoveWalletCredential removes a credential from the wallet. It takes the
+// wallet as Uint8Array, the passphrase and the id of the credential as inputs
+// and returns the updated wallet.
+func RemoveWalletCredential(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 3 {
+		return nil, errMissingInputs
+	}
+	w, err := openWallet(inputs[0], inputs[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Remove(inputs[2].String()); err != nil {
+		return nil, err
+	}
+	return w.Export([]byte(inputs[1].String()))
+}
+
+// UpdateWalletCredential updates the witness of a stored credential. It takes
+// the wallet as Uint8Array, the passphrase, the id of the credential and a list
+// of updates as inputs and returns the updated wallet.
+func UpdateWalletCredential(this js.Value, inputs []js.Value) (interface{}, error) {
+	if len(inputs) < 4 {
+		return nil, errMissingInputs
+	}
+	w, err := openWallet(inputs[0], inputs[1])
+	if err != nil {
+		return nil, err
+	}
+	updates := []*revocation.Update{}
+	if err := unmarshalListInput(inputs[3], &updates); err != nil {
+		return nil, invalidArgument(fmt.Errorf("error in update: %v", err))
+	}
+	if err := w.Update(inputs[2].String(), updates); err != nil {
+		return nil, err
+	}
+	return w.Export([]byte(inputs[1].String()))
+}
@@ -12,11 +12,13 @@ import {
   Witness,
   AttesterPrivateKey,
   AttesterPublicKey,
+  AttesterPublicKeyProof,
 } from '../types/Attestation'
 import Claimer from '../claim/Claimer'
 import { AttestationRequest, ClaimError } from '../types/Claim'
 import Accumulator from './Accumulator'
 import goWasmExec from '../wasm/wasm_exec_wrapper'
+import WasmHooks from '../wasm/WasmHooks'
 import AttesterChain from './Attester.chain'
 
 describe('Test attester', () => {
@@ -90,6 +92,57 @@ describe('Test attester', () => {
       ).resolves.toEqual(keypair)
       await expect(Attester.genKeyPair()).resolves.toEqual(keypair)
     })
+    it('Should pass progress callback and abort signal', async () => {
+      ;(goWasmExec as any) = jest.fn(async () => ({
+        privateKey: 'sk',
+        publicKey: 'pk',
+      }))
+      const onProgress = jest.fn()
+      const signal = { aborted: false }
+      await expect(
+        Attester.genKeyPair({ onProgress, signal })
+      ).resolves.toEqual(keypair)
+      expect(goWasmExec).toHaveBeenCalledWith(WasmHooks.genKeypair, [
+        expect.any(Number),
+        expect.any(Number),
+        expect.any(Number),
+        { onProgress, signal, withProof: true },
+      ])
+    })
+    it('Should return the proof of the public key unless it is skipped', async () => {
+      ;(goWasmExec as any) = jest.fn(async () => ({
+        privateKey: 'sk',
+        publicKey: 'pk',
+        publicKeyProof: 'proof',
+      }))
+      const publicKeyProof = new AttesterPublicKeyProof('proof')
+      await expect(Attester.genKeyPair()).resolves.toEqual({
+        ...keypair,
+        publicKeyProof,
+      })
+      await expect(Attester.create()).resolves.toHaveProperty(
+        'publicKeyProof',
+        publicKeyProof
+      )
+      await Attester.genKeyPair({ withProof: false })
+      expect(goWasmExec).toHaveBeenLastCalledWith(WasmHooks.genKeypair, [
+        expect.any(Number),
+        expect.any(Number),
+        expect.any(Number),
+        expect.objectContaining({ withProof: false }),
+      ])
+    })
+    it('Should verify the proof of the public key', async () => {
+      ;(goWasmExec as any) = jest.fn(async () => true)
+      const publicKeyProof = new AttesterPublicKeyProof('proof')
+      await expect(
+        Attester.verifyPublicKey(keypair.publicKey, publicKeyProof)
+      ).resolves.toBe(true)
+      expect(goWasmExec).toHaveBeenCalledWith(WasmHooks.verifyPublicKey, [
+        expect.any(String),
+        expect.any(String),
+      ])
+    })
     it('Should create attester', async () => {
       await expect(
         Attester.create({
@@ -9,6 +9,7 @@ import IAttester, {
   Witness,
   Attestation,
   AttesterPublicKey,
+  AttesterPublicKeyProof,
   AttesterPrivateKey,
   KeyLength,
   DEFAULT_MAX_ATTRIBUTES,
@@ -16,10 +17,29 @@ import IAttester, {
   DEFAULT_KEY_LENGTH,
 } from '../types/Attestation'
 
+/**
+ * The progress of the key generation. During the stage 'safePrimes', attempts counts the tested candidates
+ * and primes the found safe primes. During the stage 'keyProof', step describes the current step of the proof,
+ * done counts its finished parts and total is their number, if known.
+ */
+export type KeyGenProgress = {
+  stage: 'safePrimes' | 'keyPair' | 'keyProof'
+  attempts: number
+  primes: number
+  step: string
+  done: number
+  total: number
+}
+
 export type KeyGenOptions = {
   validityDuration?: number
   maxAttributes?: number
   keyLength?: KeyLength
+  onProgress?: (progress: KeyGenProgress) => void
+  // an AbortSignal, which aborts the key generation
+  signal?: { readonly aborted: boolean }
+  // creates the proof of the public key, which takes several minutes (defaults to true)
+  withProof?: boolean
 }
 
 /**
@@ -39,6 +59,7 @@ export function daysToNanoSecs(days: number): number {
 export default class Attester implements IAttester {
   readonly privateKey: AttesterPrivateKey
   readonly publicKey: AttesterPublicKey
+  readonly publicKeyProof?: AttesterPublicKeyProof
 
   /**
    * Generates a new key pair.
@@ -47,30 +68,42 @@ export default class Attester implements IAttester {
    * @param options.validityDuration The duration in days for which the public key will be valid.
    * @param options.maxAttributes The maximum number of attributes that can be signed with the generated private key.
    * @param options.keyLength The key length of the new key pair. Note that this key will only support credentials and claimer with the same key length.
-   * @returns A newly generated key pair.
+   * @param options.onProgress A function which is called regularly with the progress of the key generation.
+   * @param options.signal An AbortSignal. If it is aborted, the key generation stops and the promise is rejected with the code 'ERR_ABORTED'.
+   * @param options.withProof Whether the proof of the public key should be created, which takes several minutes. Defaults to true.
+   * @returns A newly generated key pair and the proof of the public key, unless it was skipped.
    */
   public static async genKeyPair({
     validityDuration,
     maxAttributes,
     keyLength,
+    onProgress,
+    signal,
+    withProof,
   }: KeyGenOptions = {}): Promise<{
     privateKey: AttesterPrivateKey
     publicKey: AttesterPublicKey
+    publicKeyProof?: AttesterPublicKeyProof
   }> {
     const durationInNanoSecs = daysToNanoSecs(
       validityDuration || DEFAULT_VALIDITY_DURATION
     )
-    const { privateKey, publicKey } = await goWasmExec<{
+    const { privateKey, publicKey, publicKeyProof } = await goWasmExec<{
       privateKey: string
       publicKey: string
+      publicKeyProof?: string
     }>(WasmHooks.genKeypair, [
       maxAttributes || DEFAULT_MAX_ATTRIBUTES,
       durationInNanoSecs,
       keyLength || DEFAULT_KEY_LENGTH,
+      { onProgress, signal, withProof: withProof !== false },
     ])
     return {
       privateKey: new AttesterPrivateKey(privateKey),
       publicKey: new AttesterPublicKey(publicKey),
+      ...(publicKeyProof
+        ? { publicKeyProof: new AttesterPublicKeyProof(publicKeyProof) }
+        : {}),
     }
   }
 
@@ -81,11 +114,33 @@ export default class Attester implements IAttester {
    * @param options.validityDuration The duration in days for which the public key will be valid.
    * @param options.maxAttributes The maximal number of attributes that can be signed with the generated private key.
    * @param options.keyLength The key length of the new key pair. Note that this key will only support credentials and claimer with the same key length.
+   * @param options.onProgress A function which is called regularly with the progress of the key generation.
+   * @param options.signal An AbortSignal, which aborts the key generation.
+   * @param options.withProof Whether the proof of the public key should be created. Defaults to true.
    * @returns A new [[Attester]].
    */
   public static async create(options: KeyGenOptions = {}): Promise<Attester> {
-    const { publicKey, privateKey } = await this.genKeyPair(options)
-    return new Attester(publicKey, privateKey)
+    const { publicKey, privateKey, publicKeyProof } = await this.genKeyPair(
+      options
+    )
+    return new Attester(publicKey, privateKey, publicKeyProof)
+  }
+
+  /**
+   * Verifies that the public key of an [[Attester]] is well formed. Claimers should verify the key before requesting an attestation.
+   *
+   * @param publicKey The public key of the [[Attester]].
+   * @param publicKeyProof The proof of the public key, which was created by [[genKeyPair]].
+   * @returns True if the proof is valid, otherwise the promise is rejected.
+   */
+  public static async verifyPublicKey(
+    publicKey: AttesterPublicKey,
+    publicKeyProof: AttesterPublicKeyProof
+  ): Promise<boolean> {
+    return goWasmExec<boolean>(WasmHooks.verifyPublicKey, [
+      wasmStringify(publicKey),
+      wasmStringify(publicKeyProof),
+    ])
   }
 
   /**
@@ -93,13 +148,16 @@ export default class Attester implements IAttester {
    *
    * @param publicKey The public key for the [[Attester]].
    * @param privateKey The private key for the [[Attester]].
+   * @param publicKeyProof The optional proof of the public key.
    */
   public constructor(
     publicKey: AttesterPublicKey,
-    privateKey: AttesterPrivateKey
+    privateKey: AttesterPrivateKey,
+    publicKeyProof?: AttesterPublicKeyProof
   ) {
     this.publicKey = publicKey
     this.privateKey = privateKey
+    this.publicKeyProof = publicKeyProof
   }
 
   /**
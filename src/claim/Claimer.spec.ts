@@ -15,6 +15,7 @@ import {
   AttesterAttestationSession,
   Witness,
   IIssueAttestation,
+  AttesterPublicKeyProof,
 } from '../types/Attestation'
 import {
   ClaimerAttestationSession,
@@ -61,6 +62,15 @@ describe('Test claimer creation', () => {
     expect(claimerWithPass).not.toStrictEqual(claimer)
     expect(claimerWithPass).not.toStrictEqual(claimerWithoutPass)
   })
+  it('Restores claimer from generated mnemonic', async () => {
+    const { claimer: created, mnemonic } = await Claimer.createWithMnemonic({
+      password: 'password',
+    })
+    const restored = await Claimer.buildFromMnemonic(mnemonic, {
+      password: 'password',
+    })
+    expect(restored).toStrictEqual(created)
+  })
   it('Builds claimer from empty mnemonic seed', async () => {
     await expect(Claimer.buildFromMnemonic('')).rejects.toThrow(
       'Invalid mnemonic'
@@ -190,6 +200,16 @@ describe('Test claimer functionality', () => {
       expect(attestationRequest).toBeDefined()
       expect(claimerSession).toBeDefined()
     })
+    it('Should throw when requesting attestation with an invalid proof of the attester key', async () => {
+      await expect(
+        claimer.requestAttestation({
+          startAttestationMsg: initiateAttestationReq,
+          claim,
+          attesterPubKey: attester.publicKey,
+          attesterPubKeyProof: new AttesterPublicKeyProof('{}'),
+        })
+      ).rejects.toThrow()
+    })
     it('Should throw when requesting attestation with empty object as claim', async () => {
       await expect(
         claimer.requestAttestation({
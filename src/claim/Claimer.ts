@@ -1,6 +1,5 @@
 import { u8aToHex } from '@polkadot/util'
 import validate from '@polkadot/util-crypto/mnemonic/validate'
-import { mnemonicToMiniSecret } from '@polkadot/util-crypto'
 import IClaimer, {
   AttestationRequest,
   ClaimerAttestationSession,
@@ -14,6 +13,7 @@ import {
   InitiateAttestationRequest,
   Attestation,
   AttesterPublicKey,
+  AttesterPublicKeyProof,
   KeyLength,
   DEFAULT_KEY_LENGTH,
 } from '../types/Attestation'
@@ -70,8 +70,13 @@ export default class Claimer implements IClaimer {
     if (!validate(mnemonic)) {
       throw new Error('Invalid mnemonic')
     }
-    const seed = mnemonicToMiniSecret(mnemonic, password)
-    return this.buildFromSeed(seed, keyLength)
+    // the key is derived inside wasm, which supports all key lengths
+    const secret = await goWasmExec<string>(WasmHooks.keyFromSeed, [
+      mnemonic,
+      keyLength || DEFAULT_KEY_LENGTH,
+      password || '',
+    ])
+    return new this(secret)
   }
 
   /**
@@ -99,8 +104,31 @@ export default class Claimer implements IClaimer {
    * @returns A new [[Claimer]].
    */
   public static async create(): Promise<Claimer> {
-    const secret = await goWasmExec<string>(WasmHooks.genKey)
-    return new this(secret)
+    const { claimer } = await this.createWithMnemonic()
+    return claimer
+  }
+
+  /**
+   * Generates a new mnemonic and returns it together with the [[Claimer]] derived from it.
+   * The mnemonic can be used to restore the [[Claimer]] using [[buildFromMnemonic]].
+   *
+   * @param options An optional object containing options for the key generation.
+   * @param options.password The password which is used to generate the key.
+   * @param options.keyLength The key length of the new secret. Note that this secret will only support credentials and attester with the same key length.
+   * @returns The mnemonic and the new [[Claimer]].
+   */
+  public static async createWithMnemonic({
+    password,
+    keyLength,
+  }: {
+    password?: string
+    keyLength?: KeyLength
+  } = {}): Promise<{ claimer: Claimer; mnemonic: string }> {
+    const { claimer, mnemonic } = await goWasmExec<{
+      claimer: string
+      mnemonic: string
+    }>(WasmHooks.genKey, [keyLength || DEFAULT_KEY_LENGTH, password || ''])
+    return { claimer: new this(claimer), mnemonic: JSON.parse(mnemonic) }
   }
 
   /**
@@ -119,6 +147,8 @@ export default class Claimer implements IClaimer {
    * @param p.claim The claim which should get attested.
    * @param p.startAttestationMsg The [[InitiateAttestationRequest]] provided by the attester.
    * @param p.attesterPubKey The [[AttesterPublicKey]].
+   * @param p.attesterPubKeyProof The optional proof of the [[AttesterPublicKey]]. If it is given, the attestation is only
+   * requested if the proof is valid (see [[Attester.verifyPublicKey]]).
    * @returns An [[AttestationRequest]] and a [[ClaimerAttestationSession]] which together with an [[Attestation]]
    * can be used to create a [[Credential]].
    */
@@ -126,14 +156,22 @@ export default class Claimer implements IClaimer {
     claim,
     startAttestationMsg,
     attesterPubKey,
+    attesterPubKeyProof,
   }: {
     // eslint-disable-next-line @typescript-eslint/no-explicit-any
     claim: Record<string, any>
     startAttestationMsg: InitiateAttestationRequest
     attesterPubKey: AttesterPublicKey
+    attesterPubKeyProof?: AttesterPublicKeyProof
   }): ReturnType<IClaimer['requestAttestation']> {
     // check for invalid claim structure
     checkValidClaimStructure(claim)
+    if (attesterPubKeyProof) {
+      await goWasmExec<boolean>(WasmHooks.verifyPublicKey, [
+        wasmStringify(attesterPubKey),
+        wasmStringify(attesterPubKeyProof),
+      ])
+    }
     const { message, session } = await goWasmExec<IGabiMsgSession>(
       WasmHooks.requestAttestation,
       [
@@ -2,7 +2,7 @@
 /* eslint-disable-next-line max-classes-per-file */
 import { SubmittableExtrinsic } from '@polkadot/api/types'
 import Accumulator from '../attestation/Accumulator'
-import WasmData from './Wasm'
+import WasmData, { parseWasmData } from './Wasm'
 import { AttestationRequest } from './Claim'
 import { wasmStringify } from '../wasm/wasm_exec_wrapper'
 
@@ -52,6 +52,14 @@ export class AttesterPublicKey extends WasmData {
   private thisIsOnlyHereToPreventClassMixes: undefined
 }
 
+/**
+ * The proof that the [[AttesterPublicKey]] is well formed, see [[Attester.verifyPublicKey]].
+ */
+export class AttesterPublicKeyProof extends WasmData {
+  // @ts-ignore
+  private thisIsOnlyHereToPreventClassMixes: undefined
+}
+
 /**
  * The off-chain private key of the [[Attester]].
  */
@@ -89,7 +97,7 @@ export class Witness extends WasmData {
  */
 export class Attestation extends WasmData {
   public parse(): IIssueAttestation {
-    return JSON.parse(wasmStringify(this))
+    return parseWasmData(wasmStringify(this)) as IIssueAttestation
   }
 }
 
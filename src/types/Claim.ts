@@ -7,6 +7,7 @@ import { wasmStringify } from '../wasm/wasm_exec_wrapper'
 import {
   Attestation,
   AttesterPublicKey,
+  AttesterPublicKeyProof,
   IIssueAttestation,
   InitiateAttestationRequest,
 } from './Attestation'
@@ -14,18 +15,20 @@ import {
   CombinedPresentationRequest,
   PresentationRequest,
 } from './Verification'
-import WasmData from './Wasm'
+import WasmData, { parseWasmData } from './Wasm'
 
 export default interface IClaimer {
   requestAttestation: ({
     claim,
     startAttestationMsg,
     attesterPubKey,
+    attesterPubKeyProof,
   }: {
     // eslint-disable-next-line @typescript-eslint/no-explicit-any
     claim: Record<string, any>
     startAttestationMsg: InitiateAttestationRequest
     attesterPubKey: AttesterPublicKey
+    attesterPubKeyProof?: AttesterPublicKeyProof
   }) => Promise<{
     message: AttestationRequest
     session: ClaimerAttestationSession
@@ -164,7 +167,7 @@ export class ClaimerAttestationSession extends WasmData {
  */
 export class Presentation extends WasmData {
   public parse(): IProof {
-    return JSON.parse(wasmStringify(this))
+    return parseWasmData(wasmStringify(this)) as IProof
   }
 }
 
@@ -10,15 +10,52 @@ export interface IPresentationRequest {
   keyLength?: KeyLength
 }
 
+/**
+ * The outcome of a single check performed by [[verifyPresentation]], e.g. 'proof' or 'accumulatorFreshness'.
+ */
+export interface IVerificationCheck {
+  name: string
+  passed: boolean
+  error?: string
+}
+
+/**
+ * The detailed result of [[verifyPresentation]], which lists every check and the key and accumulator which were used.
+ */
+export interface IVerificationResult {
+  checks: IVerificationCheck[]
+  keyCounter: number
+  accumulator?: {
+    index: number
+    time: string
+  }
+  // eslint-disable-next-line @typescript-eslint/no-explicit-any
+  claim: Record<string, any> | null
+  // the disclosed attributes whose value was not disclosed, the claim contains the hex encoded SHA-256 hash instead
+  hashedAttributes?: string[]
+}
+
 export interface IVerifiedPresentation {
   verified: boolean
   // eslint-disable-next-line @typescript-eslint/no-explicit-any
   claim: Record<string, any>
+  // the reason why the presentation could not be verified, e.g. 'ERR_PROOF_INVALID'
+  errorCode?: string
+  result?: IVerificationResult
 }
 
 export interface IVerifiedCombinedPresentation {
   verified: boolean
   claims: Array<Record<string, unknown>>
+  // the reason why the presentation could not be verified, e.g. 'ERR_PROOF_INVALID'
+  errorCode?: string
+}
+
+export interface IVerifiedTrustedPresentation extends IVerifiedPresentation {
+  // the identity of the trusted Attester whose key was used, if the presentation could be verified
+  attester: string
+  // the sequence number of the trust list, which should be passed as minSequence to reject older lists
+  sequence: number
 }
 
 /**
@@ -5,7 +5,14 @@ import WasmHooks from '../wasm/WasmHooks'
 export interface IGoWasm {
   execWasmFn: (
     fn: WasmHooks,
-    fnArgs?: Array<string | number | boolean>
+    fnArgs?: Array<
+      | string
+      | number
+      | boolean
+      | Uint8Array
+      | Uint8Array[]
+      | Record<string, unknown>
+    >
   ) => Promise<any>
   close: () => void
   // from wasm_exec.js
@@ -46,6 +53,27 @@ export interface IGoWasm {
   _makeFuncWrapper: () => void
 }
 
+/**
+ * Parses JSON encoded WASM data. Protocol messages are wrapped in an envelope
+ * containing the message type and the protocol version, for these the payload is returned.
+ *
+ * @param data The JSON encoded WASM data.
+ * @returns The parsed data or the payload of the envelope.
+ */
+export function parseWasmData(data: string): { [key: string]: any } {
+  const parsed = JSON.parse(data)
+  if (
+    parsed !== null &&
+    typeof parsed === 'object' &&
+    'type' in parsed &&
+    'version' in parsed &&
+    'payload' in parsed
+  ) {
+    return parsed.payload
+  }
+  return parsed
+}
+
 /**
  * A wrapper for data received from WASM callbacks.
  */
@@ -62,7 +90,7 @@ export default class WasmData {
    * @returns An object of the serialized data string.
    */
   public parse(): { [key: string]: any } {
-    return JSON.parse(this.wasmData)
+    return parseWasmData(this.wasmData)
   }
 
   /**
@@ -31,14 +31,19 @@ export default class CombinedRequestBuilder {
   /**
    * Converts an array of partial requests to be callable by [[requestCombinedPresentation]].
    *
+   * @param options The optional parameter object.
+   * @param options.strict Whether disclosed attributes of unknown types or with malformed values are rejected during the verification.
    * @returns A message for the [[Claimer]] to be used in [[buildCombinedPresentation]] and a session which can be used by the Verifier.
    */
-  public async finalise(): Promise<{
+  public async finalise(
+    options: { strict?: boolean } = {}
+  ): Promise<{
     message: CombinedPresentationRequest
     session: CombinedVerificationSession
   }> {
     return Verifier.requestCombinedPresentation(
-      this.partialRequests as IPresentationRequest[]
+      this.partialRequests as IPresentationRequest[],
+      options
     )
   }
 }
@@ -11,6 +11,7 @@ import {
   IPresentationRequest,
   IVerifiedPresentation,
   IVerifiedCombinedPresentation,
+  IVerifiedTrustedPresentation,
   VerificationSession,
   PresentationRequest,
   CombinedVerificationSession,
@@ -31,17 +32,19 @@ import { Presentation, CombinedPresentation } from '../types/Claim'
  * @param p.requestedAttributes The attributes that need to be disclosed for the Verifier in order to verify the [[Credential]].
  * @param p.reqUpdatedAfter The minimum [[Accumulator]] timestamp on which the [[Credential]] needs to be updated.
  * @param p.keyLength The key length of the new key pair. Note that this key will only support credentials and claimer with the same key length.
+ * @param p.strict Whether disclosed attributes of unknown types or with malformed values are rejected in [[verifyPresentation]] instead of being decoded as hex strings.
  * @returns A session and a message object. The message should be sent to the [[Claimer]] and used in [[buildPresentation]]. The session should be kept private and used in [[verifyPresentation]].
  */
 export async function requestPresentation({
   requestedAttributes,
   reqUpdatedAfter,
   keyLength,
-}: IPresentationRequest): Promise<{
+  strict,
+}: IPresentationRequest & { strict?: boolean }): Promise<{
   message: PresentationRequest
   session: VerificationSession
 }> {
-  let args: [boolean, string, string, KeyLength]
+  let args: [boolean, string, string, KeyLength, boolean]
   if (typeof reqUpdatedAfter === 'undefined') {
     args = [
       false,
@@ -49,6 +52,7 @@ export async function requestPresentation({
       new Date().toISOString(),
       JSON.stringify(requestedAttributes),
       keyLength || DEFAULT_KEY_LENGTH,
+      !!strict,
     ]
   } else {
     args = [
@@ -56,6 +60,7 @@ export async function requestPresentation({
       reqUpdatedAfter.toISOString(),
       JSON.stringify(requestedAttributes),
       keyLength || DEFAULT_KEY_LENGTH,
+      !!strict,
     ]
   }
   const { message, session } = await goWasmExec<IGabiMsgSession>(
@@ -72,10 +77,13 @@ export async function requestPresentation({
  * Initiates a verification session for a combined proof.
  *
  * @param presentationReqs An array of [[PresentationRequest]]s created by the Verifier.
+ * @param options The optional parameter object.
+ * @param options.strict Whether disclosed attributes of unknown types or with malformed values are rejected in [[verifyCombinedPresentation]] instead of being decoded as hex strings.
  * @returns A session and a message object. The message should be sent to the [[Claimer]] and used in [[buildPresentation]]. The session should be kept private and used in [[verifyPresentation]].
  */
 export async function requestCombinedPresentation(
-  presentationReqs: IPresentationRequest[]
+  presentationReqs: IPresentationRequest[],
+  { strict }: { strict?: boolean } = {}
 ): Promise<{
   message: CombinedPresentationRequest
   session: CombinedVerificationSession
@@ -90,6 +98,8 @@ export async function requestCombinedPresentation(
           ...req,
         }))
       ),
+      DEFAULT_KEY_LENGTH,
+      !!strict,
     ]
   )
   return {
@@ -127,6 +137,8 @@ export async function verifyPresentation({
   const response = await goWasmExec<{
     verified: string
     claim: string
+    errorCode?: string
+    result?: string
   }>(WasmHooks.verifyPresentation, [
     wasmStringify(proof),
     wasmStringify(verifierSession),
@@ -136,6 +148,8 @@ export async function verifyPresentation({
   return {
     verified: response.verified === 'true',
     claim: JSON.parse(response.claim),
+    errorCode: response.errorCode && JSON.parse(response.errorCode),
+    result: response.result && JSON.parse(response.result),
   }
 }
 
@@ -163,6 +177,7 @@ export async function verifyCombinedPresentation({
   const response = await goWasmExec<{
     verified: string
     claims: string
+    errorCode?: string
   }>(WasmHooks.verifyCombinedPresentation, [
     wasmStringify(proof),
     wasmStringify(verifierSession),
@@ -176,6 +191,59 @@ export async function verifyCombinedPresentation({
   return {
     verified: response.verified === 'true',
     claims: JSON.parse(response.claims),
+    errorCode: response.errorCode && JSON.parse(response.errorCode),
+  }
+}
+
+/**
+ * Checks whether the presented [[Credential]] is valid and was attested by an [[Attester]] of a signed trust list.
+ *
+ * @param p The parameter object.
+ * @param p.proof The result of combining the [[Credential]], the [[PresentationRequest]] and the [[Attester]]s public key in [[buildPresentation]].
+ * @param p.verifierSession The Verifiers session generated in [[requestPresentation]].
+ * @param p.trustList The JSON encoded trust list signed by its maintainer.
+ * @param p.trustListPublicKey The PEM encoded ECDSA public key of the maintainer of the trust list.
+ * @param p.latestAccumulators The latest [[Accumulator]]s of the trusted [[Attester]]s.
+ * @param p.minSequence The sequence number of the last accepted trust list. Older trust lists are rejected.
+ * @throws If the trust list is invalid, expired or older than minSequence.
+ *
+ * @returns Whether the presentation could be verified and the identity of the matched [[Attester]].
+ */
+export async function verifyTrustedPresentation({
+  proof,
+  verifierSession,
+  trustList,
+  trustListPublicKey,
+  latestAccumulators,
+  minSequence,
+}: {
+  proof: Presentation
+  verifierSession: VerificationSession
+  trustList: string
+  trustListPublicKey: string
+  latestAccumulators: Accumulator[]
+  minSequence?: number
+}): Promise<IVerifiedTrustedPresentation> {
+  const response = await goWasmExec<{
+    verified: string
+    claim: string
+    attester: string
+    sequence: string
+    errorCode?: string
+  }>(WasmHooks.verifyTrustedPresentation, [
+    wasmStringify(proof),
+    wasmStringify(verifierSession),
+    trustList,
+    trustListPublicKey,
+    `[${latestAccumulators.map((acc) => wasmStringify(acc)).join(',')}]`,
+    ...(minSequence !== undefined ? [minSequence] : []),
+  ])
+  return {
+    verified: response.verified === 'true',
+    claim: JSON.parse(response.claim),
+    attester: JSON.parse(response.attester),
+    sequence: JSON.parse(response.sequence),
+    errorCode: response.errorCode && JSON.parse(response.errorCode),
   }
 }
 
@@ -184,4 +252,5 @@ export default {
   requestPresentation,
   verifyPresentation,
   verifyCombinedPresentation,
+  verifyTrustedPresentation,
 }
@@ -20,6 +20,7 @@ enum WasmHooks {
   startAttestationSession = 'startAttestationSession',
   issueAttestation = 'issueAttestation',
   revokeAttestation = 'revokeAttestation',
+  verifyPublicKey = 'verifyPublicKey',
 
   // accumulator methods
   getAccumulatorIndex = 'getAccumulatorIndex',
@@ -34,5 +35,12 @@ enum WasmHooks {
   requestCombinedPresentation = 'requestCombinedPresentation',
   verifyPresentation = 'verifyPresentation',
   verifyCombinedPresentation = 'verifyCombinedPresentation',
+  verifyTrustedPresentation = 'verifyTrustedPresentation',
+
+  // binary encoding methods
+  encodeBinary = 'encodeBinary',
+  decodeBinary = 'decodeBinary',
+  encodeFrames = 'encodeFrames',
+  decodeFrames = 'decodeFrames',
 }
 export default WasmHooks
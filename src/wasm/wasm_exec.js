@@ -9,7 +9,10 @@ const wasmFetchDir =
   process && process.env && process.env.WASM_FETCH_DIR
     ? process.env.WASM_FETCH_DIR
     : './dist'
-const isBrowser = typeof window !== 'undefined'
+// web workers have no window, but load the wasm like the browser
+const isBrowser =
+  typeof window !== 'undefined' ||
+  (typeof self !== 'undefined' && typeof importScripts === 'function')
 
 if (typeof global !== 'undefined') {
   // global already exists
@@ -689,15 +692,13 @@ class GoWasm extends Go {
   execWasmFn(fn, fnArgs) {
     if (fn in global) {
       const args = Array.isArray(fnArgs) ? fnArgs : [fnArgs]
-      return new Promise((resolve, reject) => {
-        global[fn](...args, (err, ...messages) => {
-          if (err) {
-            reject(err)
-          }
-          resolve(...messages)
-        })
-      }).catch((e) => {
-        throw new WasmError(`${fn}: \n${e}`)
+      // exported go functions return a promise which is rejected with an Error containing a code
+      return Promise.resolve(global[fn](...args)).catch((e) => {
+        const err = new WasmError(`${fn}: \n${e && e.message ? e.message : e}`)
+        if (e && e.code) {
+          err.code = e.code
+        }
+        throw err
       })
     }
     throw new Error(`Function ${fn} missing in WASM`)
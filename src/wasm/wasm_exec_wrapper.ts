@@ -35,7 +35,14 @@ export const goWasmInit = async (): Promise<IGoWasm> => {
  */
 const goWasmExec = <T>(
   goHook: WasmHooks,
-  args?: Array<string | number | boolean>
+  args?: Array<
+    | string
+    | number
+    | boolean
+    | Uint8Array
+    | Uint8Array[]
+    | Record<string, unknown>
+  >
 ): Promise<T> =>
   goWasmInit()
     .then((wasm) => wasm.execWasmFn(goHook, args))